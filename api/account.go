@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 
 	db "github.com/badermezzi/KubeGoBank/db/sqlc"
@@ -11,6 +12,23 @@ import (
 	"github.com/lib/pq"
 )
 
+// accountResponse is an account with its balance and overdraft limit also written as decimals of its currency.
+type accountResponse struct {
+	db.Account
+	BalanceDecimal        string `json:"balance_decimal,omitempty"`
+	OverdraftLimitDecimal string `json:"overdraft_limit_decimal,omitempty"`
+	HeldAmountDecimal     string `json:"held_amount_decimal,omitempty"`
+}
+
+func (server *Server) newAccountResponse(account db.Account) accountResponse {
+	return accountResponse{
+		Account:               account,
+		BalanceDecimal:        server.currencies.format(account.Balance, account.Currency),
+		OverdraftLimitDecimal: server.currencies.format(account.OverdraftLimit, account.Currency),
+		HeldAmountDecimal:     server.currencies.format(account.HeldAmount, account.Currency),
+	}
+}
+
 type createAccountRequest struct {
 	Currency string `json:"currency" binding:"required,currency"`
 }
@@ -46,96 +64,191 @@ func (server *Server) createAccount(context *gin.Context) {
 		return
 	}
 
-	context.JSON(http.StatusOK, account)
+	context.JSON(http.StatusOK, server.newAccountResponse(account))
 
 }
 
-type getAccountRequest struct {
-	ID int64 `uri:"id" binding:"required,min=1"`
+// getAccount returns the account loaded by authorizeAccount.
+func (server *Server) getAccount(context *gin.Context) {
+	account := context.MustGet(authorizedAccountKey).(db.Account)
+
+	context.JSON(http.StatusOK, server.newAccountResponse(account))
+
 }
 
-func (server *Server) getAccount(context *gin.Context) {
-	var req getAccountRequest
+type listAccountRequest struct {
+	pageRequest
+}
 
-	err := context.ShouldBindUri(&req)
+func (server *Server) listAccount(context *gin.Context) {
+	var req listAccountRequest
+
+	err := context.ShouldBindQuery(&req)
 	if err != nil {
 		context.JSON(http.StatusBadRequest, errorResponce(err))
 		return
 	}
 
-	account, err := server.store.GetAccount(context, req.ID)
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			context.JSON(http.StatusNotFound, errorResponce(err))
-			return
-		}
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	arg := db.ListAccountsParams{
+		Owner:     authPayload.Username,
+		AfterID:   cursor.ID,
+		PageLimit: pageLimit(pageSize),
+	}
 
+	accounts, err := server.store.ListAccounts(context, arg)
+	if err != nil {
 		context.JSON(http.StatusInternalServerError, errorResponce(err))
 		return
 	}
 
-	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	response := make([]accountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		response = append(response, server.newAccountResponse(account))
+	}
+
+	context.JSON(http.StatusOK, newListResponse(response, pageSize, func(account accountResponse) pageCursor {
+		return pageCursor{ID: account.ID}
+	}))
+
+}
+
+// deleteAccount closes the account loaded by authorizeAccount. Accounts are never removed,
+// so their history stays readable. The balance must be zero, use closeAccount to sweep it elsewhere first.
+func (server *Server) deleteAccount(context *gin.Context) {
+	account := context.MustGet(authorizedAccountKey).(db.Account)
+
+	server.closeAccountTx(context, db.CloseAccountTxParams{AccountID: account.ID})
+}
+
+type closeAccountRequest struct {
+	SweepToAccountID int64 `json:"sweep_to_account_id" binding:"omitempty,min=1"`
+}
+
+// closeAccount closes the account loaded by authorizeAccount, optionally sweeping its balance to another account.
+func (server *Server) closeAccount(context *gin.Context) {
+	var req closeAccountRequest
+
+	// The body is optional, an empty one closes an account that is already at zero.
+	if context.Request.ContentLength != 0 {
+		err := context.ShouldBindJSON(&req)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+	}
 
-	if account.Owner != authPayload.Username {
-		err := errors.New("account doesn't belong to the authenticated user")
-		context.JSON(http.StatusUnauthorized, errorResponce(err))
+	account := context.MustGet(authorizedAccountKey).(db.Account)
+
+	if req.SweepToAccountID != 0 {
+		if req.SweepToAccountID == account.ID {
+			err := errors.New("cannot sweep an account into itself")
+			context.JSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+
+		_, valid := server.validAccount(context, req.SweepToAccountID, account.Currency)
+		if !valid {
+			return
+		}
+	}
+
+	server.closeAccountTx(context, db.CloseAccountTxParams{
+		AccountID:        account.ID,
+		SweepToAccountID: req.SweepToAccountID,
+	})
+}
+
+type closeAccountResponse struct {
+	Account accountResponse     `json:"account"`
+	Sweep   *transferTxResponse `json:"sweep,omitempty"`
+}
+
+func (server *Server) closeAccountTx(context *gin.Context, arg db.CloseAccountTxParams) {
+	result, err := server.store.CloseAccountTx(context, arg)
+	if err != nil {
+		context.JSON(transferErrorStatus(err), errorResponce(err))
 		return
 	}
 
-	context.JSON(http.StatusOK, account)
+	response := closeAccountResponse{Account: server.newAccountResponse(result.Account)}
+	if result.Sweep != nil {
+		sweep := server.newTransferTxResponse(*result.Sweep)
+		response.Sweep = &sweep
+	}
 
+	context.JSON(http.StatusOK, response)
 }
 
-type listAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+// updateAccountRequest lists the account attributes an owner may change.
+// Balances only move through transfers and banker adjustments, so they are not part of it.
+type updateAccountRequest struct {
+	Nickname *string `json:"nickname" binding:"omitempty,max=64"`
 }
 
-func (server *Server) listAccount(context *gin.Context) {
-	var req listAccountRequest
+func (server *Server) updateAccount(context *gin.Context) {
+	var req updateAccountRequest
 
-	err := context.ShouldBindQuery(&req)
+	err := context.ShouldBindJSON(&req)
 	if err != nil {
 		context.JSON(http.StatusBadRequest, errorResponce(err))
 		return
 	}
 
-	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+	account := context.MustGet(authorizedAccountKey).(db.Account)
 
-	arg := db.ListAccountsParams{
-		Owner:  authPayload.Username,
-		Limit:  req.PageSize,
-		Offset: (req.PageID - 1) * req.PageSize,
+	arg := db.UpdateAccountParams{
+		ID: account.ID,
+	}
+	if req.Nickname != nil {
+		arg.Nickname = sql.NullString{String: *req.Nickname, Valid: true}
 	}
 
-	accounts, err := server.store.ListAccounts(context, arg)
+	account, err = server.store.UpdateAccount(context, arg)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, errorResponce(err))
 		return
 	}
 
-	context.JSON(http.StatusOK, accounts)
+	context.JSON(http.StatusOK, server.newAccountResponse(account))
+}
 
+func (server *Server) freezeAccount(context *gin.Context) {
+	server.setAccountStatus(context, db.AccountStatusFrozen)
 }
 
-type deleteAccountRequest struct {
-	ID int64 `uri:"id" binding:"required,min=1"`
+func (server *Server) unfreezeAccount(context *gin.Context) {
+	server.setAccountStatus(context, db.AccountStatusActive)
 }
 
-func (server *Server) deleteAccount(context *gin.Context) {
-	var req deleteAccountRequest
+// setAccountStatus moves the account loaded by authorizeAccount to the given status, if that transition is allowed.
+func (server *Server) setAccountStatus(context *gin.Context, status string) {
+	account := context.MustGet(authorizedAccountKey).(db.Account)
 
-	err := context.ShouldBindUri(&req)
-	if err != nil {
-		context.JSON(http.StatusBadRequest, errorResponce(err))
+	if !db.CanTransitionAccountStatus(account.Status, status) {
+		err := fmt.Errorf("account [%d] cannot go from %s to %s", account.ID, account.Status, status)
+		context.JSON(http.StatusConflict, errorResponce(err))
 		return
 	}
 
-	// checking if id valid and account exist
-	_, err = server.store.GetAccount(context, req.ID)
+	arg := db.UpdateAccountStatusParams{
+		ID:         account.ID,
+		Status:     status,
+		FromStatus: account.Status,
+	}
+
+	account, err := server.store.UpdateAccountStatus(context, arg)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			context.JSON(http.StatusNotFound, errorResponce(err))
+			err := errors.New("account status was changed by another request")
+			context.JSON(http.StatusConflict, errorResponce(err))
 			return
 		}
 
@@ -143,40 +256,109 @@ func (server *Server) deleteAccount(context *gin.Context) {
 		return
 	}
 
-	// delete account
-	err = server.store.DeleteAccount(context, req.ID)
+	context.JSON(http.StatusOK, server.newAccountResponse(account))
+}
+
+// overdraftLimitChangeResponse is an overdraft limit change with both limits also written as decimals.
+type overdraftLimitChangeResponse struct {
+	db.OverdraftLimitChange
+	OldLimitDecimal string `json:"old_limit_decimal,omitempty"`
+	NewLimitDecimal string `json:"new_limit_decimal,omitempty"`
+}
+
+func (server *Server) newOverdraftLimitChangeResponse(change db.OverdraftLimitChange, currency string) overdraftLimitChangeResponse {
+	return overdraftLimitChangeResponse{
+		OverdraftLimitChange: change,
+		OldLimitDecimal:      server.currencies.format(change.OldLimit, currency),
+		NewLimitDecimal:      server.currencies.format(change.NewLimit, currency),
+	}
+}
+
+type setOverdraftLimitRequest struct {
+	OverdraftLimit *int64 `json:"overdraft_limit" binding:"required,min=0"`
+}
+
+type setOverdraftLimitResponse struct {
+	Account accountResponse              `json:"account"`
+	Change  overdraftLimitChangeResponse `json:"change"`
+}
+
+// setOverdraftLimit lets a banker change how far below zero the account loaded by authorizeAccount may go.
+func (server *Server) setOverdraftLimit(context *gin.Context) {
+	var req setOverdraftLimitRequest
+
+	err := context.ShouldBindJSON(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	account := context.MustGet(authorizedAccountKey).(db.Account)
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	arg := db.SetOverdraftLimitTxParams{
+		AccountID:      account.ID,
+		OverdraftLimit: *req.OverdraftLimit,
+		ChangedBy:      authPayload.Username,
+	}
+
+	result, err := server.store.SetOverdraftLimitTx(context, arg)
 	if err != nil {
+		if errors.Is(err, db.ErrBalanceBelowOverdraftLimit) {
+			context.JSON(http.StatusUnprocessableEntity, errorResponce(err))
+			return
+		}
+
 		context.JSON(http.StatusInternalServerError, errorResponce(err))
 		return
 	}
 
-	context.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+	context.JSON(http.StatusOK, setOverdraftLimitResponse{
+		Account: server.newAccountResponse(result.Account),
+		Change:  server.newOverdraftLimitChangeResponse(result.Change, result.Account.Currency),
+	})
 }
 
-type updateAccountRequest struct {
-	ID      int64 `json:"id" binding:"required,min=1"`
-	Balance int64 `json:"balance" binding:"required,min=1"`
+type listOverdraftLimitChangesRequest struct {
+	pageRequest
 }
 
-func (server *Server) updateAccount(context *gin.Context) {
-	var req updateAccountRequest
+// listOverdraftLimitChanges returns the audit trail of overdraft limit changes on the account loaded by authorizeAccount.
+func (server *Server) listOverdraftLimitChanges(context *gin.Context) {
+	var req listOverdraftLimitChangesRequest
 
-	err := context.ShouldBindJSON(&req)
+	err := context.ShouldBindQuery(&req)
 	if err != nil {
 		context.JSON(http.StatusBadRequest, errorResponce(err))
 		return
 	}
 
-	arg := db.UpdateAccountParams{
-		ID:      req.ID,
-		Balance: req.Balance,
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
 	}
 
-	account, err := server.store.UpdateAccount(context, arg)
+	account := context.MustGet(authorizedAccountKey).(db.Account)
+
+	arg := db.ListOverdraftLimitChangesParams{
+		AccountID: account.ID,
+		AfterID:   cursor.ID,
+		PageLimit: pageLimit(pageSize),
+	}
+
+	changes, err := server.store.ListOverdraftLimitChanges(context, arg)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, errorResponce(err))
 		return
 	}
 
-	context.JSON(http.StatusOK, account)
+	response := make([]overdraftLimitChangeResponse, 0, len(changes))
+	for _, change := range changes {
+		response = append(response, server.newOverdraftLimitChangeResponse(change, account.Currency))
+	}
+
+	context.JSON(http.StatusOK, newListResponse(response, pageSize, func(change overdraftLimitChangeResponse) pageCursor {
+		return pageCursor{ID: change.ID}
+	}))
 }
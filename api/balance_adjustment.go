@@ -0,0 +1,82 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/gin-gonic/gin"
+)
+
+type adjustBalanceRequest struct {
+	Amount     util.Money `json:"amount" binding:"money,nonzero_money"` // Amount is credited when positive and debited when negative.
+	ReasonCode string     `json:"reason_code" binding:"required,adjustment_reason"`
+	Note       string     `json:"note" binding:"max=255"`
+}
+
+// adjustBalanceResponse writes the amounts of an adjustment also as decimals of the account currency.
+type adjustBalanceResponse struct {
+	Adjustment      balanceAdjustmentResponse `json:"adjustment"`
+	Entry           ledgerEntryResponse       `json:"entry"`
+	SuspenseEntry   ledgerEntryResponse       `json:"suspense_entry"`
+	Account         accountResponse           `json:"account"`
+	SuspenseAccount accountResponse           `json:"suspense_account"`
+}
+
+type balanceAdjustmentResponse struct {
+	db.BalanceAdjustment
+	AmountDecimal string `json:"amount_decimal,omitempty"`
+}
+
+func (server *Server) newAdjustBalanceResponse(result db.AdjustBalanceTxResult) adjustBalanceResponse {
+	currency := result.Account.Currency
+
+	return adjustBalanceResponse{
+		Adjustment: balanceAdjustmentResponse{
+			BalanceAdjustment: result.Adjustment,
+			AmountDecimal:     server.currencies.format(result.Adjustment.Amount, currency),
+		},
+		Entry:           server.newLedgerEntryResponse(result.Entry, currency),
+		SuspenseEntry:   server.newLedgerEntryResponse(result.SuspenseEntry, currency),
+		Account:         server.newAccountResponse(result.Account),
+		SuspenseAccount: server.newAccountResponse(result.SuspenseAccount),
+	}
+}
+
+// adjustBalance lets a banker correct the balance of the account loaded by authorizeAccount.
+func (server *Server) adjustBalance(context *gin.Context) {
+	var req adjustBalanceRequest
+
+	err := context.ShouldBindJSON(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	account := context.MustGet(authorizedAccountKey).(db.Account)
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if req.Amount.Currency != account.Currency {
+		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", account.ID, account.Currency, req.Amount.Currency)
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	arg := db.AdjustBalanceTxParams{
+		AccountID:  account.ID,
+		Amount:     req.Amount.Amount,
+		ReasonCode: req.ReasonCode,
+		Note:       req.Note,
+		CreatedBy:  authPayload.Username,
+	}
+
+	result, err := server.store.AdjustBalanceTx(context, arg)
+	if err != nil {
+		context.JSON(transferErrorStatus(err), errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, server.newAdjustBalanceResponse(result))
+}
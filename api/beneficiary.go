@@ -0,0 +1,257 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// defaultBeneficiaryCoolingOffPeriod is used when BENEFICIARY_COOLING_OFF_PERIOD is not set.
+const defaultBeneficiaryCoolingOffPeriod = 24 * time.Hour
+
+type createBeneficiaryRequest struct {
+	Nickname  string `json:"nickname" binding:"required,max=40"`
+	AccountID int64  `json:"account_id" binding:"required,min=1"`
+	Currency  string `json:"currency" binding:"required,currency"` // Currency must be the currency of the account.
+}
+
+type beneficiaryResponse struct {
+	ID              int64     `json:"id"`
+	Nickname        string    `json:"nickname"`
+	AccountID       int64     `json:"account_id"`
+	Currency        string    `json:"currency"`
+	CreatedAt       time.Time `json:"created_at"`
+	CoolingOffUntil time.Time `json:"cooling_off_until"` // CoolingOffUntil is when transfers to the beneficiary stop being capped.
+}
+
+func (server *Server) newBeneficiaryResponse(beneficiary db.Beneficiary) beneficiaryResponse {
+	return beneficiaryResponse{
+		ID:              beneficiary.ID,
+		Nickname:        beneficiary.Nickname,
+		AccountID:       beneficiary.AccountID,
+		Currency:        beneficiary.Currency,
+		CreatedAt:       beneficiary.CreatedAt,
+		CoolingOffUntil: server.coolingOffUntil(beneficiary),
+	}
+}
+
+// beneficiaryCoolingOffPeriod returns BENEFICIARY_COOLING_OFF_PERIOD, or its default if it is not set.
+func beneficiaryCoolingOffPeriod(config util.Config) time.Duration {
+	if config.BeneficiaryCoolingOffPeriod <= 0 {
+		return defaultBeneficiaryCoolingOffPeriod
+	}
+	return config.BeneficiaryCoolingOffPeriod
+}
+
+// coolingOffUntil returns the end of the cooling-off period of a beneficiary, which starts when it is saved.
+func (server *Server) coolingOffUntil(beneficiary db.Beneficiary) time.Time {
+	return beneficiary.CoolingOffUntil(beneficiaryCoolingOffPeriod(server.config))
+}
+
+// checkCoolingOff rejects an amount paid at the given time into an account the sender saved as a beneficiary,
+// deleted or not, less than BENEFICIARY_COOLING_OFF_PERIOD before, if it is above the cooling-off limit of
+// its currency. It applies however the account is addressed, by beneficiary, account ID, payee or schedule.
+func (server *Server) checkCoolingOff(context *gin.Context, owner string, accountID int64, amount util.Money, at time.Time) bool {
+	beneficiary, err := server.store.GetBeneficiaryByAccount(context, db.GetBeneficiaryByAccountParams{
+		Owner:     owner,
+		AccountID: accountID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true
+		}
+
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return false
+	}
+
+	currency, ok := server.currencies.get(amount.Currency)
+	if !ok {
+		err := fmt.Errorf("unknown currency %s", amount.Currency)
+		context.JSON(http.StatusUnprocessableEntity, errorResponce(err))
+		return false
+	}
+
+	err = beneficiary.CheckCoolingOff(beneficiaryCoolingOffPeriod(server.config), currency, amount.Amount, at)
+	if err != nil {
+		context.JSON(http.StatusUnprocessableEntity, errorResponce(err))
+		return false
+	}
+	return true
+}
+
+// validBeneficiary loads the account of a beneficiary of owner that a transfer can be paid into.
+func (server *Server) validBeneficiary(context *gin.Context, beneficiaryID int64, owner string) (db.Account, bool) {
+	beneficiary, err := server.store.GetBeneficiary(context, beneficiaryID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			context.JSON(http.StatusNotFound, errorResponce(err))
+			return db.Account{}, false
+		}
+
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return db.Account{}, false
+	}
+
+	if beneficiary.Owner != owner {
+		err := errors.New("beneficiary doesn't belong to the authenticated user")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return db.Account{}, false
+	}
+
+	return server.validToAccount(context, beneficiary.AccountID, beneficiary.Currency)
+}
+
+// createBeneficiary saves an account the authenticated user pays often under a nickname.
+func (server *Server) createBeneficiary(context *gin.Context) {
+	var req createBeneficiaryRequest
+
+	err := context.ShouldBindJSON(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	_, valid := server.validToAccount(context, req.AccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	arg := db.CreateBeneficiaryParams{
+		Owner:     authPayload.Username,
+		Nickname:  req.Nickname,
+		AccountID: req.AccountID,
+		Currency:  req.Currency,
+	}
+
+	beneficiary, err := server.store.CreateBeneficiary(context, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := fmt.Errorf("account [%d] is already saved as a beneficiary", req.AccountID)
+			context.JSON(http.StatusForbidden, errorResponce(err))
+			return
+		}
+
+		pqError, ok := err.(*pq.Error)
+		if ok {
+			switch pqError.Code.Name() {
+			case "foreign_key_violation", "unique_violation":
+				context.JSON(http.StatusForbidden, errorResponce(err))
+				return
+			}
+		}
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, server.newBeneficiaryResponse(beneficiary))
+}
+
+type listBeneficiariesRequest struct {
+	pageRequest
+}
+
+func (server *Server) listBeneficiaries(context *gin.Context) {
+	var req listBeneficiariesRequest
+
+	err := context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	arg := db.ListBeneficiariesParams{
+		Owner:     authPayload.Username,
+		AfterID:   cursor.ID,
+		PageLimit: pageLimit(pageSize),
+	}
+
+	beneficiaries, err := server.store.ListBeneficiaries(context, arg)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	response := make([]beneficiaryResponse, 0, len(beneficiaries))
+	for _, beneficiary := range beneficiaries {
+		response = append(response, server.newBeneficiaryResponse(beneficiary))
+	}
+
+	context.JSON(http.StatusOK, newListResponse(response, pageSize, func(beneficiary beneficiaryResponse) pageCursor {
+		return pageCursor{ID: beneficiary.ID}
+	}))
+}
+
+// getBeneficiary returns the beneficiary loaded by authorizeBeneficiary.
+func (server *Server) getBeneficiary(context *gin.Context) {
+	beneficiary := context.MustGet(authorizedBeneficiaryKey).(db.Beneficiary)
+
+	context.JSON(http.StatusOK, server.newBeneficiaryResponse(beneficiary))
+}
+
+// updateBeneficiaryRequest only renames a beneficiary. Pointing it at another account is saving a new beneficiary,
+// with a cooling-off period of its own.
+type updateBeneficiaryRequest struct {
+	Nickname string `json:"nickname" binding:"required,max=40"`
+}
+
+func (server *Server) updateBeneficiary(context *gin.Context) {
+	var req updateBeneficiaryRequest
+
+	err := context.ShouldBindJSON(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	beneficiary := context.MustGet(authorizedBeneficiaryKey).(db.Beneficiary)
+
+	beneficiary, err = server.store.UpdateBeneficiary(context, db.UpdateBeneficiaryParams{
+		ID:       beneficiary.ID,
+		Nickname: req.Nickname,
+	})
+	if err != nil {
+		pqError, ok := err.(*pq.Error)
+		if ok {
+			switch pqError.Code.Name() {
+			case "unique_violation":
+				context.JSON(http.StatusForbidden, errorResponce(err))
+				return
+			}
+		}
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, server.newBeneficiaryResponse(beneficiary))
+}
+
+func (server *Server) deleteBeneficiary(context *gin.Context) {
+	beneficiary := context.MustGet(authorizedBeneficiaryKey).(db.Beneficiary)
+
+	err := server.store.DeleteBeneficiary(context, beneficiary.ID)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "beneficiary deleted"})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/go-playground/validator/v10"
+)
+
+// currencyRegistry mirrors the currencies table in memory, so validating a request or formatting
+// an amount never hits the database. It is loaded when the server starts and synced in the background,
+// so currencies enabled or disabled by another server instance are picked up.
+type currencyRegistry struct {
+	store db.Store
+
+	mutex      sync.RWMutex
+	currencies map[string]db.Currency
+}
+
+func newCurrencyRegistry(store db.Store) *currencyRegistry {
+	return &currencyRegistry{
+		store:      store,
+		currencies: make(map[string]db.Currency),
+	}
+}
+
+// get returns a currency of the registry, enabled or not.
+func (registry *currencyRegistry) get(code string) (db.Currency, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	currency, ok := registry.currencies[code]
+	return currency, ok
+}
+
+// validCurrency is the "currency" validator: the field must be an enabled currency of the registry.
+func (registry *currencyRegistry) validCurrency(fieldLevel validator.FieldLevel) bool {
+	code, ok := fieldLevel.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+
+	currency, ok := registry.get(code)
+	return ok && currency.Enabled
+}
+
+// validMoney is the "money" validator: the field must be util.Money of an enabled currency of the registry.
+func (registry *currencyRegistry) validMoney(fieldLevel validator.FieldLevel) bool {
+	money, ok := fieldLevel.Field().Interface().(util.Money)
+	if !ok {
+		return false
+	}
+
+	currency, ok := registry.get(money.Currency)
+	return ok && currency.Enabled
+}
+
+// format writes an amount of the currency as a decimal. It returns an empty string for a currency
+// the registry does not know yet, rather than guessing its minor unit.
+func (registry *currencyRegistry) format(amount int64, code string) string {
+	currency, ok := registry.get(code)
+	if !ok {
+		return ""
+	}
+	return util.FormatAmount(amount, currency.MinorUnit)
+}
+
+// replace swaps the whole registry for the given currencies.
+func (registry *currencyRegistry) replace(currencies []db.Currency) {
+	byCode := make(map[string]db.Currency, len(currencies))
+	for _, currency := range currencies {
+		byCode[currency.Code] = currency
+	}
+
+	registry.mutex.Lock()
+	registry.currencies = byCode
+	registry.mutex.Unlock()
+}
+
+// sync reloads the registry from the database.
+func (registry *currencyRegistry) sync(ctx context.Context) error {
+	currencies, err := registry.store.ListCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	registry.replace(currencies)
+	return nil
+}
+
+// run syncs the registry every interval until the context is cancelled. The first load is done by Start.
+func (registry *currencyRegistry) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := registry.sync(ctx)
+		if err != nil {
+			log.Println("cannot sync currencies:", err)
+		}
+	}
+}
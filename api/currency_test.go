@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyRegistrySync(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	registry := newCurrencyRegistry(store)
+
+	_, ok := registry.get(util.USD)
+	require.False(t, ok)
+
+	store.EXPECT().ListCurrencies(gomock.Any()).Times(1).Return(testCurrencies, nil)
+	require.NoError(t, registry.sync(context.Background()))
+
+	currency, ok := registry.get(util.JPY)
+	require.True(t, ok)
+	require.Equal(t, int32(0), currency.MinorUnit)
+
+	// A failed sync keeps the currencies loaded so far
+	store.EXPECT().ListCurrencies(gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+	require.ErrorIs(t, registry.sync(context.Background()), sql.ErrConnDone)
+	_, ok = registry.get(util.JPY)
+	require.True(t, ok)
+
+	// Currencies removed from the table are dropped
+	store.EXPECT().ListCurrencies(gomock.Any()).Times(1).Return(testCurrencies[:1], nil)
+	require.NoError(t, registry.sync(context.Background()))
+	_, ok = registry.get(util.JPY)
+	require.False(t, ok)
+}
+
+func TestCurrencyRegistryFormat(t *testing.T) {
+	registry := newCurrencyRegistry(nil)
+	registry.replace(testCurrencies)
+
+	require.Equal(t, "123.45", registry.format(12345, util.USD))
+	require.Equal(t, "12345", registry.format(12345, util.JPY))
+	require.Equal(t, "12.345", registry.format(12345, util.KWD))
+	require.Empty(t, registry.format(12345, "XYZ"))
+}
+
+func TestCreateAccountCurrencyRegistry(t *testing.T) {
+	user := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		currency      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "ZeroDecimals",
+			currency: util.JPY,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Eq(db.CreateAccountParams{Owner: user.Username, Currency: util.JPY})).
+					Times(1).
+					Return(db.Account{ID: 1, Owner: user.Username, Currency: util.JPY, Status: db.AccountStatusActive}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var account accountResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &account)
+				require.NoError(t, err)
+				require.Equal(t, util.JPY, account.Currency)
+				require.Equal(t, "0", account.BalanceDecimal)
+			},
+		},
+		{
+			name:     "DisabledCurrency",
+			currency: "CHF",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "UnknownCurrency",
+			currency: "XYZ",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.currencies.replace(append(testCurrencies, db.Currency{Code: "CHF", NumericCode: 756, MinorUnit: 2}))
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"currency": tc.currency})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/accounts", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestGetAccountDecimalBalance(t *testing.T) {
+	user := randomUser(t)
+
+	testCases := []struct {
+		currency       string
+		balance        string
+		overdraftLimit string
+	}{
+		{currency: util.USD, balance: "-12.34", overdraftLimit: "50.00"},
+		{currency: util.JPY, balance: "-1234", overdraftLimit: "5000"},
+		{currency: util.KWD, balance: "-1.234", overdraftLimit: "5.000"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.currency, func(t *testing.T) {
+			account := randomAccount(user.Username)
+			account.Currency = tc.currency
+			account.Balance = -1234
+			account.OverdraftLimit = 5000
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%d", account.ID), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var got accountResponse
+			err = json.Unmarshal(recorder.Body.Bytes(), &got)
+			require.NoError(t, err)
+			require.Equal(t, account.Balance, got.Balance)
+			require.Equal(t, tc.balance, got.BalanceDecimal)
+			require.Equal(t, tc.overdraftLimit, got.OverdraftLimitDecimal)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+type listEntriesRequest struct {
+	From      time.Time `form:"from"`                                             // From is the first created_at to include, RFC 3339.
+	To        time.Time `form:"to" binding:"omitempty,gtfield=From"`              // To is the first created_at to leave out, RFC 3339.
+	Direction string    `form:"direction" binding:"omitempty,oneof=credit debit"` // Direction keeps only credits or only debits.
+	pageRequest
+}
+
+// entryResponse is a statement line: an entry, the balance right after it and the other side of its transfer.
+type entryResponse struct {
+	ID                    int64     `json:"id"`
+	AccountID             int64     `json:"account_id"`
+	Amount                int64     `json:"amount"`
+	BalanceAfter          int64     `json:"balance_after"`
+	AmountDecimal         string    `json:"amount_decimal,omitempty"`
+	BalanceAfterDecimal   string    `json:"balance_after_decimal,omitempty"`
+	TransferID            *int64    `json:"transfer_id,omitempty"`
+	Description           string    `json:"description,omitempty"`
+	CounterpartyAccountID *int64    `json:"counterparty_account_id,omitempty"`
+	CounterpartyOwner     string    `json:"counterparty_owner,omitempty"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+func (server *Server) newEntryResponse(row db.ListStatementEntriesRow, currency string) entryResponse {
+	response := entryResponse{
+		ID:                  row.ID,
+		AccountID:           row.AccountID,
+		Amount:              row.Amount,
+		BalanceAfter:        row.BalanceAfter,
+		AmountDecimal:       server.currencies.format(row.Amount, currency),
+		BalanceAfterDecimal: server.currencies.format(row.BalanceAfter, currency),
+		TransferID:          row.TransferID,
+		Description:         row.Description,
+		CreatedAt:           row.CreatedAt,
+	}
+	if row.CounterpartyAccountID.Valid {
+		response.CounterpartyAccountID = &row.CounterpartyAccountID.Int64
+		response.CounterpartyOwner = row.CounterpartyOwner.String
+	}
+	return response
+}
+
+// listEntries returns the statement of the account loaded by authorizeAccount.
+func (server *Server) listEntries(context *gin.Context) {
+	var req listEntriesRequest
+
+	err := context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	account := context.MustGet(authorizedAccountKey).(db.Account)
+
+	arg := db.ListStatementEntriesParams{
+		AccountID: account.ID,
+		FromTime:  sql.NullTime{Time: req.From, Valid: !req.From.IsZero()},
+		ToTime:    sql.NullTime{Time: req.To, Valid: !req.To.IsZero()},
+		Direction: req.Direction,
+		AfterID:   cursor.ID,
+		PageLimit: pageLimit(pageSize),
+	}
+
+	rows, err := server.store.ListStatementEntries(context, arg)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	entries := make([]entryResponse, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, server.newEntryResponse(row, account.Currency))
+	}
+
+	context.JSON(http.StatusOK, newListResponse(entries, pageSize, func(entry entryResponse) pageCursor {
+		return pageCursor{ID: entry.ID}
+	}))
+}
+
+// ledgerEntryResponse is an entry booked by a transfer or an adjustment, with its amount also written as a decimal.
+type ledgerEntryResponse struct {
+	db.Entry
+	AmountDecimal string `json:"amount_decimal,omitempty"`
+}
+
+func (server *Server) newLedgerEntryResponse(entry db.Entry, currency string) ledgerEntryResponse {
+	return ledgerEntryResponse{
+		Entry:         entry,
+		AmountDecimal: server.currencies.format(entry.Amount, currency),
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotencyKey reads the optional Idempotency-Key header. It returns nil if the header is not set.
+// The key is scoped to the user and bound to a fingerprint of the request, so it can't be replayed with another body.
+func idempotencyKey(context *gin.Context, username string, req any) (*db.CreateIdempotencyKeyParams, error) {
+	key := context.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+
+	// The scheduler makes runs under the owner's keys too, a client key must not take one of them first.
+	if strings.HasPrefix(key, db.ScheduledTransferKeyPrefix) {
+		return nil, fmt.Errorf("%s must not start with %q", idempotencyKeyHeader, db.ScheduledTransferKeyPrefix)
+	}
+
+	return &db.CreateIdempotencyKeyParams{
+		Username:    username,
+		Key:         key,
+		RequestHash: requestFingerprint(req),
+	}, nil
+}
+
+// requestFingerprint hashes the bound request, so that formatting differences in the body don't count as a different request.
+func requestFingerprint(req any) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// completedTransfer looks up the transfer an earlier request already completed under the key. A retry is answered
+// with it before anything else is checked again, since the accounts, payee or rate may have changed since.
+// It returns nil if the key is not set or not used yet, and false if it answered the request with an error.
+func (server *Server) completedTransfer(context *gin.Context, key *db.CreateIdempotencyKeyParams) (*db.TransferTxResult, bool) {
+	if key == nil {
+		return nil, true
+	}
+
+	stored, err := server.store.GetIdempotencyKey(context, db.GetIdempotencyKeyParams{
+		Username: key.Username,
+		Key:      key.Key,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, true
+		}
+
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return nil, false
+	}
+
+	result, err := db.StoredTransferTxResult(stored, key.RequestHash)
+	if err != nil {
+		context.JSON(transferErrorStatus(err), errorResponce(err))
+		return nil, false
+	}
+
+	return result, true
+}
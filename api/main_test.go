@@ -13,15 +13,27 @@ import (
 
 func newTestServer(t *testing.T, store db.Store) *Server {
 	config := util.Config{
-		TokenSymmetricKey:   util.RandomString(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:    util.RandomString(32),
+		AccessTokenDuration:  time.Minute,
+		RefreshTokenDuration: time.Hour,
 	}
 
 	server, err := NewServer(config, store)
 	require.NoError(t, err)
+
+	server.currencies.replace(testCurrencies)
 	return server
 }
 
+// testCurrencies stands in for the currencies seeded by the migrations, since test servers are never started.
+var testCurrencies = []db.Currency{
+	{Code: util.USD, NumericCode: 840, MinorUnit: 2, Enabled: true, BeneficiaryCoolingOffLimit: 50000},
+	{Code: util.EUR, NumericCode: 978, MinorUnit: 2, Enabled: true, BeneficiaryCoolingOffLimit: 50000},
+	{Code: util.CAD, NumericCode: 124, MinorUnit: 2, Enabled: true, BeneficiaryCoolingOffLimit: 70000},
+	{Code: util.JPY, NumericCode: 392, MinorUnit: 0, Enabled: true, BeneficiaryCoolingOffLimit: 75000},
+	{Code: util.KWD, NumericCode: 414, MinorUnit: 3, Enabled: true, BeneficiaryCoolingOffLimit: 150000},
+}
+
 func TestMain(m *testing.M) {
 
 	gin.SetMode(gin.TestMode)
@@ -1,11 +1,13 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
 	"github.com/badermezzi/KubeGoBank/token"
 	"github.com/gin-gonic/gin"
 )
@@ -14,9 +16,14 @@ const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
 	authorizationPayloadKey = "authorization_payload"
+	authorizedAccountKey    = "authorized_account"
+
+	authorizedScheduledTransferKey = "authorized_scheduled_transfer"
+
+	authorizedBeneficiaryKey = "authorized_beneficiary"
 )
 
-func authmiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+func authmiddleware(tokenMaker token.Maker, revocations *revocationCache) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		authorizationHeader := context.GetHeader(authorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
@@ -47,7 +54,166 @@ func authmiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 			return
 		}
 
+		// A refresh token lives much longer than an access token, it must only ever renew one.
+		if payload.Type != token.TokenTypeAccessToken {
+			err := errors.New("token is not an access token")
+			context.AbortWithStatusJSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
+		if revocations.isRevoked(payload) {
+			err := errors.New("token has been revoked")
+			context.AbortWithStatusJSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
 		context.Set(authorizationPayloadKey, payload)
 		context.Next()
 	}
 }
+
+// authorizeRoles only lets the request through if the authenticated user has one of the given roles.
+// It must run after authmiddleware.
+func authorizeRoles(roles ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+		if !hasRole(authPayload, roles...) {
+			err := fmt.Errorf("role %s is not allowed to access this route", authPayload.Role)
+			context.AbortWithStatusJSON(http.StatusForbidden, errorResponce(err))
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// hasRole reports whether the token owner has one of the given roles.
+func hasRole(payload *token.Payload, roles ...string) bool {
+	for _, role := range roles {
+		if payload.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+type accountURIRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// authorizeAccount loads the account named by the :id route parameter and only lets the request through
+// if the account belongs to the authenticated user or the user has one of the given roles.
+// The account is stored in the context under authorizedAccountKey. It must run after authmiddleware.
+func authorizeAccount(store db.Store, roles ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var req accountURIRequest
+		err := context.ShouldBindUri(&req)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+
+		account, err := store.GetAccount(context, req.ID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				context.AbortWithStatusJSON(http.StatusNotFound, errorResponce(err))
+				return
+			}
+
+			context.AbortWithStatusJSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+
+		authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+		if account.Owner != authPayload.Username && !hasRole(authPayload, roles...) {
+			err := errors.New("account doesn't belong to the authenticated user")
+			context.AbortWithStatusJSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
+		context.Set(authorizedAccountKey, account)
+		context.Next()
+	}
+}
+
+type scheduledTransferURIRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// authorizeScheduledTransfer loads the scheduled transfer named by the :id route parameter and only lets the request
+// through if the schedule belongs to the authenticated user or the user has one of the given roles.
+// The schedule is stored in the context under authorizedScheduledTransferKey. It must run after authmiddleware.
+func authorizeScheduledTransfer(store db.Store, roles ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var req scheduledTransferURIRequest
+		err := context.ShouldBindUri(&req)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+
+		schedule, err := store.GetScheduledTransfer(context, req.ID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				context.AbortWithStatusJSON(http.StatusNotFound, errorResponce(err))
+				return
+			}
+
+			context.AbortWithStatusJSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+
+		authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+		if schedule.Owner != authPayload.Username && !hasRole(authPayload, roles...) {
+			err := errors.New("scheduled transfer doesn't belong to the authenticated user")
+			context.AbortWithStatusJSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
+		context.Set(authorizedScheduledTransferKey, schedule)
+		context.Next()
+	}
+}
+
+type beneficiaryURIRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// authorizeBeneficiary loads the beneficiary named by the :id route parameter and only lets the request
+// through if it was saved by the authenticated user. Beneficiaries are private to their owner, whatever the role.
+// The beneficiary is stored in the context under authorizedBeneficiaryKey. It must run after authmiddleware.
+func authorizeBeneficiary(store db.Store) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var req beneficiaryURIRequest
+		err := context.ShouldBindUri(&req)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+
+		beneficiary, err := store.GetBeneficiary(context, req.ID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				context.AbortWithStatusJSON(http.StatusNotFound, errorResponce(err))
+				return
+			}
+
+			context.AbortWithStatusJSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+
+		authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+		if beneficiary.Owner != authPayload.Username {
+			err := errors.New("beneficiary doesn't belong to the authenticated user")
+			context.AbortWithStatusJSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
+		context.Set(authorizedBeneficiaryKey, beneficiary)
+		context.Next()
+	}
+}
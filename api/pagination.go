@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Page sizes used when DEFAULT_PAGE_SIZE or MAX_PAGE_SIZE are not set.
+const (
+	defaultPageSize    int32 = 20
+	defaultMaxPageSize int32 = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageRequest holds the query parameters shared by the list endpoints.
+type pageRequest struct {
+	Cursor   string `form:"cursor"`                              // Cursor is the next_cursor of the previous page, empty for the first page.
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1"` // PageSize defaults to DEFAULT_PAGE_SIZE and is capped by MAX_PAGE_SIZE.
+}
+
+// pageCursor is the key of the last row of a page, the next page starts right after it.
+// Rows are listed in id order, which is also their creation order, users in username order
+// and exchange rates from the newest valid_from.
+type pageCursor struct {
+	ID       int64      `json:"id,omitempty"`
+	Username string     `json:"username,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+}
+
+// encode turns the cursor into the opaque string handed to clients.
+func (cursor pageCursor) encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageCursor(value string) (pageCursor, error) {
+	var cursor pageCursor
+	if value == "" {
+		return cursor, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || cursor.ID < 0 {
+		return cursor, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+// parsePage decodes the cursor of a list request and resolves its page size against the server config.
+func (server *Server) parsePage(req pageRequest) (pageCursor, int32, error) {
+	cursor, err := decodePageCursor(req.Cursor)
+	if err != nil {
+		return cursor, 0, err
+	}
+
+	maxPageSize := server.config.MaxPageSize
+	if maxPageSize <= 0 {
+		maxPageSize = defaultMaxPageSize
+	}
+
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = server.config.DefaultPageSize
+		if pageSize <= 0 {
+			pageSize = defaultPageSize
+		}
+		pageSize = min(pageSize, maxPageSize)
+	}
+
+	if pageSize > maxPageSize {
+		return cursor, 0, fmt.Errorf("page_size must be at most %d", maxPageSize)
+	}
+	return cursor, pageSize, nil
+}
+
+// pageLimit is the number of rows to query for a page: one more than the page size,
+// so that the extra row tells whether there is a next page.
+func pageLimit(pageSize int32) int32 {
+	return pageSize + 1
+}
+
+// listResponse is the envelope returned by the list endpoints.
+type listResponse[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// newListResponse drops the extra row queried by pageLimit and, if there was one,
+// points next_cursor at the last item of the page.
+func newListResponse[T any](items []T, pageSize int32, cursorOf func(T) pageCursor) listResponse[T] {
+	response := listResponse[T]{Items: items}
+	if response.Items == nil {
+		response.Items = []T{}
+	}
+
+	if len(items) > int(pageSize) {
+		response.Items = items[:pageSize]
+		response.NextCursor = cursorOf(response.Items[pageSize-1]).encode()
+	}
+	return response
+}
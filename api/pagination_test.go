@@ -0,0 +1,73 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageCursor(t *testing.T) {
+	cursor := pageCursor{ID: util.RandomInt(1, 1000)}
+
+	decoded, err := decodePageCursor(cursor.encode())
+	require.NoError(t, err)
+	require.Equal(t, cursor, decoded)
+
+	validFrom := time.Now().UTC().Truncate(time.Second)
+	cursor = pageCursor{Time: &validFrom}
+
+	decoded, err = decodePageCursor(cursor.encode())
+	require.NoError(t, err)
+	require.Equal(t, cursor, decoded)
+
+	decoded, err = decodePageCursor("")
+	require.NoError(t, err)
+	require.Zero(t, decoded)
+
+	_, err = decodePageCursor("not a cursor")
+	require.ErrorIs(t, err, errInvalidCursor)
+
+	_, err = decodePageCursor(pageCursor{ID: -1}.encode())
+	require.ErrorIs(t, err, errInvalidCursor)
+}
+
+func TestParsePage(t *testing.T) {
+	server := &Server{config: util.Config{DefaultPageSize: 50, MaxPageSize: 200}}
+
+	_, pageSize, err := server.parsePage(pageRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(50), pageSize)
+
+	_, pageSize, err = server.parsePage(pageRequest{PageSize: 200})
+	require.NoError(t, err)
+	require.Equal(t, int32(200), pageSize)
+
+	_, _, err = server.parsePage(pageRequest{PageSize: 201})
+	require.Error(t, err)
+
+	server = &Server{}
+	_, pageSize, err = server.parsePage(pageRequest{})
+	require.NoError(t, err)
+	require.Equal(t, defaultPageSize, pageSize)
+
+	_, _, err = server.parsePage(pageRequest{PageSize: defaultMaxPageSize + 1})
+	require.Error(t, err)
+}
+
+func TestNewListResponse(t *testing.T) {
+	cursorOf := func(id int64) pageCursor { return pageCursor{ID: id} }
+
+	response := newListResponse([]int64{1, 2, 3}, 2, cursorOf)
+	require.Equal(t, []int64{1, 2}, response.Items)
+	require.Equal(t, pageCursor{ID: 2}.encode(), response.NextCursor)
+
+	response = newListResponse([]int64{1, 2}, 2, cursorOf)
+	require.Equal(t, []int64{1, 2}, response.Items)
+	require.Empty(t, response.NextCursor)
+
+	response = newListResponse[int64](nil, 2, cursorOf)
+	require.NotNil(t, response.Items)
+	require.Empty(t, response.Items)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/gin-gonic/gin"
+)
+
+// payeeRequest identifies a payee by username or email, and the currency of their account to pay into.
+type payeeRequest struct {
+	Username string `form:"username" binding:"omitempty,alphanum"`
+	Email    string `form:"email" binding:"omitempty,email"`
+	Currency string `form:"currency" binding:"required,currency"`
+}
+
+// payeeResponse lets the sender confirm who they are about to pay without learning the payee's account.
+type payeeResponse struct {
+	MaskedName string `json:"masked_name"`
+	Currency   string `json:"currency"`
+}
+
+func newPayeeResponse(payee db.GetPayeeRow) payeeResponse {
+	return payeeResponse{
+		MaskedName: maskName(payee.FullName),
+		Currency:   payee.Currency,
+	}
+}
+
+// maskName keeps the first letter of each part of a full name, e.g. "Bader Mezzi" becomes "B*** M***".
+func maskName(fullName string) string {
+	parts := strings.Fields(fullName)
+	for i, part := range parts {
+		parts[i] = string([]rune(part)[0]) + "***"
+	}
+	return strings.Join(parts, " ")
+}
+
+// getPayee looks up the account a transfer to a username or email would be paid into, and returns the masked name
+// of its owner for the sender to check before sending.
+func (server *Server) getPayee(context *gin.Context) {
+	var req payeeRequest
+
+	err := context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	payee, valid := server.validPayee(context, req.Username, req.Email, req.Currency)
+	if !valid {
+		return
+	}
+
+	context.JSON(http.StatusOK, newPayeeResponse(payee))
+}
+
+// replayedPayee rebuilds the payee of a transfer replayed from its idempotency key, without looking them up again.
+func (server *Server) replayedPayee(context *gin.Context, account db.Account) (*payeeResponse, bool) {
+	user, err := server.store.GetUser(context, account.Owner)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return nil, false
+	}
+
+	return &payeeResponse{
+		MaskedName: maskName(user.FullName),
+		Currency:   account.Currency,
+	}, true
+}
+
+// validPayee resolves a payee known by exactly one of username and email to their active account in the currency.
+// Frozen, closed and missing accounts are all reported as not found, so the lookup doesn't tell them apart.
+// Every lookup counts against the user's payee lookup limit.
+func (server *Server) validPayee(context *gin.Context, username string, email string, currency string) (db.GetPayeeRow, bool) {
+	if (username == "") == (email == "") {
+		err := errors.New("a payee needs exactly one of username and email")
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return db.GetPayeeRow{}, false
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	allowed, retryAfter := server.payees.allow(authPayload.Username, time.Now())
+	if !allowed {
+		context.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		err := errors.New("too many payee lookups, try again later")
+		context.JSON(http.StatusTooManyRequests, errorResponce(err))
+		return db.GetPayeeRow{}, false
+	}
+
+	payee, err := server.store.GetPayee(context, db.GetPayeeParams{
+		Username: sql.NullString{String: username, Valid: username != ""},
+		Email:    sql.NullString{String: email, Valid: email != ""},
+		Currency: currency,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := fmt.Errorf("no payee with a %s account", currency)
+			context.JSON(http.StatusNotFound, errorResponce(err))
+			return payee, false
+		}
+
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return payee, false
+	}
+
+	return payee, true
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// payeeLookupLimiter caps how many payees each user can look up per period, so the answers
+// can't be used to list who banks here and in which currencies. Counts are kept per server instance.
+type payeeLookupLimiter struct {
+	limit  int
+	period time.Duration
+
+	mutex   sync.Mutex
+	windows map[string]payeeLookupWindow // username -> lookups in the current period
+	sweptAt time.Time                    // last time expired windows were dropped
+}
+
+// payeeLookupWindow counts the lookups of one user since start.
+type payeeLookupWindow struct {
+	start time.Time
+	count int
+}
+
+func newPayeeLookupLimiter(limit int, period time.Duration) *payeeLookupLimiter {
+	return &payeeLookupLimiter{
+		limit:   limit,
+		period:  period,
+		windows: make(map[string]payeeLookupWindow),
+	}
+}
+
+// allow counts a lookup by the user. When the user is over the limit it returns false and how long until
+// their next lookup is allowed.
+func (limiter *payeeLookupLimiter) allow(username string, now time.Time) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if now.Sub(limiter.sweptAt) >= limiter.period {
+		for user, window := range limiter.windows {
+			if now.Sub(window.start) >= limiter.period {
+				delete(limiter.windows, user)
+			}
+		}
+		limiter.sweptAt = now
+	}
+
+	window, ok := limiter.windows[username]
+	if !ok || now.Sub(window.start) >= limiter.period {
+		window = payeeLookupWindow{start: now}
+	}
+
+	if window.count >= limiter.limit {
+		return false, window.start.Add(limiter.period).Sub(now)
+	}
+
+	window.count++
+	limiter.windows[username] = window
+	return true, 0
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayeeLookupLimiter(t *testing.T) {
+	limiter := newPayeeLookupLimiter(2, time.Minute)
+	now := time.Now()
+
+	allowed, _ := limiter.allow("alice", now)
+	require.True(t, allowed)
+	allowed, _ = limiter.allow("alice", now.Add(time.Second))
+	require.True(t, allowed)
+
+	allowed, retryAfter := limiter.allow("alice", now.Add(10*time.Second))
+	require.False(t, allowed)
+	require.Equal(t, 50*time.Second, retryAfter)
+
+	allowed, _ = limiter.allow("bob", now.Add(10*time.Second))
+	require.True(t, allowed)
+
+	// A new period starts once the previous one is over
+	allowed, _ = limiter.allow("alice", now.Add(time.Minute))
+	require.True(t, allowed)
+
+	// Expired windows are dropped
+	limiter.allow("alice", now.Add(3*time.Minute))
+	require.Len(t, limiter.windows, 1)
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskName(t *testing.T) {
+	require.Equal(t, "B*** M***", maskName("Bader Mezzi"))
+	require.Equal(t, "J*** R*** T***", maskName(" John  Ronald Tolkien "))
+	require.Equal(t, "É***", maskName("Élodie"))
+	require.Equal(t, "", maskName(""))
+}
+
+func TestGetPayeeAPI(t *testing.T) {
+	user := randomUser(t)
+	payee := randomUser(t)
+	payee.FullName = "Jane Doe"
+	account := randomAccount(payee.Username)
+	account.Currency = util.USD
+
+	testCases := []struct {
+		name          string
+		query         url.Values
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "ByUsername",
+			query: url.Values{"username": {payee.Username}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetPayeeParams{
+					Username: sql.NullString{String: payee.Username, Valid: true},
+					Currency: util.USD,
+				}
+				store.EXPECT().
+					GetPayee(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.GetPayeeRow{AccountID: account.ID, Owner: payee.Username, Currency: util.USD, FullName: payee.FullName}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response payeeResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.Equal(t, payeeResponse{MaskedName: "J*** D***", Currency: util.USD}, response)
+				require.NotContains(t, recorder.Body.String(), "account")
+			},
+		},
+		{
+			name:  "ByEmail",
+			query: url.Values{"email": {payee.Email}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetPayeeParams{
+					Email:    sql.NullString{String: payee.Email, Valid: true},
+					Currency: util.USD,
+				}
+				store.EXPECT().
+					GetPayee(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.GetPayeeRow{AccountID: account.ID, Owner: payee.Username, Currency: util.USD, FullName: payee.FullName}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "NotFound",
+			query: url.Values{"username": {payee.Username}, "currency": {util.EUR}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPayee(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetPayeeRow{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:  "UsernameAndEmail",
+			query: url.Values{"username": {payee.Username}, "email": {payee.Email}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPayee(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "NoPayee",
+			query: url.Values{"currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPayee(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidEmail",
+			query: url.Values{"email": {"not-an-email"}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPayee(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: url.Values{"username": {payee.Username}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPayee(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetPayeeRow{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/payees?"+tc.query.Encode(), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetPayeeAPITooManyLookups(t *testing.T) {
+	user := randomUser(t)
+	other := randomUser(t)
+	payee := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetPayee(gomock.Any(), gomock.Any()).
+		Times(defaultPayeeLookupLimit+1).
+		Return(db.GetPayeeRow{}, sql.ErrNoRows)
+
+	server := newTestServer(t, store)
+	query := url.Values{"username": {payee.Username}, "currency": {util.USD}}
+
+	lookup := func(username string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/payees?"+query.Encode(), nil)
+		require.NoError(t, err)
+
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, username, util.DepositorRole, time.Minute)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for i := 0; i < defaultPayeeLookupLimit; i++ {
+		require.Equal(t, http.StatusNotFound, lookup(user.Username).Code)
+	}
+
+	recorder := lookup(user.Username)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+
+	// The limit is per user
+	require.Equal(t, http.StatusNotFound, lookup(other.Username).Code)
+}
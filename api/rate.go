@@ -0,0 +1,117 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+type ratePairRequest struct {
+	Base  string `uri:"base" binding:"required,currency"`
+	Quote string `uri:"quote" binding:"required,currency,nefield=Base"`
+}
+
+// listRates returns the rate currently in effect for every pair.
+func (server *Server) listRates(context *gin.Context) {
+	rates, err := server.store.ListLatestExchangeRates(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	if rates == nil {
+		rates = []db.ExchangeRate{}
+	}
+	context.JSON(http.StatusOK, listResponse[db.ExchangeRate]{Items: rates})
+}
+
+type getRateRequest struct {
+	At time.Time `form:"at"` // At is the time the rate was in effect, RFC 3339. It defaults to now.
+}
+
+// getRate returns the rate of a pair in effect at a given time.
+func (server *Server) getRate(context *gin.Context) {
+	var pair ratePairRequest
+	err := context.ShouldBindUri(&pair)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	var req getRateRequest
+	err = context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	if req.At.IsZero() {
+		req.At = time.Now()
+	}
+
+	rate, err := server.store.GetExchangeRate(context, db.GetExchangeRateParams{
+		BaseCurrency:  pair.Base,
+		QuoteCurrency: pair.Quote,
+		At:            req.At,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			context.JSON(http.StatusNotFound, errorResponce(err))
+			return
+		}
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, rate)
+}
+
+type listRateHistoryRequest struct {
+	pageRequest
+}
+
+// listRateHistory returns the rates of a pair, newest first.
+func (server *Server) listRateHistory(context *gin.Context) {
+	var pair ratePairRequest
+	err := context.ShouldBindUri(&pair)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	var req listRateHistoryRequest
+	err = context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	arg := db.ListExchangeRateHistoryParams{
+		BaseCurrency:  pair.Base,
+		QuoteCurrency: pair.Quote,
+		PageLimit:     pageLimit(pageSize),
+	}
+	if cursor.Time != nil {
+		arg.Before = sql.NullTime{Time: *cursor.Time, Valid: true}
+	}
+
+	rates, err := server.store.ListExchangeRateHistory(context, arg)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, newListResponse(rates, pageSize, func(rate db.ExchangeRate) pageCursor {
+		return pageCursor{Time: &rate.ValidFrom}
+	}))
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func randomExchangeRate(base string, quote string, validFrom time.Time) db.ExchangeRate {
+	return db.ExchangeRate{
+		ID:            util.RandomInt(1, 1000),
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          "0.92",
+		ValidFrom:     validFrom,
+		Source:        "static",
+		CreatedAt:     validFrom,
+	}
+}
+
+func requireBodyMatchExchangeRates(t *testing.T, recorder *httptest.ResponseRecorder, rates []db.ExchangeRate) string {
+	var response listResponse[db.ExchangeRate]
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Equal(t, rates, response.Items)
+	return response.NextCursor
+}
+
+func TestListRatesAPI(t *testing.T) {
+	user := randomUser(t)
+	validFrom := time.Now().UTC().Truncate(time.Second)
+	rates := []db.ExchangeRate{
+		randomExchangeRate(util.EUR, util.USD, validFrom),
+		randomExchangeRate(util.USD, util.EUR, validFrom),
+	}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListLatestExchangeRates(gomock.Any()).Times(1).Return(rates, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchExchangeRates(t, recorder, rates)
+			},
+		},
+		{
+			name: "NoRates",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListLatestExchangeRates(gomock.Any()).Times(1).Return(nil, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchExchangeRates(t, recorder, []db.ExchangeRate{})
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListLatestExchangeRates(gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/rates", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestGetRateAPI(t *testing.T) {
+	user := randomUser(t)
+	at := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	rate := randomExchangeRate(util.USD, util.EUR, at.Add(-time.Minute))
+
+	testCases := []struct {
+		name          string
+		path          string
+		query         url.Values
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			path:  "/rates/USD/EUR",
+			query: url.Values{"at": {at.Format(time.RFC3339)}},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.GetExchangeRateParams{
+					BaseCurrency:  util.USD,
+					QuoteCurrency: util.EUR,
+					At:            at,
+				}
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Eq(arg)).Times(1).Return(rate, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got db.ExchangeRate
+				err := json.Unmarshal(recorder.Body.Bytes(), &got)
+				require.NoError(t, err)
+				require.Equal(t, rate, got)
+			},
+		},
+		{
+			name: "DefaultsToNow",
+			path: "/rates/USD/EUR",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.GetExchangeRateParams) (db.ExchangeRate, error) {
+						require.WithinDuration(t, time.Now(), arg.At, time.Second)
+						return rate, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			path: "/rates/USD/EUR",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Any()).Times(1).Return(db.ExchangeRate{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			path: "/rates/USD/EUR",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Any()).Times(1).Return(db.ExchangeRate{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidCurrency",
+			path: "/rates/USD/XYZ",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "SameCurrency",
+			path: "/rates/USD/USD",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidTime",
+			path:  "/rates/USD/EUR",
+			query: url.Values{"at": {"yesterday"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, tc.path+"?"+tc.query.Encode(), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListRateHistoryAPI(t *testing.T) {
+	user := randomUser(t)
+	validFrom := time.Now().UTC().Truncate(time.Second)
+	rates := []db.ExchangeRate{
+		randomExchangeRate(util.USD, util.EUR, validFrom),
+		randomExchangeRate(util.USD, util.EUR, validFrom.Add(-time.Hour)),
+		randomExchangeRate(util.USD, util.EUR, validFrom.Add(-2*time.Hour)),
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	listHistory := func(query url.Values) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/rates/USD/EUR/history?"+query.Encode(), nil)
+		require.NoError(t, err)
+
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	store.EXPECT().
+		ListExchangeRateHistory(gomock.Any(), gomock.Eq(db.ListExchangeRateHistoryParams{
+			BaseCurrency:  util.USD,
+			QuoteCurrency: util.EUR,
+			PageLimit:     3,
+		})).
+		Times(1).
+		Return(rates, nil)
+
+	recorder := listHistory(url.Values{"page_size": {"2"}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	nextCursor := requireBodyMatchExchangeRates(t, recorder, rates[:2])
+	require.NotEmpty(t, nextCursor)
+
+	// The next page starts before the valid_from of the last rate
+	store.EXPECT().
+		ListExchangeRateHistory(gomock.Any(), gomock.Eq(db.ListExchangeRateHistoryParams{
+			BaseCurrency:  util.USD,
+			QuoteCurrency: util.EUR,
+			Before:        sql.NullTime{Time: rates[1].ValidFrom, Valid: true},
+			PageLimit:     3,
+		})).
+		Times(1).
+		Return(rates[2:], nil)
+
+	recorder = listHistory(url.Values{"page_size": {"2"}, "cursor": {nextCursor}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	nextCursor = requireBodyMatchExchangeRates(t, recorder, rates[2:])
+	require.Empty(t, nextCursor)
+
+	recorder = listHistory(url.Values{"cursor": {"not a cursor"}})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	store.EXPECT().ListExchangeRateHistory(gomock.Any(), gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+	recorder = listHistory(url.Values{})
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
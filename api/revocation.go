@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/google/uuid"
+)
+
+// revocationSyncOverlap is how far back each sync looks past the last seen revocation,
+// so rows committed late by a slow transaction are still picked up.
+const revocationSyncOverlap = time.Minute
+
+// revocationCache keeps revoked token IDs and per-user token cutoffs in memory.
+// Revocations are written to Postgres and mirrored here, and a background sync pulls in
+// revocations made by other server instances, so checking a token never hits the database.
+type revocationCache struct {
+	store db.Store
+
+	mutex    sync.RWMutex
+	tokens   map[uuid.UUID]time.Time // revoked token ID -> token expiry
+	users    map[string]time.Time    // username -> tokens issued before this time are revoked
+	syncedAt time.Time               // latest revoked_at loaded from the database
+}
+
+func newRevocationCache(store db.Store) *revocationCache {
+	return &revocationCache{
+		store:  store,
+		tokens: make(map[uuid.UUID]time.Time),
+		users:  make(map[string]time.Time),
+	}
+}
+
+// isRevoked reports whether the token was revoked on its own or by a "log out everywhere".
+func (cache *revocationCache) isRevoked(payload *token.Payload) bool {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	if _, ok := cache.tokens[payload.ID]; ok {
+		return true
+	}
+
+	cutoff, ok := cache.users[payload.Username]
+	return ok && payload.IssuedAt.Before(cutoff)
+}
+
+// revokeToken revokes a single token until it expires.
+func (cache *revocationCache) revokeToken(ctx context.Context, payload *token.Payload) error {
+	err := cache.store.RevokeToken(ctx, db.RevokeTokenParams{
+		ID:        payload.ID,
+		Username:  payload.Username,
+		ExpiresAt: payload.ExpiredAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	cache.mutex.Lock()
+	cache.tokens[payload.ID] = payload.ExpiredAt
+	cache.mutex.Unlock()
+	return nil
+}
+
+// revokeUser revokes every token issued to the user so far and blocks all of their sessions.
+func (cache *revocationCache) revokeUser(ctx context.Context, username string) error {
+	user, err := cache.store.RevokeUserTokensTx(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	cache.mutex.Lock()
+	cache.setUserCutoff(user.Username, user.TokensRevokedAt)
+	cache.mutex.Unlock()
+	return nil
+}
+
+// setUserCutoff only ever moves a user's cutoff forward. The caller must hold the write lock.
+func (cache *revocationCache) setUserCutoff(username string, cutoff time.Time) {
+	if cutoff.After(cache.users[username]) {
+		cache.users[username] = cutoff
+	}
+}
+
+// sync loads revocations recorded since the last sync and drops tokens that have expired.
+func (cache *revocationCache) sync(ctx context.Context) error {
+	cache.mutex.RLock()
+	since := cache.syncedAt
+	cache.mutex.RUnlock()
+
+	if !since.IsZero() {
+		since = since.Add(-revocationSyncOverlap)
+	}
+
+	tokens, err := cache.store.ListRevokedTokensSince(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	users, err := cache.store.ListUserTokenRevocationsSince(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for _, revoked := range tokens {
+		cache.tokens[revoked.ID] = revoked.ExpiresAt
+		if revoked.RevokedAt.After(cache.syncedAt) {
+			cache.syncedAt = revoked.RevokedAt
+		}
+	}
+
+	for _, user := range users {
+		cache.setUserCutoff(user.Username, user.TokensRevokedAt)
+		if user.TokensRevokedAt.After(cache.syncedAt) {
+			cache.syncedAt = user.TokensRevokedAt
+		}
+	}
+
+	now := time.Now()
+	for id, expiresAt := range cache.tokens {
+		if now.After(expiresAt) {
+			delete(cache.tokens, id) // An expired token is rejected by VerifyToken anyway
+		}
+	}
+
+	return nil
+}
+
+// run syncs the cache every interval until the context is cancelled. The first sync is done by Start.
+func (cache *revocationCache) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := cache.sync(ctx)
+		if err != nil {
+			log.Println("cannot sync token revocations:", err)
+		}
+	}
+}
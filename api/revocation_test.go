@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationCacheSync(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	cache := newRevocationCache(store)
+
+	now := time.Now()
+	revokedToken := db.RevokedToken{
+		ID:        uuid.New(),
+		Username:  util.RandomOwner(),
+		ExpiresAt: now.Add(time.Hour),
+		RevokedAt: now.Add(-time.Minute),
+	}
+	expiredToken := db.RevokedToken{
+		ID:        uuid.New(),
+		Username:  util.RandomOwner(),
+		ExpiresAt: now.Add(-time.Second),
+		RevokedAt: now.Add(-2 * time.Minute),
+	}
+	revokedUser := db.ListUserTokenRevocationsSinceRow{
+		Username:        util.RandomOwner(),
+		TokensRevokedAt: now,
+	}
+
+	// The first sync loads everything.
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Eq(time.Time{})).
+		Times(1).
+		Return([]db.RevokedToken{expiredToken, revokedToken}, nil)
+	store.EXPECT().
+		ListUserTokenRevocationsSince(gomock.Any(), gomock.Eq(time.Time{})).
+		Times(1).
+		Return([]db.ListUserTokenRevocationsSinceRow{revokedUser}, nil)
+
+	err := cache.sync(context.Background())
+	require.NoError(t, err)
+
+	require.True(t, cache.isRevoked(&token.Payload{ID: revokedToken.ID, Username: revokedToken.Username, IssuedAt: now}))
+	require.NotContains(t, cache.tokens, expiredToken.ID)
+	require.True(t, cache.isRevoked(&token.Payload{ID: uuid.New(), Username: revokedUser.Username, IssuedAt: now.Add(-time.Second)}))
+	require.False(t, cache.isRevoked(&token.Payload{ID: uuid.New(), Username: revokedUser.Username, IssuedAt: now.Add(time.Second)}))
+	require.Equal(t, now, cache.syncedAt)
+
+	// Later syncs only ask for revocations after the newest one seen, with some overlap.
+	since := now.Add(-revocationSyncOverlap)
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Eq(since)).
+		Times(1).
+		Return([]db.RevokedToken{}, nil)
+	store.EXPECT().
+		ListUserTokenRevocationsSince(gomock.Any(), gomock.Eq(since)).
+		Times(1).
+		Return([]db.ListUserTokenRevocationsSinceRow{}, nil)
+
+	err = cache.sync(context.Background())
+	require.NoError(t, err)
+	require.True(t, cache.isRevoked(&token.Payload{ID: revokedToken.ID, Username: revokedToken.Username, IssuedAt: now}))
+}
+
+func TestRevocationCacheSyncError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	cache := newRevocationCache(store)
+
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil, sql.ErrConnDone)
+	store.EXPECT().
+		ListUserTokenRevocationsSince(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	err := cache.sync(context.Background())
+	require.ErrorIs(t, err, sql.ErrConnDone)
+	require.True(t, cache.syncedAt.IsZero())
+}
+
+func TestStartRevocationSyncError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	store.EXPECT().ListCurrencies(gomock.Any()).Times(1).Return(testCurrencies, nil)
+	store.EXPECT().
+		ListRevokedTokensSince(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil, sql.ErrConnDone)
+
+	// The server doesn't serve until revoked tokens are known
+	err := server.Start("localhost:0")
+	require.ErrorIs(t, err, sql.ErrConnDone)
+}
@@ -0,0 +1,368 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/gin-gonic/gin"
+)
+
+type createScheduledTransferRequest struct {
+	FromAccountID int64      `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64      `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        util.Money `json:"amount" binding:"money,positive_money"` // Amount must be in the from account's currency
+	Description   string     `json:"description" binding:"max=140"`
+	Frequency     string     `json:"frequency" binding:"required,oneof=once daily weekly monthly"`
+	StartAt       time.Time  `json:"start_at" binding:"required"` // StartAt is the first run, later runs keep its time of day.
+	EndAt         *time.Time `json:"end_at"`                      // EndAt, if set, is the last time a run may be due.
+	MaxRuns       *int32     `json:"max_runs" binding:"omitempty,min=1"`
+	// InsufficientFundsPolicy says what happens to a run the from account can't pay: skip it, the default,
+	// or retry it up to MaxRetries times.
+	InsufficientFundsPolicy string `json:"insufficient_funds_policy" binding:"omitempty,oneof=skip retry"`
+	MaxRetries              int32  `json:"max_retries" binding:"min=0,max=10"`
+}
+
+// scheduledTransferResponse is a scheduled transfer with its amount also written as a decimal.
+type scheduledTransferResponse struct {
+	ID                      int64      `json:"id"`
+	FromAccountID           int64      `json:"from_account_id"`
+	ToAccountID             int64      `json:"to_account_id"`
+	Amount                  util.Money `json:"amount"`
+	AmountDecimal           string     `json:"amount_decimal,omitempty"`
+	Description             string     `json:"description"`
+	Frequency               string     `json:"frequency"`
+	StartAt                 time.Time  `json:"start_at"`
+	EndAt                   *time.Time `json:"end_at,omitempty"`
+	MaxRuns                 *int32     `json:"max_runs,omitempty"`
+	InsufficientFundsPolicy string     `json:"insufficient_funds_policy"`
+	MaxRetries              int32      `json:"max_retries"`
+	Status                  string     `json:"status"`
+	Runs                    int32      `json:"runs"`
+	NextRunAt               *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt               time.Time  `json:"created_at"`
+}
+
+func (server *Server) newScheduledTransferResponse(schedule db.ScheduledTransfer) scheduledTransferResponse {
+	response := scheduledTransferResponse{
+		ID:                      schedule.ID,
+		FromAccountID:           schedule.FromAccountID,
+		ToAccountID:             schedule.ToAccountID,
+		Amount:                  util.NewMoney(schedule.Amount, schedule.Currency),
+		AmountDecimal:           server.currencies.format(schedule.Amount, schedule.Currency),
+		Description:             schedule.Description,
+		Frequency:               schedule.Frequency,
+		StartAt:                 schedule.StartAt,
+		InsufficientFundsPolicy: schedule.InsufficientFundsPolicy,
+		MaxRetries:              schedule.MaxRetries,
+		Status:                  schedule.Status,
+		Runs:                    schedule.Runs,
+		CreatedAt:               schedule.CreatedAt,
+	}
+	if schedule.EndAt.Valid {
+		response.EndAt = &schedule.EndAt.Time
+	}
+	if schedule.MaxRuns.Valid {
+		response.MaxRuns = &schedule.MaxRuns.Int32
+	}
+	if schedule.NextRunAt.Valid {
+		response.NextRunAt = &schedule.NextRunAt.Time
+	}
+	return response
+}
+
+// createScheduledTransfer schedules a transfer from one of the authenticated user's accounts.
+// The executor makes each run through TransferTx, so the accounts are checked again when a run is due.
+func (server *Server) createScheduledTransfer(context *gin.Context) {
+	var req createScheduledTransferRequest
+
+	err := context.ShouldBindJSON(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	err = validScheduleWindow(req.StartAt, req.EndAt, req.InsufficientFundsPolicy, req.MaxRetries)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	if req.StartAt.Before(time.Now()) {
+		err := errors.New("start_at must not be in the past")
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	fromAccount, valid := server.validAccount(context, req.FromAccountID, req.Amount.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if fromAccount.Owner != authPayload.Username {
+		err := errors.New("from account doesn't belong to the authenticated user")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	// The to account may hold another currency, each run is then converted at the rate of the day.
+	_, valid = server.validToAccount(context, req.ToAccountID, "")
+	if !valid {
+		return
+	}
+
+	// The executor checks the cap again when each run is due
+	if !server.checkCoolingOff(context, authPayload.Username, req.ToAccountID, req.Amount, req.StartAt) {
+		return
+	}
+
+	arg := db.CreateScheduledTransferParams{
+		Owner:                   authPayload.Username,
+		FromAccountID:           req.FromAccountID,
+		ToAccountID:             req.ToAccountID,
+		Amount:                  req.Amount.Amount,
+		Currency:                req.Amount.Currency,
+		Description:             req.Description,
+		Frequency:               req.Frequency,
+		StartAt:                 req.StartAt,
+		InsufficientFundsPolicy: req.InsufficientFundsPolicy,
+		MaxRetries:              req.MaxRetries,
+	}
+	if arg.InsufficientFundsPolicy == "" {
+		arg.InsufficientFundsPolicy = db.InsufficientFundsPolicySkip
+	}
+	if req.EndAt != nil {
+		arg.EndAt = sql.NullTime{Time: *req.EndAt, Valid: true}
+	}
+	if req.MaxRuns != nil {
+		arg.MaxRuns = sql.NullInt32{Int32: *req.MaxRuns, Valid: true}
+	}
+
+	schedule, err := server.store.CreateScheduledTransfer(context, arg)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, server.newScheduledTransferResponse(schedule))
+}
+
+// validScheduleWindow checks the fields of a schedule that depend on each other.
+func validScheduleWindow(startAt time.Time, endAt *time.Time, policy string, maxRetries int32) error {
+	if endAt != nil && !endAt.After(startAt) {
+		return errors.New("end_at must be after start_at")
+	}
+	if maxRetries > 0 && policy != db.InsufficientFundsPolicyRetry {
+		return fmt.Errorf("max_retries needs insufficient_funds_policy %s", db.InsufficientFundsPolicyRetry)
+	}
+	return nil
+}
+
+type listScheduledTransfersRequest struct {
+	pageRequest
+}
+
+// listScheduledTransfers returns the scheduled transfers of the authenticated user, whatever their status.
+func (server *Server) listScheduledTransfers(context *gin.Context) {
+	var req listScheduledTransfersRequest
+
+	err := context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	arg := db.ListScheduledTransfersParams{
+		Owner:     authPayload.Username,
+		AfterID:   cursor.ID,
+		PageLimit: pageLimit(pageSize),
+	}
+
+	schedules, err := server.store.ListScheduledTransfers(context, arg)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	response := make([]scheduledTransferResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		response = append(response, server.newScheduledTransferResponse(schedule))
+	}
+
+	context.JSON(http.StatusOK, newListResponse(response, pageSize, func(schedule scheduledTransferResponse) pageCursor {
+		return pageCursor{ID: schedule.ID}
+	}))
+}
+
+// getScheduledTransfer returns the scheduled transfer loaded by authorizeScheduledTransfer.
+func (server *Server) getScheduledTransfer(context *gin.Context) {
+	schedule := context.MustGet(authorizedScheduledTransferKey).(db.ScheduledTransfer)
+
+	context.JSON(http.StatusOK, server.newScheduledTransferResponse(schedule))
+}
+
+// updateScheduledTransferRequest lists what an owner may change on an active schedule.
+// The accounts, frequency and start_at fix when and between whom runs happen, so they are not part of it.
+type updateScheduledTransferRequest struct {
+	Amount                  *util.Money `json:"amount" binding:"omitempty,money,positive_money"`
+	Description             *string     `json:"description" binding:"omitempty,max=140"`
+	EndAt                   *time.Time  `json:"end_at"`
+	MaxRuns                 *int32      `json:"max_runs" binding:"omitempty,min=1"`
+	InsufficientFundsPolicy *string     `json:"insufficient_funds_policy" binding:"omitempty,oneof=skip retry"`
+	MaxRetries              *int32      `json:"max_retries" binding:"omitempty,min=0,max=10"`
+}
+
+func (server *Server) updateScheduledTransfer(context *gin.Context) {
+	var req updateScheduledTransferRequest
+
+	err := context.ShouldBindJSON(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	schedule := context.MustGet(authorizedScheduledTransferKey).(db.ScheduledTransfer)
+
+	if req.Amount != nil && req.Amount.Currency != schedule.Currency {
+		err := fmt.Errorf("scheduled transfer [%d] currency mismatch: %s vs %s", schedule.ID, schedule.Currency, req.Amount.Currency)
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	// The checks apply to the schedule as it will be after the update
+	policy := schedule.InsufficientFundsPolicy
+	if req.InsufficientFundsPolicy != nil {
+		policy = *req.InsufficientFundsPolicy
+	}
+	maxRetries := schedule.MaxRetries
+	if req.MaxRetries != nil {
+		maxRetries = *req.MaxRetries
+	}
+	err = validScheduleWindow(schedule.StartAt, req.EndAt, policy, maxRetries)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	if req.Amount != nil {
+		at := time.Now()
+		if schedule.NextRunAt.Valid {
+			at = schedule.NextRunAt.Time
+		}
+
+		if !server.checkCoolingOff(context, schedule.Owner, schedule.ToAccountID, *req.Amount, at) {
+			return
+		}
+	}
+
+	arg := db.UpdateScheduledTransferParams{
+		ID: schedule.ID,
+	}
+	if req.Amount != nil {
+		arg.Amount = sql.NullInt64{Int64: req.Amount.Amount, Valid: true}
+	}
+	if req.Description != nil {
+		arg.Description = sql.NullString{String: *req.Description, Valid: true}
+	}
+	if req.EndAt != nil {
+		arg.EndAt = sql.NullTime{Time: *req.EndAt, Valid: true}
+	}
+	if req.MaxRuns != nil {
+		arg.MaxRuns = sql.NullInt32{Int32: *req.MaxRuns, Valid: true}
+	}
+	if req.InsufficientFundsPolicy != nil {
+		arg.InsufficientFundsPolicy = sql.NullString{String: *req.InsufficientFundsPolicy, Valid: true}
+	}
+	if req.MaxRetries != nil {
+		arg.MaxRetries = sql.NullInt32{Int32: *req.MaxRetries, Valid: true}
+	}
+
+	schedule, err = server.store.UpdateScheduledTransfer(context, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := fmt.Errorf("scheduled transfer [%d] is no longer active", arg.ID)
+			context.JSON(http.StatusConflict, errorResponce(err))
+			return
+		}
+
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, server.newScheduledTransferResponse(schedule))
+}
+
+// cancelScheduledTransfer stops the scheduled transfer loaded by authorizeScheduledTransfer.
+// The schedule and its executions stay readable.
+func (server *Server) cancelScheduledTransfer(context *gin.Context) {
+	schedule := context.MustGet(authorizedScheduledTransferKey).(db.ScheduledTransfer)
+
+	schedule, err := server.store.CancelScheduledTransfer(context, schedule.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := errors.New("scheduled transfer is no longer active")
+			context.JSON(http.StatusConflict, errorResponce(err))
+			return
+		}
+
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, server.newScheduledTransferResponse(schedule))
+}
+
+type listScheduledTransferExecutionsRequest struct {
+	pageRequest
+}
+
+// listScheduledTransferExecutions returns every attempt the executor made at runs of the scheduled transfer
+// loaded by authorizeScheduledTransfer, with its outcome.
+func (server *Server) listScheduledTransferExecutions(context *gin.Context) {
+	var req listScheduledTransferExecutionsRequest
+
+	err := context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	schedule := context.MustGet(authorizedScheduledTransferKey).(db.ScheduledTransfer)
+
+	arg := db.ListScheduledTransferExecutionsParams{
+		ScheduledTransferID: schedule.ID,
+		AfterID:             cursor.ID,
+		PageLimit:           pageLimit(pageSize),
+	}
+
+	executions, err := server.store.ListScheduledTransferExecutions(context, arg)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, newListResponse(executions, pageSize, func(execution db.ScheduledTransferExecution) pageCursor {
+		return pageCursor{ID: execution.ID}
+	}))
+}
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/rates"
+	"github.com/badermezzi/KubeGoBank/scheduler"
 	"github.com/badermezzi/KubeGoBank/token"
 	"github.com/badermezzi/KubeGoBank/util"
 	"github.com/gin-gonic/gin"
@@ -11,28 +15,83 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
+// defaultRevocationSyncPeriod is used when REVOCATION_SYNC_PERIOD is not set.
+const defaultRevocationSyncPeriod = 10 * time.Second
+
+// defaultRatesRefreshPeriod is used when RATES_REFRESH_PERIOD is not set.
+const defaultRatesRefreshPeriod = time.Minute
+
+// defaultCurrencySyncPeriod is used when CURRENCY_SYNC_PERIOD is not set.
+const defaultCurrencySyncPeriod = time.Minute
+
+// defaultSchedulerPeriod is used when SCHEDULER_PERIOD is not set.
+const defaultSchedulerPeriod = time.Minute
+
+// defaultSchedulerRetryPeriod is used when SCHEDULER_RETRY_PERIOD is not set.
+const defaultSchedulerRetryPeriod = time.Hour
+
+// defaultPayeeLookupLimit is used when PAYEE_LOOKUP_LIMIT is not set.
+const defaultPayeeLookupLimit = 20
+
+// defaultPayeeLookupPeriod is used when PAYEE_LOOKUP_PERIOD is not set.
+const defaultPayeeLookupPeriod = time.Minute
+
 type Server struct {
-	config     util.Config
-	store      db.Store
-	tokenMaker token.Maker
-	router     *gin.Engine
+	config      util.Config
+	store       db.Store
+	tokenMaker  token.Maker
+	revocations *revocationCache
+	currencies  *currencyRegistry
+	rates       *rates.Refresher
+	scheduler   *scheduler.Executor
+	payees      *payeeLookupLimiter
+	router      *gin.Engine
 }
 
 func NewServer(config util.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
 
+	rateProvider, err := rates.NewProvider(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create rate provider: %w", err)
+	}
+
+	retryPeriod := config.SchedulerRetryPeriod
+	if retryPeriod <= 0 {
+		retryPeriod = defaultSchedulerRetryPeriod
+	}
+
+	payeeLookupLimit := config.PayeeLookupLimit
+	if payeeLookupLimit <= 0 {
+		payeeLookupLimit = defaultPayeeLookupLimit
+	}
+
+	payeeLookupPeriod := config.PayeeLookupPeriod
+	if payeeLookupPeriod <= 0 {
+		payeeLookupPeriod = defaultPayeeLookupPeriod
+	}
+
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:      config,
+		store:       store,
+		tokenMaker:  tokenMaker,
+		revocations: newRevocationCache(store),
+		currencies:  newCurrencyRegistry(store),
+		rates:       rates.NewRefresher(rateProvider, store),
+		scheduler:   scheduler.NewExecutor(store, retryPeriod, beneficiaryCoolingOffPeriod(config)),
+		payees:      newPayeeLookupLimiter(payeeLookupLimit, payeeLookupPeriod),
 	}
 
 	v, ok := binding.Validator.Engine().(*validator.Validate)
 	if ok {
-		v.RegisterValidation("currency", validCurrency)
+		v.RegisterValidation("currency", server.currencies.validCurrency)
+		v.RegisterValidation("money", server.currencies.validMoney)
+		v.RegisterValidation("positive_money", validPositiveMoney)
+		v.RegisterValidation("nonzero_money", validNonZeroMoney)
+		v.RegisterValidation("adjustment_reason", validAdjustmentReason)
 	}
 
 	server.setupRouter()
@@ -41,27 +100,121 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 
 }
 
+// newTokenMaker picks the token.Maker implementation named by TOKEN_TYPE.
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	symmetricKeys := config.SymmetricKeys()
+
+	switch config.TokenType {
+	case "", "paseto":
+		return token.NewPasetoMaker(symmetricKeys[0], symmetricKeys[1:]...)
+	case "paseto_public":
+		return token.NewPublicPasetoMakerFromFiles(config.TokenPrivateKeyFile, config.TokenPublicKeyFiles...)
+	case "jwt":
+		switch config.TokenJWTAlgorithm {
+		case "", token.JWTAlgorithmHS256:
+			return token.NewJWTMaker(symmetricKeys[0])
+		default:
+			return token.NewAsymmetricJWTMakerFromFile(config.TokenJWTAlgorithm, config.TokenPrivateKeyFile)
+		}
+	}
+	return nil, fmt.Errorf("unsupported token type %q", config.TokenType)
+}
+
 func (server *Server) setupRouter() {
 	router := gin.Default()
 
 	router.POST("/users", server.createUser)
 	router.POST("/users/login", server.loginUser)
+	router.POST("/tokens/renew_access", server.renewAccessToken)
+	router.GET("/tokens/keys", server.listTokenKeys)
+
+	authRoutes := router.Group("/").Use(authmiddleware(server.tokenMaker, server.revocations))
 
-	authRoutes := router.Group("/").Use(authmiddleware(server.tokenMaker))
+	authRoutes.POST("/users/logout", server.logoutUser)
+	authRoutes.POST("/users/logout_all", server.logoutAllUser)
+	authRoutes.GET("/users", authorizeRoles(util.BankerRole), server.listUsers)
 
 	authRoutes.POST("/accounts", server.createAccount)
-	authRoutes.GET("/accounts/:id", server.getAccount)
 	authRoutes.GET("/accounts", server.listAccount)
-	authRoutes.DELETE("/accounts/:id", server.deleteAccount)
-	authRoutes.PATCH("/accounts/:id", server.updateAccount)
-
+	authRoutes.GET("/accounts/:id", authorizeAccount(server.store, util.BankerRole), server.getAccount)
+	authRoutes.DELETE("/accounts/:id", authorizeAccount(server.store), server.deleteAccount)
+	authRoutes.PATCH("/accounts/:id", authorizeAccount(server.store), server.updateAccount)
+	authRoutes.POST("/accounts/:id/close", authorizeAccount(server.store), server.closeAccount)
+	authRoutes.GET("/accounts/:id/entries", authorizeAccount(server.store, util.BankerRole), server.listEntries)
+	authRoutes.POST("/accounts/:id/freeze", authorizeRoles(util.BankerRole), authorizeAccount(server.store, util.BankerRole), server.freezeAccount)
+	authRoutes.POST("/accounts/:id/unfreeze", authorizeRoles(util.BankerRole), authorizeAccount(server.store, util.BankerRole), server.unfreezeAccount)
+	authRoutes.POST("/accounts/:id/adjustments", authorizeRoles(util.BankerRole), authorizeAccount(server.store, util.BankerRole), server.adjustBalance)
+	authRoutes.PUT("/accounts/:id/overdraft_limit", authorizeRoles(util.BankerRole), authorizeAccount(server.store, util.BankerRole), server.setOverdraftLimit)
+	authRoutes.GET("/accounts/:id/overdraft_limit/changes", authorizeRoles(util.BankerRole), authorizeAccount(server.store, util.BankerRole), server.listOverdraftLimitChanges)
+
+	authRoutes.POST("/beneficiaries", server.createBeneficiary)
+	authRoutes.GET("/beneficiaries", server.listBeneficiaries)
+	authRoutes.GET("/beneficiaries/:id", authorizeBeneficiary(server.store), server.getBeneficiary)
+	authRoutes.PATCH("/beneficiaries/:id", authorizeBeneficiary(server.store), server.updateBeneficiary)
+	authRoutes.DELETE("/beneficiaries/:id", authorizeBeneficiary(server.store), server.deleteBeneficiary)
+
+	authRoutes.GET("/payees", server.getPayee)
 	authRoutes.POST("/transfers", server.createTransfer)
+	authRoutes.GET("/transfers", server.listTransfers)
+	authRoutes.GET("/transfers/:id", server.getTransfer)
+	authRoutes.POST("/transfers/:id/refund", server.refundTransfer)
+	authRoutes.POST("/transfers/:id/reverse", authorizeRoles(util.BankerRole), server.forceReverseTransfer)
+	authRoutes.POST("/transfers/:id/complete", authorizeRoles(util.BankerRole), server.completeTransfer)
+	authRoutes.POST("/transfers/:id/reject", authorizeRoles(util.BankerRole), server.rejectTransfer)
+	authRoutes.POST("/transfers/:id/cancel", server.cancelTransfer)
+
+	authRoutes.POST("/scheduled_transfers", server.createScheduledTransfer)
+	authRoutes.GET("/scheduled_transfers", server.listScheduledTransfers)
+	authRoutes.GET("/scheduled_transfers/:id", authorizeScheduledTransfer(server.store, util.BankerRole), server.getScheduledTransfer)
+	authRoutes.PATCH("/scheduled_transfers/:id", authorizeScheduledTransfer(server.store), server.updateScheduledTransfer)
+	authRoutes.DELETE("/scheduled_transfers/:id", authorizeScheduledTransfer(server.store), server.cancelScheduledTransfer)
+	authRoutes.GET("/scheduled_transfers/:id/executions", authorizeScheduledTransfer(server.store, util.BankerRole), server.listScheduledTransferExecutions)
+
+	authRoutes.GET("/rates", server.listRates)
+	authRoutes.GET("/rates/:base/:quote", server.getRate)
+	authRoutes.GET("/rates/:base/:quote/history", server.listRateHistory)
 
 	server.router = router
 
 }
 
 func (server *Server) Start(address string) error {
+	// Requests can't be validated before the currencies are known
+	err := server.currencies.sync(context.Background())
+	if err != nil {
+		return fmt.Errorf("cannot load currencies: %w", err)
+	}
+
+	currencySyncPeriod := server.config.CurrencySyncPeriod
+	if currencySyncPeriod <= 0 {
+		currencySyncPeriod = defaultCurrencySyncPeriod
+	}
+	go server.currencies.run(context.Background(), currencySyncPeriod)
+
+	// A token revoked before a restart must not be accepted again
+	err = server.revocations.sync(context.Background())
+	if err != nil {
+		return fmt.Errorf("cannot load token revocations: %w", err)
+	}
+
+	syncPeriod := server.config.RevocationSyncPeriod
+	if syncPeriod <= 0 {
+		syncPeriod = defaultRevocationSyncPeriod
+	}
+	go server.revocations.run(context.Background(), syncPeriod)
+
+	refreshPeriod := server.config.RatesRefreshPeriod
+	if refreshPeriod <= 0 {
+		refreshPeriod = defaultRatesRefreshPeriod
+	}
+	go server.rates.Run(context.Background(), refreshPeriod)
+
+	schedulerPeriod := server.config.SchedulerPeriod
+	if schedulerPeriod <= 0 {
+		schedulerPeriod = defaultSchedulerPeriod
+	}
+	go server.scheduler.Run(context.Background(), schedulerPeriod)
+
 	return server.router.Run(address)
 }
 
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/gin-gonic/gin"
+)
+
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type renewAccessTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+func (server *Server) renewAccessToken(context *gin.Context) {
+	var req renewAccessTokenRequest
+
+	err := context.ShouldBindJSON(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken)
+	if err != nil {
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	if refreshPayload.Type != token.TokenTypeRefreshToken {
+		err := errors.New("token is not a refresh token")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	if server.revocations.isRevoked(refreshPayload) {
+		err := errors.New("refresh token has been revoked")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	session, err := server.store.GetSession(context, refreshPayload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			context.JSON(http.StatusNotFound, errorResponce(err))
+			return
+		}
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	if session.IsBlocked {
+		err := errors.New("blocked session")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	if session.Username != refreshPayload.Username {
+		err := errors.New("incorrect session user")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	if session.RefreshToken != req.RefreshToken {
+		err := errors.New("mismatched session token")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		err := errors.New("expired session")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	// Read the user again so a role change takes effect on the next renewal.
+	user, err := server.store.GetUser(context, session.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			context.JSON(http.StatusNotFound, errorResponce(err))
+			return
+		}
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.TokenTypeAccessToken, server.config.AccessTokenDuration)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	response := renewAccessTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+type listTokenKeysResponse struct {
+	Keys []token.PublicKey `json:"keys"`
+}
+
+// listTokenKeys publishes the public keys that access tokens can be verified with,
+// so other services can check our tokens without holding the signing key.
+func (server *Server) listTokenKeys(context *gin.Context) {
+	provider, ok := server.tokenMaker.(token.PublicKeyProvider)
+	if !ok {
+		err := errors.New("tokens are not signed with a public key")
+		context.JSON(http.StatusNotFound, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, listTokenKeysResponse{Keys: provider.PublicKeys()})
+}
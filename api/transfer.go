@@ -2,21 +2,35 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	db "github.com/badermezzi/KubeGoBank/db/sqlc"
 	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/badermezzi/KubeGoBank/util"
 	"github.com/gin-gonic/gin"
 )
 
 type transferRequest struct {
 	FromAccountID int64 `json:"from_account_id" binding:"required,min=1"`
-	ToAccountID   int64 `json:"to_account_id" binding:"required,min=1"`
-	Amount        int64 `json:"amount" binding:"required,gt=0"`
-	// Currency      string `json:"currency" binding:"required,oneof=USD EUR"`
-	Currency string `json:"currency" binding:"required,currency"`
+	// The recipient is given by exactly one of ToAccountID, a payee known by ToUsername or ToEmail, and BeneficiaryID.
+	// A payee is paid into their account in ToCurrency, the currency of Amount by default.
+	ToAccountID   int64      `json:"to_account_id" binding:"omitempty,min=1"`
+	ToUsername    string     `json:"to_username" binding:"omitempty,alphanum"`
+	ToEmail       string     `json:"to_email" binding:"omitempty,email"`
+	ToCurrency    string     `json:"to_currency" binding:"omitempty,currency"`
+	BeneficiaryID int64      `json:"beneficiary_id" binding:"omitempty,min=1"` // BeneficiaryID is one of the sender's saved beneficiaries.
+	Amount        util.Money `json:"amount" binding:"money,positive_money"`    // Amount must be in the from account's currency
+	Description   string     `json:"description" binding:"max=140"`            // Description is shown to both sides on their statements.
+	Reference     string     `json:"reference" binding:"max=64"`               // Reference is the client's own identifier, e.g. an invoice number.
+	// Metadata holds string keys and values for the client, returned with the transfer and searchable in the history.
+	Metadata map[string]string `json:"metadata" binding:"max=20,dive,keys,min=1,max=40,endkeys,max=500"`
+	// Pending holds the amount on the from account until a banker completes or rejects the transfer.
+	// The sender may cancel it meanwhile.
+	Pending bool `json:"pending"`
 }
 
 func (server *Server) createTransfer(context *gin.Context) {
@@ -28,42 +42,398 @@ func (server *Server) createTransfer(context *gin.Context) {
 		return
 	}
 
-	fromAccount, valid := server.validAccount(context, req.FromAccountID, req.Currency)
+	isPayee := req.ToUsername != "" || req.ToEmail != ""
 
-	if !valid {
+	recipients := 0
+	for _, given := range []bool{req.ToAccountID != 0, isPayee, req.BeneficiaryID != 0} {
+		if given {
+			recipients++
+		}
+	}
+	if recipients != 1 {
+		err := errors.New("exactly one of to_account_id, a payee and beneficiary_id is required")
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	if req.ToCurrency != "" && !isPayee {
+		err := errors.New("to_currency only applies to a payee")
+		context.JSON(http.StatusBadRequest, errorResponce(err))
 		return
 	}
 
 	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
 
+	key, err := idempotencyKey(context, authPayload.Username, req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	stored, valid := server.completedTransfer(context, key)
+	if !valid {
+		return
+	}
+
+	if stored != nil {
+		var payee *payeeResponse
+		if isPayee {
+			payee, valid = server.replayedPayee(context, stored.ToAccount)
+			if !valid {
+				return
+			}
+		}
+
+		server.respondTransfer(context, *stored, payee)
+		return
+	}
+
+	fromAccount, valid := server.validAccount(context, req.FromAccountID, req.Amount.Currency)
+
+	if !valid {
+		return
+	}
+
 	if fromAccount.Owner != authPayload.Username {
 		err := errors.New("from account doesn't belong to the authenticated user")
 		context.JSON(http.StatusUnauthorized, errorResponce(err))
 		return
 	}
 
-	_, valid = server.validAccount(context, req.ToAccountID, req.Currency)
+	// The to account may hold another currency, the amount is then converted at the current rate.
+	var toAccount db.Account
+	var payee *payeeResponse
+	switch {
+	case req.ToAccountID != 0:
+		toAccount, valid = server.validToAccount(context, req.ToAccountID, "")
+	case req.BeneficiaryID != 0:
+		toAccount, valid = server.validBeneficiary(context, req.BeneficiaryID, authPayload.Username)
+	default:
+		toCurrency := req.ToCurrency
+		if toCurrency == "" {
+			toCurrency = req.Amount.Currency
+		}
+
+		var row db.GetPayeeRow
+		row, valid = server.validPayee(context, req.ToUsername, req.ToEmail, toCurrency)
+		toAccount = db.Account{ID: row.AccountID, Owner: row.Owner, Currency: row.Currency}
+		response := newPayeeResponse(row)
+		payee = &response
+	}
 
 	if !valid {
 		return
 	}
 
+	if !server.checkCoolingOff(context, authPayload.Username, toAccount.ID, req.Amount, time.Now()) {
+		return
+	}
+
 	arg := db.TransferTxParams{
-		FromAccountID: req.FromAccountID,
-		ToAccountID:   req.ToAccountID,
-		Amount:        req.Amount,
+		FromAccountID:  req.FromAccountID,
+		ToAccountID:    toAccount.ID,
+		Amount:         req.Amount,
+		Description:    req.Description,
+		Reference:      req.Reference,
+		IdempotencyKey: key,
+		Pending:        req.Pending,
+	}
+
+	if len(req.Metadata) > 0 {
+		arg.Metadata, err = json.Marshal(req.Metadata)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+	}
+
+	if toAccount.Currency != fromAccount.Currency {
+		rate, err := server.store.GetExchangeRate(context, db.GetExchangeRateParams{
+			BaseCurrency:  fromAccount.Currency,
+			QuoteCurrency: toAccount.Currency,
+			At:            time.Now(),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err := fmt.Errorf("no exchange rate from %s to %s", fromAccount.Currency, toAccount.Currency)
+				context.JSON(http.StatusUnprocessableEntity, errorResponce(err))
+				return
+			}
+			context.JSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+		arg.ExchangeRate = rate.Rate
 	}
 
 	result, err := server.store.TransferTx(context, arg)
+	if err != nil {
+		context.JSON(transferErrorStatus(err), errorResponce(err))
+		return
+	}
+
+	server.respondTransfer(context, result, payee)
+}
+
+// respondTransfer answers a transfer request with its result, marked if it was replayed from the idempotency key.
+func (server *Server) respondTransfer(context *gin.Context, result db.TransferTxResult, payee *payeeResponse) {
+	if result.Replayed {
+		context.Header(idempotentReplayedHeader, "true")
+	}
+
+	response := server.newTransferTxResponse(result)
+	if payee != nil {
+		// The sender knows the payee by name only, so the payee's account and entry stay private
+		response.ToAccount = nil
+		response.ToEntry = nil
+		response.Payee = payee
+	}
+
+	context.JSON(http.StatusOK, response)
+}
+
+// transferResponse is a transfer with the amount in the from currency and to_amount in the to currency,
+// both also written as decimals.
+type transferResponse struct {
+	ID              int64           `json:"id"`
+	FromAccountID   int64           `json:"from_account_id"`
+	ToAccountID     int64           `json:"to_account_id"`
+	Amount          util.Money      `json:"amount"`
+	AmountDecimal   string          `json:"amount_decimal,omitempty"`
+	ToAmount        util.Money      `json:"to_amount"`
+	ToAmountDecimal string          `json:"to_amount_decimal,omitempty"`
+	ExchangeRate    string          `json:"exchange_rate"`
+	Description     string          `json:"description"`
+	Reference       string          `json:"reference,omitempty"`
+	Metadata        json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	Status          string          `json:"status"`
+	// ReversedTransferID is the transfer a reversal pays back.
+	// ReversedAmount is what reversals have paid back of this transfer so far, in the to currency.
+	ReversedTransferID *int64     `json:"reversed_transfer_id,omitempty"`
+	ReversedAmount     util.Money `json:"reversed_amount"`
+}
+
+func (server *Server) newTransferResponse(transfer db.Transfer, fromCurrency string, toCurrency string) transferResponse {
+	return transferResponse{
+		ID:                 transfer.ID,
+		FromAccountID:      transfer.FromAccountID,
+		ToAccountID:        transfer.ToAccountID,
+		Amount:             util.NewMoney(transfer.Amount, fromCurrency),
+		AmountDecimal:      server.currencies.format(transfer.Amount, fromCurrency),
+		ToAmount:           util.NewMoney(transfer.ToAmount, toCurrency),
+		ToAmountDecimal:    server.currencies.format(transfer.ToAmount, toCurrency),
+		ExchangeRate:       transfer.ExchangeRate,
+		Description:        transfer.Description,
+		Reference:          transfer.Reference,
+		Metadata:           transfer.Metadata,
+		CreatedAt:          transfer.CreatedAt,
+		Status:             transfer.Status,
+		ReversedTransferID: transfer.ReversedTransferID,
+		ReversedAmount:     util.NewMoney(transfer.ReversedAmount, toCurrency),
+	}
+}
+
+// transferTxResponse is the result of a transfer with every amount also written as a decimal of its currency.
+type transferTxResponse struct {
+	Transfer    transferResponse      `json:"transfer"`
+	FromEntry   *ledgerEntryResponse  `json:"from_entry,omitempty"` // The entries are left out while the transfer is pending.
+	ToEntry     *ledgerEntryResponse  `json:"to_entry,omitempty"`
+	FromAccount accountResponse       `json:"from_account"`
+	ToAccount   *accountResponse      `json:"to_account,omitempty"`
+	FXEntries   []ledgerEntryResponse `json:"fx_entries,omitempty"`
+	Payee       *payeeResponse        `json:"payee,omitempty"` // Payee is set instead of ToEntry and ToAccount when paying a username or email.
+}
+
+func (server *Server) newTransferTxResponse(result db.TransferTxResult) transferTxResponse {
+	fromCurrency := result.FromAccount.Currency
+	toCurrency := result.ToAccount.Currency
+
+	toAccount := server.newAccountResponse(result.ToAccount)
+
+	response := transferTxResponse{
+		Transfer:    server.newTransferResponse(result.Transfer, fromCurrency, toCurrency),
+		FromAccount: server.newAccountResponse(result.FromAccount),
+		ToAccount:   &toAccount,
+	}
+
+	if result.Transfer.Status != db.TransferStatusPending {
+		fromEntry := server.newLedgerEntryResponse(result.FromEntry, fromCurrency)
+		toEntry := server.newLedgerEntryResponse(result.ToEntry, toCurrency)
+		response.FromEntry = &fromEntry
+		response.ToEntry = &toEntry
+	}
+
+	// The FX position in the from currency is booked first, then the one in the to currency
+	for i, entry := range result.FXEntries {
+		currency := fromCurrency
+		if i > 0 {
+			currency = toCurrency
+		}
+		response.FXEntries = append(response.FXEntries, server.newLedgerEntryResponse(entry, currency))
+	}
+	return response
+}
+
+type listTransfersRequest struct {
+	AccountID             int64     `form:"account_id" binding:"omitempty,min=1"`                  // AccountID keeps transfers of one of the caller's accounts.
+	Direction             string    `form:"direction" binding:"omitempty,oneof=incoming outgoing"` // Direction is seen from the caller's accounts.
+	CounterpartyAccountID int64     `form:"counterparty_account_id" binding:"omitempty,min=1"`     // CounterpartyAccountID is the other side of the transfer.
+	MinAmount             int64     `form:"min_amount" binding:"omitempty,min=1"`
+	MaxAmount             int64     `form:"max_amount" binding:"omitempty,min=1,gtefield=MinAmount"`
+	From                  time.Time `form:"from"`                                    // From is the first created_at to include, RFC 3339.
+	To                    time.Time `form:"to" binding:"omitempty,gtfield=From"`     // To is the first created_at to leave out, RFC 3339.
+	Reference             string    `form:"reference" binding:"omitempty,max=64"`    // Reference must match exactly.
+	Description           string    `form:"description" binding:"omitempty,max=140"` // Description keeps transfers whose description contains it, ignoring case.
+	pageRequest
+}
+
+// maxMetadataFilters caps the metadata[key]=value pairs of a transfer history query, like the metadata of a transfer.
+const maxMetadataFilters = 20
+
+// listTransfers returns the transfers touching any account of the authenticated user.
+func (server *Server) listTransfers(context *gin.Context) {
+	var req listTransfersRequest
+
+	err := context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	// metadata[key]=value keeps transfers whose metadata has every given pair.
+	metadata := context.QueryMap("metadata")
+	if len(metadata) > maxMetadataFilters {
+		err := fmt.Errorf("at most %d metadata filters are allowed", maxMetadataFilters)
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	arg := db.ListUserTransfersParams{
+		Owner:                 authPayload.Username,
+		AccountID:             sql.NullInt64{Int64: req.AccountID, Valid: req.AccountID != 0},
+		Direction:             req.Direction,
+		CounterpartyAccountID: sql.NullInt64{Int64: req.CounterpartyAccountID, Valid: req.CounterpartyAccountID != 0},
+		MinAmount:             sql.NullInt64{Int64: req.MinAmount, Valid: req.MinAmount != 0},
+		MaxAmount:             sql.NullInt64{Int64: req.MaxAmount, Valid: req.MaxAmount != 0},
+		FromTime:              sql.NullTime{Time: req.From, Valid: !req.From.IsZero()},
+		ToTime:                sql.NullTime{Time: req.To, Valid: !req.To.IsZero()},
+		Reference:             sql.NullString{String: req.Reference, Valid: req.Reference != ""},
+		Description:           sql.NullString{String: req.Description, Valid: req.Description != ""},
+		AfterID:               cursor.ID,
+		PageLimit:             pageLimit(pageSize),
+	}
+
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+		arg.Metadata = sql.NullString{String: string(data), Valid: true}
+	}
+
+	transfers, err := server.store.ListUserTransfers(context, arg)
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, errorResponce(err))
 		return
 	}
 
-	context.JSON(http.StatusOK, result)
+	response := make([]transferResponse, 0, len(transfers))
+	for _, row := range transfers {
+		transfer := db.Transfer{
+			ID:                 row.ID,
+			FromAccountID:      row.FromAccountID,
+			ToAccountID:        row.ToAccountID,
+			Amount:             row.Amount,
+			CreatedAt:          row.CreatedAt,
+			ToAmount:           row.ToAmount,
+			ExchangeRate:       row.ExchangeRate,
+			Description:        row.Description,
+			Reference:          row.Reference,
+			Metadata:           row.Metadata,
+			ReversedTransferID: row.ReversedTransferID,
+			ReversedAmount:     row.ReversedAmount,
+			Status:             row.Status,
+		}
+		response = append(response, server.newTransferResponse(transfer, row.FromCurrency, row.ToCurrency))
+	}
+
+	context.JSON(http.StatusOK, newListResponse(response, pageSize, func(transfer transferResponse) pageCursor {
+		return pageCursor{ID: transfer.ID}
+	}))
+}
 
+type getTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
 }
 
+// validTransfer loads the transfer with the ID in the URI, along with the owners and currencies of its accounts.
+func (server *Server) validTransfer(context *gin.Context) (db.GetTransferWithOwnersRow, bool) {
+	var req getTransferRequest
+
+	err := context.ShouldBindUri(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return db.GetTransferWithOwnersRow{}, false
+	}
+
+	row, err := server.store.GetTransferWithOwners(context, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			context.JSON(http.StatusNotFound, errorResponce(err))
+			return row, false
+		}
+
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return row, false
+	}
+
+	return row, true
+}
+
+// getTransfer returns a transfer if the authenticated user owns one of its accounts or is a banker.
+func (server *Server) getTransfer(context *gin.Context) {
+	row, valid := server.validTransfer(context)
+	if !valid {
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if row.FromOwner != authPayload.Username && row.ToOwner != authPayload.Username && !hasRole(authPayload, util.BankerRole) {
+		err := errors.New("transfer doesn't belong to the authenticated user")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	transfer := db.Transfer{
+		ID:                 row.ID,
+		FromAccountID:      row.FromAccountID,
+		ToAccountID:        row.ToAccountID,
+		Amount:             row.Amount,
+		CreatedAt:          row.CreatedAt,
+		ToAmount:           row.ToAmount,
+		ExchangeRate:       row.ExchangeRate,
+		Description:        row.Description,
+		Reference:          row.Reference,
+		Metadata:           row.Metadata,
+		ReversedTransferID: row.ReversedTransferID,
+		ReversedAmount:     row.ReversedAmount,
+		Status:             row.Status,
+	}
+
+	context.JSON(http.StatusOK, server.newTransferResponse(transfer, row.FromCurrency, row.ToCurrency))
+}
+
+// validAccount loads an account that can move money. If currency is not empty, the account must hold that currency.
 func (server *Server) validAccount(context *gin.Context, accountID int64, currency string) (db.Account, bool) {
 	account, err := server.store.GetAccount(context, accountID)
 	if err != nil {
@@ -76,7 +446,13 @@ func (server *Server) validAccount(context *gin.Context, accountID int64, curren
 		return account, false
 	}
 
-	if account.Currency != currency {
+	if account.Status != db.AccountStatusActive {
+		err := fmt.Errorf("account [%d] is %s", account.ID, account.Status)
+		context.JSON(http.StatusForbidden, errorResponce(err))
+		return account, false
+	}
+
+	if currency != "" && account.Currency != currency {
 		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", account.ID, account.Currency, currency)
 		context.JSON(http.StatusBadRequest, errorResponce(err))
 		return account, false
@@ -84,3 +460,40 @@ func (server *Server) validAccount(context *gin.Context, accountID int64, curren
 
 	return account, true
 }
+
+// validToAccount is validAccount for an account money is sent to, which must belong to a customer.
+// The bank's own accounts, such as suspense and FX position accounts, are only moved by the bank.
+func (server *Server) validToAccount(context *gin.Context, accountID int64, currency string) (db.Account, bool) {
+	account, valid := server.validAccount(context, accountID, currency)
+	if !valid {
+		return account, false
+	}
+
+	if account.Kind != db.AccountKindCustomer {
+		err := fmt.Errorf("account [%d] is a %s account and can't receive transfers", account.ID, account.Kind)
+		context.JSON(http.StatusForbidden, errorResponce(err))
+		return account, false
+	}
+
+	return account, true
+}
+
+// transferErrorStatus maps the errors returned by the store when moving money to an HTTP status code.
+func transferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrInsufficientFunds), errors.Is(err, db.ErrNonZeroBalance), errors.Is(err, db.ErrFundsOnHold),
+		errors.Is(err, db.ErrIdempotencyKeyReused),
+		errors.Is(err, db.ErrAmountTooSmall), errors.Is(err, db.ErrInvalidExchangeRate), errors.Is(err, util.ErrAmountOverflow),
+		errors.Is(err, db.ErrReversalExceedsTransfer), errors.Is(err, db.ErrTransferIsReversal):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrTransferAlreadyReversed), errors.Is(err, db.ErrInvalidTransferTransition):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrAccountFrozen), errors.Is(err, db.ErrAccountClosed):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrCurrencyMismatch), errors.Is(err, util.ErrMoneyCurrencyMismatch), errors.Is(err, db.ErrNonPositiveAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/gin-gonic/gin"
+)
+
+type reverseTransferRequest struct {
+	// Amount is paid back in the currency the recipient was paid in. All that is left of the transfer by default.
+	Amount      *util.Money `json:"amount" binding:"omitempty,money,positive_money"`
+	Description string      `json:"description" binding:"max=140"` // Description defaults to naming the reversed transfer.
+}
+
+// reverseTransferKeyRequest is what an idempotency key of a reversal is bound to, since the body alone
+// doesn't say which transfer is paid back.
+type reverseTransferKeyRequest struct {
+	TransferID int64 `json:"transfer_id"`
+	Forced     bool  `json:"forced"`
+	reverseTransferRequest
+}
+
+type reverseTransferResponse struct {
+	Transfer transferResponse   `json:"transfer"` // Transfer is the reversed transfer with what it has been paid back so far.
+	Reversal transferTxResponse `json:"reversal"`
+}
+
+// refundTransfer lets the recipient of a transfer pay it back, in full or in part. Like POST /transfers, it accepts
+// an Idempotency-Key so that a retried refund is paid once.
+func (server *Server) refundTransfer(context *gin.Context) {
+	server.reverseTransfer(context, false)
+}
+
+// forceReverseTransfer lets a banker reverse a transfer, even into or out of a frozen account.
+func (server *Server) forceReverseTransfer(context *gin.Context) {
+	server.reverseTransfer(context, true)
+}
+
+func (server *Server) reverseTransfer(context *gin.Context, forced bool) {
+	var req reverseTransferRequest
+
+	// The body is optional, an empty one pays back all that is left of the transfer.
+	if context.Request.ContentLength != 0 {
+		err := context.ShouldBindJSON(&req)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+	}
+
+	row, valid := server.validTransfer(context)
+	if !valid {
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if !forced && row.ToOwner != authPayload.Username {
+		err := errors.New("transfer wasn't paid to the authenticated user")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	key, err := idempotencyKey(context, authPayload.Username, reverseTransferKeyRequest{
+		TransferID:             row.ID,
+		Forced:                 forced,
+		reverseTransferRequest: req,
+	})
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	arg := db.ReverseTransferTxParams{
+		TransferID:     row.ID,
+		Description:    req.Description,
+		Forced:         forced,
+		IdempotencyKey: key,
+	}
+
+	if req.Amount != nil {
+		if req.Amount.Currency != row.ToCurrency {
+			err := fmt.Errorf("transfer [%d] was paid in %s, not %s", row.ID, row.ToCurrency, req.Amount.Currency)
+			context.JSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+		arg.Amount = req.Amount.Amount
+	}
+
+	result, err := server.store.ReverseTransferTx(context, arg)
+	if err != nil {
+		context.JSON(transferErrorStatus(err), errorResponce(err))
+		return
+	}
+
+	if result.Replayed {
+		context.Header(idempotentReplayedHeader, "true")
+	}
+
+	response := reverseTransferResponse{
+		Transfer: server.newTransferResponse(result.Transfer, row.FromCurrency, row.ToCurrency),
+		Reversal: server.newTransferTxResponse(result.Reversal),
+	}
+	if !forced {
+		// Like the sender of a payment to a payee, the recipient doesn't get to see the other side's account
+		response.Reversal.ToAccount = nil
+		response.Reversal.ToEntry = nil
+	}
+
+	context.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseTransferAPI(t *testing.T) {
+	sender := randomUser(t)
+	recipient := randomUser(t)
+
+	account1 := createRandomAccount(sender.Username)
+	account2 := createRandomAccount(recipient.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	row := db.GetTransferWithOwnersRow{
+		ID:            util.RandomInt(1, 1000),
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        300,
+		ToAmount:      300,
+		ExchangeRate:  "1",
+		CreatedAt:     time.Now(),
+		FromOwner:     sender.Username,
+		ToOwner:       recipient.Username,
+		FromCurrency:  util.USD,
+		ToCurrency:    util.USD,
+	}
+
+	reversalResult := func(amount int64) db.ReverseTransferTxResult {
+		return db.ReverseTransferTxResult{
+			Transfer: db.Transfer{ID: row.ID, FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 300, ToAmount: 300, ReversedAmount: amount},
+			Reversal: db.TransferTxResult{
+				Transfer:    db.Transfer{ID: row.ID + 1, FromAccountID: account2.ID, ToAccountID: account1.ID, Amount: amount, ToAmount: amount, ReversedTransferID: &row.ID},
+				FromAccount: account2,
+				ToAccount:   account1,
+			},
+		}
+	}
+
+	refundAmount := util.NewMoney(100, util.USD)
+	idempotencyKey := util.RandomString(16)
+	refundKey := &db.CreateIdempotencyKeyParams{
+		Username: recipient.Username,
+		Key:      idempotencyKey,
+		RequestHash: requestFingerprint(reverseTransferKeyRequest{
+			TransferID:             row.ID,
+			reverseTransferRequest: reverseTransferRequest{Amount: &refundAmount},
+		}),
+	}
+
+	testCases := []struct {
+		name           string
+		action         string
+		body           gin.H
+		username       string
+		role           string
+		idempotencyKey string
+		buildStubs     func(store *mockdb.MockStore)
+		checkResponse  func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Refund",
+			action:   "refund",
+			body:     gin.H{"amount": util.NewMoney(100, util.USD), "description": "damaged"},
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				arg := db.ReverseTransferTxParams{
+					TransferID:  row.ID,
+					Amount:      100,
+					Description: "damaged",
+				}
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(reversalResult(100), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response reverseTransferResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.Equal(t, util.NewMoney(100, util.USD), response.Transfer.ReversedAmount)
+				require.Equal(t, row.ID, *response.Reversal.Transfer.ReversedTransferID)
+				require.Equal(t, account2.ID, response.Reversal.FromAccount.ID)
+
+				// The sender's account stays private
+				require.Nil(t, response.Reversal.ToAccount)
+				require.Nil(t, response.Reversal.ToEntry)
+			},
+		},
+		{
+			name:     "RefundAllLeft",
+			action:   "refund",
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				arg := db.ReverseTransferTxParams{TransferID: row.ID}
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(reversalResult(300), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:           "RefundIdempotencyKey",
+			action:         "refund",
+			body:           gin.H{"amount": refundAmount},
+			username:       recipient.Username,
+			role:           util.DepositorRole,
+			idempotencyKey: idempotencyKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				arg := db.ReverseTransferTxParams{
+					TransferID:     row.ID,
+					Amount:         100,
+					IdempotencyKey: refundKey,
+				}
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(reversalResult(100), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Empty(t, recorder.Header().Get(idempotentReplayedHeader))
+			},
+		},
+		{
+			name:           "RefundIdempotentReplay",
+			action:         "refund",
+			body:           gin.H{"amount": refundAmount},
+			username:       recipient.Username,
+			role:           util.DepositorRole,
+			idempotencyKey: idempotencyKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				result := reversalResult(100)
+				result.Replayed = true
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).Return(result, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "true", recorder.Header().Get(idempotentReplayedHeader))
+			},
+		},
+		{
+			name:           "RefundIdempotencyKeyReused",
+			action:         "refund",
+			body:           gin.H{"amount": refundAmount},
+			username:       recipient.Username,
+			role:           util.DepositorRole,
+			idempotencyKey: idempotencyKey,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ReverseTransferTxResult{}, db.ErrIdempotencyKeyReused)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name:           "RefundIdempotencyKeyTooLong",
+			action:         "refund",
+			username:       recipient.Username,
+			role:           util.DepositorRole,
+			idempotencyKey: util.RandomString(maxIdempotencyKeyLength + 1),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "SenderCannotRefund",
+			action:   "refund",
+			username: sender.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "CurrencyMismatch",
+			action:   "refund",
+			body:     gin.H{"amount": util.NewMoney(100, util.EUR)},
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "NegativeAmount",
+			action:   "refund",
+			body:     gin.H{"amount": util.NewMoney(-100, util.USD)},
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			action:   "refund",
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(db.GetTransferWithOwnersRow{}, sql.ErrNoRows)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "AlreadyReversed",
+			action:   "refund",
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ReverseTransferTxResult{}, db.ErrTransferAlreadyReversed)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "ExceedsTransfer",
+			action:   "refund",
+			body:     gin.H{"amount": util.NewMoney(301, util.USD)},
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ReverseTransferTxResult{}, db.ErrReversalExceedsTransfer)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name:     "RecipientAccountFrozen",
+			action:   "refund",
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ReverseTransferTxResult{}, db.ErrAccountFrozen)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "BankerForcesReversal",
+			action:   "reverse",
+			body:     gin.H{"description": "fraud"},
+			username: "banker",
+			role:     util.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				arg := db.ReverseTransferTxParams{
+					TransferID:  row.ID,
+					Description: "fraud",
+					Forced:      true,
+				}
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(reversalResult(300), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response reverseTransferResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.NotNil(t, response.Reversal.ToAccount)
+				require.Equal(t, account1.ID, response.Reversal.ToAccount.ID)
+			},
+		},
+		{
+			name:     "DepositorCannotForce",
+			action:   "reverse",
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			var body bytes.Buffer
+			if tc.body != nil {
+				err := json.NewEncoder(&body).Encode(tc.body)
+				require.NoError(t, err)
+			}
+
+			url := fmt.Sprintf("/transfers/%d/%s", row.ID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, &body)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			if tc.idempotencyKey != "" {
+				request.Header.Set(idempotencyKeyHeader, tc.idempotencyKey)
+			}
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
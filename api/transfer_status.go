@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/gin-gonic/gin"
+)
+
+type releaseTransferResponse struct {
+	Transfer    transferResponse `json:"transfer"`
+	FromAccount accountResponse  `json:"from_account"` // FromAccount no longer holds the amount of the transfer.
+}
+
+// completeTransfer lets a banker post a pending transfer, moving the money held on the from account.
+func (server *Server) completeTransfer(context *gin.Context) {
+	row, valid := server.validTransfer(context)
+	if !valid {
+		return
+	}
+
+	result, err := server.store.CompleteTransferTx(context, row.ID)
+	if err != nil {
+		context.JSON(transferErrorStatus(err), errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, server.newTransferTxResponse(result))
+}
+
+// rejectTransfer lets a banker fail a pending transfer, releasing its hold.
+func (server *Server) rejectTransfer(context *gin.Context) {
+	server.releaseTransfer(context, server.store.FailTransferTx)
+}
+
+// cancelTransfer lets the sender of a pending transfer, or a banker, call it off and release its hold.
+func (server *Server) cancelTransfer(context *gin.Context) {
+	server.releaseTransfer(context, server.store.CancelTransferTx)
+}
+
+func (server *Server) releaseTransfer(context *gin.Context, release func(ctx context.Context, transferID int64) (db.ReleaseTransferTxResult, error)) {
+	row, valid := server.validTransfer(context)
+	if !valid {
+		return
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if row.FromOwner != authPayload.Username && !hasRole(authPayload, util.BankerRole) {
+		err := errors.New("transfer wasn't sent by the authenticated user")
+		context.JSON(http.StatusUnauthorized, errorResponce(err))
+		return
+	}
+
+	result, err := release(context, row.ID)
+	if err != nil {
+		context.JSON(transferErrorStatus(err), errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, releaseTransferResponse{
+		Transfer:    server.newTransferResponse(result.Transfer, row.FromCurrency, row.ToCurrency),
+		FromAccount: server.newAccountResponse(result.FromAccount),
+	})
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferStatusAPI(t *testing.T) {
+	sender := randomUser(t)
+	recipient := randomUser(t)
+
+	account1 := createRandomAccount(sender.Username)
+	account2 := createRandomAccount(recipient.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	row := db.GetTransferWithOwnersRow{
+		ID:            util.RandomInt(1, 1000),
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        300,
+		ToAmount:      300,
+		ExchangeRate:  "1",
+		CreatedAt:     time.Now(),
+		Status:        db.TransferStatusPending,
+		FromOwner:     sender.Username,
+		ToOwner:       recipient.Username,
+		FromCurrency:  util.USD,
+		ToCurrency:    util.USD,
+	}
+
+	transfer := func(status string) db.Transfer {
+		return db.Transfer{ID: row.ID, FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 300, ToAmount: 300, Status: status}
+	}
+
+	released := func(status string) db.ReleaseTransferTxResult {
+		return db.ReleaseTransferTxResult{Transfer: transfer(status), FromAccount: account1}
+	}
+
+	testCases := []struct {
+		name          string
+		action        string
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "BankerCompletes",
+			action:   "complete",
+			username: "banker",
+			role:     util.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				result := db.TransferTxResult{
+					Transfer:    transfer(db.TransferStatusCompleted),
+					FromAccount: account1,
+					ToAccount:   account2,
+					FromEntry:   db.Entry{ID: 1, AccountID: account1.ID, Amount: -300},
+					ToEntry:     db.Entry{ID: 2, AccountID: account2.ID, Amount: 300},
+				}
+				store.EXPECT().CompleteTransferTx(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(result, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response transferTxResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.Equal(t, db.TransferStatusCompleted, response.Transfer.Status)
+				require.NotNil(t, response.FromEntry)
+				require.NotNil(t, response.ToEntry)
+			},
+		},
+		{
+			name:     "CompleteNotPending",
+			action:   "complete",
+			username: "banker",
+			role:     util.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				err := &db.TransferStatusError{TransferID: row.ID, From: db.TransferStatusCancelled, To: db.TransferStatusCompleted}
+				store.EXPECT().CompleteTransferTx(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(db.TransferTxResult{}, err)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "DepositorCannotComplete",
+			action:   "complete",
+			username: sender.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CompleteTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "BankerRejects",
+			action:   "reject",
+			username: "banker",
+			role:     util.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().FailTransferTx(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(released(db.TransferStatusFailed), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response releaseTransferResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.Equal(t, db.TransferStatusFailed, response.Transfer.Status)
+				require.Equal(t, account1.ID, response.FromAccount.ID)
+			},
+		},
+		{
+			name:     "SenderCancels",
+			action:   "cancel",
+			username: sender.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().CancelTransferTx(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(released(db.TransferStatusCancelled), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response releaseTransferResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.Equal(t, db.TransferStatusCancelled, response.Transfer.Status)
+			},
+		},
+		{
+			name:     "BankerCancels",
+			action:   "cancel",
+			username: "banker",
+			role:     util.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().CancelTransferTx(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(released(db.TransferStatusCancelled), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "RecipientCannotCancel",
+			action:   "cancel",
+			username: recipient.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+				store.EXPECT().CancelTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "CancelCompleted",
+			action:   "cancel",
+			username: sender.Username,
+			role:     util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferWithOwners(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(row, nil)
+
+				err := &db.TransferStatusError{TransferID: row.ID, From: db.TransferStatusCompleted, To: db.TransferStatusCancelled}
+				store.EXPECT().CancelTransferTx(gomock.Any(), gomock.Eq(row.ID)).Times(1).Return(db.ReleaseTransferTxResult{}, err)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/transfers/%d/%s", row.ID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -2,12 +2,15 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/token"
 	"github.com/badermezzi/KubeGoBank/util"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
@@ -22,6 +25,7 @@ type userResponse struct {
 	Username          string    `json:"username"`
 	FullName          string    `json:"full_name"`
 	Email             string    `json:"email"`
+	Role              string    `json:"role"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
 }
@@ -31,6 +35,7 @@ func newUserResponse(user db.User) userResponse {
 		Username:          user.Username,
 		FullName:          user.FullName,
 		Email:             user.Email,
+		Role:              user.Role,
 		PasswordChangedAt: user.PasswordChangedAt,
 		CreatedAt:         user.CreatedAt,
 	}
@@ -84,8 +89,12 @@ type loginUserRequest struct {
 }
 
 type loginUserResponse struct {
-	AccessToken string       `json:"access_token"`
-	User        userResponse `json:"user"`
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
 }
 
 func (server *Server) loginUser(context *gin.Context) {
@@ -113,17 +122,156 @@ func (server *Server) loginUser(context *gin.Context) {
 		return
 	}
 
-	accessToken, err := server.tokenMaker.CreateToken(user.Username, server.config.AccessTokenDuration)
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.TokenTypeAccessToken, server.config.AccessTokenDuration)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.TokenTypeRefreshToken, server.config.RefreshTokenDuration)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	session, err := server.store.CreateSession(context, db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    context.Request.UserAgent(),
+		ClientIp:     context.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, errorResponce(err))
 		return
 	}
 
 	response := loginUserResponse{
-		AccessToken: accessToken,
-		User:        newUserResponse(user),
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
 	}
 
 	context.JSON(http.StatusOK, response)
 
 }
+
+type logoutUserRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (server *Server) logoutUser(context *gin.Context) {
+	var req logoutUserRequest
+
+	// The body is optional: without a refresh token only the access token is revoked.
+	if context.Request.ContentLength != 0 {
+		err := context.ShouldBindJSON(&req)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, errorResponce(err))
+			return
+		}
+	}
+
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if req.RefreshToken != "" {
+		refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken)
+		if err != nil {
+			context.JSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
+		if refreshPayload.Type != token.TokenTypeRefreshToken {
+			err := errors.New("token is not a refresh token")
+			context.JSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
+		if refreshPayload.Username != authPayload.Username {
+			err := errors.New("refresh token doesn't belong to the authenticated user")
+			context.JSON(http.StatusUnauthorized, errorResponce(err))
+			return
+		}
+
+		err = server.store.BlockSession(context, refreshPayload.ID)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+
+		err = server.revocations.revokeToken(context, refreshPayload)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, errorResponce(err))
+			return
+		}
+	}
+
+	err := server.revocations.revokeToken(context, authPayload)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func (server *Server) logoutAllUser(context *gin.Context) {
+	authPayload := context.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	err := server.revocations.revokeUser(context, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			context.JSON(http.StatusNotFound, errorResponce(err))
+			return
+		}
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "logged out of all sessions"})
+}
+
+type listUsersRequest struct {
+	pageRequest
+}
+
+func (server *Server) listUsers(context *gin.Context) {
+	var req listUsersRequest
+
+	err := context.ShouldBindQuery(&req)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	cursor, pageSize, err := server.parsePage(req.pageRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, errorResponce(err))
+		return
+	}
+
+	arg := db.ListUsersParams{
+		AfterUsername: cursor.Username,
+		PageLimit:     pageLimit(pageSize),
+	}
+
+	users, err := server.store.ListUsers(context, arg)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, errorResponce(err))
+		return
+	}
+
+	response := make([]userResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, newUserResponse(user))
+	}
+
+	context.JSON(http.StatusOK, newListResponse(response, pageSize, func(user userResponse) pageCursor {
+		return pageCursor{Username: user.Username}
+	}))
+}
@@ -1,15 +1,29 @@
 package api
 
 import (
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
 	"github.com/badermezzi/KubeGoBank/util"
 	"github.com/go-playground/validator/v10"
 )
 
-var validCurrency validator.Func = func(fieldLevel validator.FieldLevel) bool {
-	currency, ok := fieldLevel.Field().Interface().(string)
-	if ok {
-		return util.IsSupportedCurrency(currency)
+var validPositiveMoney validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	money, ok := fieldLevel.Field().Interface().(util.Money)
+	return ok && money.IsPositive()
+}
 
+var validNonZeroMoney validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	money, ok := fieldLevel.Field().Interface().(util.Money)
+	return ok && !money.IsZero()
+}
+
+var validAdjustmentReason validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	reason, ok := fieldLevel.Field().Interface().(string)
+	if ok {
+		switch reason {
+		case db.AdjustmentReasonCorrection, db.AdjustmentReasonFee, db.AdjustmentReasonFeeRefund,
+			db.AdjustmentReasonInterest, db.AdjustmentReasonWriteOff:
+			return true
+		}
 	}
 	return false
 }
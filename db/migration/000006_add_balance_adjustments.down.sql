@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS "balance_adjustments";
+
+DELETE FROM "entries" WHERE "account_id" IN (SELECT "id" FROM "accounts" WHERE "owner" = 'suspense');
+
+DELETE FROM "accounts" WHERE "owner" = 'suspense';
+
+DELETE FROM "users" WHERE "username" = 'suspense';
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "kind";
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "nickname";
@@ -0,0 +1,46 @@
+ALTER TABLE "accounts" ADD COLUMN "nickname" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "accounts" ADD COLUMN "kind" varchar NOT NULL DEFAULT 'customer';
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_kind_check" CHECK ("kind" IN ('customer', 'suspense'));
+
+COMMENT ON COLUMN "accounts"."kind" IS 'system accounts such as suspense balance the ledger and are not owned by a customer';
+
+CREATE TABLE "balance_adjustments" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "suspense_account_id" bigint NOT NULL,
+  "entry_id" bigint NOT NULL,
+  "suspense_entry_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "reason_code" varchar NOT NULL,
+  "note" varchar NOT NULL DEFAULT '',
+  "created_by" varchar NOT NULL,
+  "created_at" TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "balance_adjustments" ("account_id");
+
+COMMENT ON COLUMN "balance_adjustments"."amount" IS 'credited to the account and debited from the suspense account, can be negative';
+
+ALTER TABLE "balance_adjustments" ADD CONSTRAINT "balance_adjustments_reason_code_check" CHECK ("reason_code" IN ('correction', 'fee', 'fee_refund', 'interest', 'write_off'));
+
+ALTER TABLE "balance_adjustments" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "balance_adjustments" ADD FOREIGN KEY ("suspense_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "balance_adjustments" ADD FOREIGN KEY ("entry_id") REFERENCES "entries" ("id");
+
+ALTER TABLE "balance_adjustments" ADD FOREIGN KEY ("suspense_entry_id") REFERENCES "entries" ("id");
+
+ALTER TABLE "balance_adjustments" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("username");
+
+-- The suspense user owns one suspense account per currency. It has no password, so nobody can log in as it.
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email")
+VALUES ('suspense', '', 'Suspense', 'suspense@system.invalid');
+
+INSERT INTO "accounts" ("owner", "balance", "currency", "kind")
+VALUES
+  ('suspense', 0, 'USD', 'suspense'),
+  ('suspense', 0, 'EUR', 'suspense'),
+  ('suspense', 0, 'CAD', 'suspense');
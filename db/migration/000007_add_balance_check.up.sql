@@ -0,0 +1,3 @@
+-- Customer accounts can never go below zero, whatever code path moves the money.
+-- NOT VALID skips checking rows written before this migration, new writes are always checked.
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("kind" <> 'customer' OR "balance" >= 0) NOT VALID;
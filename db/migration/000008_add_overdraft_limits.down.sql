@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "overdraft_limit_changes";
+
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_check";
+
+ALTER TABLE IF EXISTS "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("kind" <> 'customer' OR "balance" >= 0) NOT VALID;
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "overdraft_limit";
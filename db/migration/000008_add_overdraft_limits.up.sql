@@ -0,0 +1,24 @@
+ALTER TABLE "accounts" ADD COLUMN "overdraft_limit" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_overdraft_limit_check" CHECK ("overdraft_limit" >= 0);
+
+COMMENT ON COLUMN "accounts"."overdraft_limit" IS 'how far below zero the balance may go';
+
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("kind" <> 'customer' OR "balance" >= -"overdraft_limit") NOT VALID;
+
+CREATE TABLE "overdraft_limit_changes" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "old_limit" bigint NOT NULL,
+  "new_limit" bigint NOT NULL,
+  "changed_by" varchar NOT NULL,
+  "created_at" TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "overdraft_limit_changes" ("account_id");
+
+ALTER TABLE "overdraft_limit_changes" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "overdraft_limit_changes" ADD FOREIGN KEY ("changed_by") REFERENCES "users" ("username");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "transfers_to_account_id_id_idx";
+
+DROP INDEX IF EXISTS "transfers_from_account_id_id_idx";
+
+DROP INDEX IF EXISTS "entries_account_id_id_idx";
@@ -0,0 +1,30 @@
+ALTER TABLE "accounts" DROP CONSTRAINT "accounts_kind_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_kind_check" CHECK ("kind" IN ('customer', 'suspense', 'fx_position'));
+
+ALTER TABLE "transfers" ADD COLUMN "to_amount" bigint;
+
+UPDATE "transfers" SET "to_amount" = "amount";
+
+ALTER TABLE "transfers" ALTER COLUMN "to_amount" SET NOT NULL;
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_to_amount_check" CHECK ("to_amount" > 0);
+
+ALTER TABLE "transfers" ADD COLUMN "exchange_rate" numeric NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN "transfers"."amount" IS 'must be positive, in the currency of the from account';
+
+COMMENT ON COLUMN "transfers"."to_amount" IS 'credited to the to account in its currency';
+
+COMMENT ON COLUMN "transfers"."exchange_rate" IS 'units of the to currency bought by one unit of the from currency, 1 within a currency';
+
+-- The fx user owns one FX position account per currency. Cross-currency transfers credit the position in the
+-- source currency and debit the position in the destination currency, so each currency's ledger still balances.
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email")
+VALUES ('fx', '', 'FX Position', 'fx@system.invalid');
+
+INSERT INTO "accounts" ("owner", "balance", "currency", "kind")
+VALUES
+  ('fx', 0, 'USD', 'fx_position'),
+  ('fx', 0, 'EUR', 'fx_position'),
+  ('fx', 0, 'CAD', 'fx_position');
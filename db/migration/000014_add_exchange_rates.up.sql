@@ -0,0 +1,17 @@
+CREATE TABLE "exchange_rates" (
+  "id" bigserial PRIMARY KEY,
+  "base_currency" varchar NOT NULL,
+  "quote_currency" varchar NOT NULL,
+  "rate" numeric NOT NULL,
+  "valid_from" timestamptz NOT NULL,
+  "source" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "exchange_rates" ("base_currency", "quote_currency", "valid_from");
+
+ALTER TABLE "exchange_rates" ADD CONSTRAINT "exchange_rates_rate_check" CHECK ("rate" > 0);
+
+COMMENT ON COLUMN "exchange_rates"."rate" IS 'units of the quote currency bought by one unit of the base currency';
+
+COMMENT ON COLUMN "exchange_rates"."source" IS 'rate provider the rate was loaded from';
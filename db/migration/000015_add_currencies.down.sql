@@ -0,0 +1,13 @@
+DELETE FROM "entries" WHERE "account_id" IN (
+  SELECT "id" FROM "accounts" WHERE "owner" IN ('suspense', 'fx') AND "currency" IN ('JPY', 'KWD')
+);
+
+DELETE FROM "accounts" WHERE "owner" IN ('suspense', 'fx') AND "currency" IN ('JPY', 'KWD');
+
+ALTER TABLE IF EXISTS "exchange_rates" DROP CONSTRAINT IF EXISTS "exchange_rates_quote_currency_fkey";
+
+ALTER TABLE IF EXISTS "exchange_rates" DROP CONSTRAINT IF EXISTS "exchange_rates_base_currency_fkey";
+
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_currency_fkey";
+
+DROP TABLE IF EXISTS "currencies";
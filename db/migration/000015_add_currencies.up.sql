@@ -0,0 +1,43 @@
+CREATE TABLE "currencies" (
+  "code" varchar(3) PRIMARY KEY,
+  "numeric_code" integer UNIQUE NOT NULL,
+  "minor_unit" integer NOT NULL,
+  "enabled" boolean NOT NULL DEFAULT true,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "currencies" ADD CONSTRAINT "currencies_code_check" CHECK ("code" ~ '^[A-Z]{3}$');
+
+ALTER TABLE "currencies" ADD CONSTRAINT "currencies_numeric_code_check" CHECK ("numeric_code" BETWEEN 1 AND 999);
+
+ALTER TABLE "currencies" ADD CONSTRAINT "currencies_minor_unit_check" CHECK ("minor_unit" BETWEEN 0 AND 4);
+
+COMMENT ON COLUMN "currencies"."code" IS 'ISO 4217 alphabetic code';
+
+COMMENT ON COLUMN "currencies"."numeric_code" IS 'ISO 4217 numeric code';
+
+COMMENT ON COLUMN "currencies"."minor_unit" IS 'number of decimal places, amounts are stored in 10^-minor_unit of the currency';
+
+COMMENT ON COLUMN "currencies"."enabled" IS 'disabled currencies cannot be used for new accounts or transfers, existing accounts stay readable';
+
+INSERT INTO "currencies" ("code", "numeric_code", "minor_unit")
+VALUES
+  ('USD', 840, 2),
+  ('EUR', 978, 2),
+  ('CAD', 124, 2),
+  ('JPY', 392, 0),
+  ('KWD', 414, 3);
+
+ALTER TABLE "accounts" ADD FOREIGN KEY ("currency") REFERENCES "currencies" ("code");
+
+ALTER TABLE "exchange_rates" ADD FOREIGN KEY ("base_currency") REFERENCES "currencies" ("code");
+
+ALTER TABLE "exchange_rates" ADD FOREIGN KEY ("quote_currency") REFERENCES "currencies" ("code");
+
+-- Every currency needs its suspense and FX position accounts. A currency added later must get them too.
+INSERT INTO "accounts" ("owner", "balance", "currency", "kind")
+VALUES
+  ('suspense', 0, 'JPY', 'suspense'),
+  ('suspense', 0, 'KWD', 'suspense'),
+  ('fx', 0, 'JPY', 'fx_position'),
+  ('fx', 0, 'KWD', 'fx_position');
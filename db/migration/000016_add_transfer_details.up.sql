@@ -0,0 +1,21 @@
+ALTER TABLE "transfers" ADD COLUMN "description" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "transfers" ADD COLUMN "reference" varchar NOT NULL DEFAULT '';
+
+ALTER TABLE "transfers" ADD COLUMN "metadata" jsonb NOT NULL DEFAULT '{}';
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_metadata_check" CHECK (jsonb_typeof("metadata") = 'object');
+
+ALTER TABLE "entries" ADD COLUMN "description" varchar NOT NULL DEFAULT '';
+
+CREATE INDEX ON "transfers" ("reference") WHERE "reference" <> '';
+
+CREATE INDEX ON "transfers" USING GIN ("metadata");
+
+COMMENT ON COLUMN "transfers"."description" IS 'free text shown to both sides of the transfer';
+
+COMMENT ON COLUMN "transfers"."reference" IS 'set by the client to match the transfer with its own records, e.g. an invoice number';
+
+COMMENT ON COLUMN "transfers"."metadata" IS 'string keys and values set by the client';
+
+COMMENT ON COLUMN "entries"."description" IS 'description of the transfer the entry belongs to';
@@ -0,0 +1,81 @@
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "description" varchar NOT NULL DEFAULT '',
+  "frequency" varchar NOT NULL,
+  "start_at" timestamptz NOT NULL,
+  "end_at" timestamptz,
+  "max_runs" integer,
+  "insufficient_funds_policy" varchar NOT NULL DEFAULT 'skip',
+  "max_retries" integer NOT NULL DEFAULT 0,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "runs" integer NOT NULL DEFAULT 0,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "next_run_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "scheduled_transfer_executions" (
+  "id" bigserial PRIMARY KEY,
+  "scheduled_transfer_id" bigint NOT NULL,
+  "scheduled_for" timestamptz NOT NULL,
+  "attempt" integer NOT NULL,
+  "status" varchar NOT NULL,
+  "transfer_id" bigint,
+  "error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "scheduled_transfers" ("owner", "id");
+
+CREATE INDEX ON "scheduled_transfers" ("next_run_at") WHERE "status" = 'active';
+
+CREATE UNIQUE INDEX ON "scheduled_transfer_executions" ("scheduled_transfer_id", "scheduled_for", "attempt");
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_frequency_check" CHECK ("frequency" IN ('once', 'daily', 'weekly', 'monthly'));
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_max_runs_check" CHECK ("max_runs" > 0);
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_insufficient_funds_policy_check" CHECK ("insufficient_funds_policy" IN ('skip', 'retry'));
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_max_retries_check" CHECK ("max_retries" >= 0);
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_status_check" CHECK ("status" IN ('active', 'completed', 'cancelled'));
+
+ALTER TABLE "scheduled_transfer_executions" ADD CONSTRAINT "scheduled_transfer_executions_status_check" CHECK ("status" IN ('succeeded', 'retrying', 'skipped', 'failed'));
+
+COMMENT ON COLUMN "scheduled_transfers"."amount" IS 'must be positive, in currency, which is the currency of the from account';
+
+COMMENT ON COLUMN "scheduled_transfers"."start_at" IS 'first run, later runs of a recurring schedule follow it by day, week or calendar month';
+
+COMMENT ON COLUMN "scheduled_transfers"."end_at" IS 'no run is scheduled after it, null runs until max_runs or for ever';
+
+COMMENT ON COLUMN "scheduled_transfers"."insufficient_funds_policy" IS 'skip gives up on a run that lacks funds, retry tries it again up to max_retries times';
+
+COMMENT ON COLUMN "scheduled_transfers"."runs" IS 'runs done, paid or given up, the next run is run number runs';
+
+COMMENT ON COLUMN "scheduled_transfers"."attempts" IS 'failed attempts at the next run';
+
+COMMENT ON COLUMN "scheduled_transfers"."next_run_at" IS 'when the executor picks the schedule up next, null once it is no longer active';
+
+COMMENT ON COLUMN "scheduled_transfer_executions"."scheduled_for" IS 'the run the attempt was for, retries share it';
+
+COMMENT ON COLUMN "scheduled_transfer_executions"."transfer_id" IS 'transfer made by a succeeded attempt';
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("currency") REFERENCES "currencies" ("code");
+
+ALTER TABLE "scheduled_transfer_executions" ADD FOREIGN KEY ("scheduled_transfer_id") REFERENCES "scheduled_transfers" ("id");
+
+ALTER TABLE "scheduled_transfer_executions" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
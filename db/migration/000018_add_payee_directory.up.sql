@@ -0,0 +1,2 @@
+-- Payees are looked up by email ignoring case, so two users can't share an email that only differs in case.
+CREATE UNIQUE INDEX "users_lower_email_key" ON "users" (lower("email"));
@@ -0,0 +1,26 @@
+CREATE TABLE "beneficiaries" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "nickname" varchar NOT NULL,
+  "account_id" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "beneficiaries" ("owner", "id");
+
+CREATE UNIQUE INDEX ON "beneficiaries" ("owner", "account_id");
+
+CREATE UNIQUE INDEX ON "beneficiaries" ("owner", "nickname");
+
+COMMENT ON COLUMN "beneficiaries"."account_id" IS 'account the owner pays when transferring to the beneficiary';
+
+COMMENT ON COLUMN "beneficiaries"."currency" IS 'currency of the account, checked when the beneficiary is saved';
+
+COMMENT ON COLUMN "beneficiaries"."created_at" IS 'start of the cooling-off period, during which transfers to the beneficiary are capped';
+
+ALTER TABLE "beneficiaries" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "beneficiaries" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "beneficiaries" ADD FOREIGN KEY ("currency") REFERENCES "currencies" ("code");
@@ -0,0 +1,13 @@
+ALTER TABLE "transfers" ADD COLUMN "reversed_transfer_id" bigint;
+
+ALTER TABLE "transfers" ADD COLUMN "reversed_amount" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_reversed_amount_check" CHECK ("reversed_amount" >= 0 AND "reversed_amount" <= "to_amount");
+
+CREATE INDEX ON "transfers" ("reversed_transfer_id") WHERE "reversed_transfer_id" IS NOT NULL;
+
+COMMENT ON COLUMN "transfers"."reversed_transfer_id" IS 'transfer this one pays back, in full or in part, null for an ordinary transfer';
+
+COMMENT ON COLUMN "transfers"."reversed_amount" IS 'part of to_amount already paid back by reversals, in the currency of the to account';
+
+ALTER TABLE "transfers" ADD FOREIGN KEY ("reversed_transfer_id") REFERENCES "transfers" ("id");
@@ -0,0 +1,7 @@
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_check";
+
+ALTER TABLE IF EXISTS "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("kind" <> 'customer' OR "balance" >= -"overdraft_limit") NOT VALID;
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "held_amount";
+
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "status";
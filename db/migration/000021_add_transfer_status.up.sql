@@ -0,0 +1,18 @@
+ALTER TABLE "transfers" ADD COLUMN "status" varchar NOT NULL DEFAULT 'completed';
+
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_status_check" CHECK ("status" IN ('pending', 'completed', 'failed', 'cancelled', 'reversed'));
+
+UPDATE "transfers" SET "status" = 'reversed' WHERE "reversed_amount" = "to_amount";
+
+COMMENT ON COLUMN "transfers"."status" IS 'pending transfers hold their amount on the from account, only completed ones have entries';
+
+ALTER TABLE "accounts" ADD COLUMN "held_amount" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_held_amount_check" CHECK ("held_amount" >= 0);
+
+COMMENT ON COLUMN "accounts"."held_amount" IS 'part of the balance held for pending transfers, it can''t be spent';
+
+-- Money on hold is spoken for, so the overdraft limit applies to what is left of the balance.
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("kind" <> 'customer' OR "balance" - "held_amount" >= -"overdraft_limit") NOT VALID;
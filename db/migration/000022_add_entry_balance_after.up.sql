@@ -0,0 +1,19 @@
+ALTER TABLE "entries" ADD COLUMN "balance_after" bigint;
+
+COMMENT ON COLUMN "entries"."balance_after" IS 'balance of the account right after the entry was posted';
+
+-- The balance after an entry is the current balance minus every later entry of the account.
+UPDATE "entries" AS e
+SET "balance_after" = s."balance_after"
+FROM (
+  SELECT
+    entries."id",
+    accounts."balance"
+      - SUM(entries."amount") OVER (PARTITION BY entries."account_id")
+      + SUM(entries."amount") OVER (PARTITION BY entries."account_id" ORDER BY entries."id") AS "balance_after"
+  FROM "entries"
+  JOIN "accounts" ON accounts."id" = entries."account_id"
+) AS s
+WHERE e."id" = s."id";
+
+ALTER TABLE "entries" ALTER COLUMN "balance_after" SET NOT NULL;
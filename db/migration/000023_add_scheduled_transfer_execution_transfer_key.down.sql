@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "scheduled_transfer_executions_transfer_id_key";
@@ -0,0 +1,2 @@
+-- A transfer is made by one run, so recording it again finds the execution already there.
+CREATE UNIQUE INDEX "scheduled_transfer_executions_transfer_id_key" ON "scheduled_transfer_executions" ("transfer_id");
@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS "currencies" DROP COLUMN IF EXISTS "beneficiary_cooling_off_limit";
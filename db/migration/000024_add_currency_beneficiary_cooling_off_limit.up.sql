@@ -0,0 +1,20 @@
+ALTER TABLE "currencies" ADD COLUMN "beneficiary_cooling_off_limit" bigint NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN "currencies"."beneficiary_cooling_off_limit" IS 'most that can be sent, in minor units, into an account saved as a beneficiary during its cooling-off period';
+
+-- About 500 USD in each currency. A currency added later must set its own limit.
+UPDATE "currencies" AS c
+SET "beneficiary_cooling_off_limit" = l."beneficiary_cooling_off_limit"
+FROM (
+  VALUES
+    ('USD', 50000),
+    ('EUR', 50000),
+    ('CAD', 70000),
+    ('JPY', 75000),
+    ('KWD', 150000)
+) AS l ("code", "beneficiary_cooling_off_limit")
+WHERE c."code" = l."code";
+
+ALTER TABLE "currencies" ALTER COLUMN "beneficiary_cooling_off_limit" DROP DEFAULT;
+
+ALTER TABLE "currencies" ADD CONSTRAINT "currencies_beneficiary_cooling_off_limit_check" CHECK ("beneficiary_cooling_off_limit" > 0);
@@ -0,0 +1,7 @@
+DELETE FROM "beneficiaries" WHERE "deleted_at" IS NOT NULL;
+
+DROP INDEX IF EXISTS "beneficiaries_owner_nickname_idx";
+
+CREATE UNIQUE INDEX ON "beneficiaries" ("owner", "nickname");
+
+ALTER TABLE IF EXISTS "beneficiaries" DROP COLUMN IF EXISTS "deleted_at";
@@ -0,0 +1,8 @@
+ALTER TABLE "beneficiaries" ADD COLUMN "deleted_at" timestamptz;
+
+COMMENT ON COLUMN "beneficiaries"."deleted_at" IS 'set when the owner deletes the beneficiary, the row is kept for the cooling-off period of the account';
+
+-- A deleted beneficiary no longer holds its nickname.
+DROP INDEX IF EXISTS "beneficiaries_owner_nickname_idx";
+
+CREATE UNIQUE INDEX ON "beneficiaries" ("owner", "nickname") WHERE "deleted_at" IS NULL;
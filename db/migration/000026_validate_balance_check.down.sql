@@ -0,0 +1,4 @@
+-- Overdraft limits raised by the up migration are kept, the accounts still owe that money.
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_check";
+
+ALTER TABLE IF EXISTS "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("kind" <> 'customer' OR "balance" - "held_amount" >= -"overdraft_limit") NOT VALID;
@@ -0,0 +1,22 @@
+-- accounts_balance_check was added NOT VALID, so customer accounts overdrawn before it still break it.
+-- Their balances are left as they are: each one gets an overdraft limit that covers what it already owes,
+-- so it can't draw any further, and is reported below for the bank to follow up.
+DO $$
+DECLARE
+  "account" record;
+BEGIN
+  FOR "account" IN
+    SELECT "id", "owner", "currency", "balance", "held_amount", "overdraft_limit" FROM "accounts"
+    WHERE "kind" = 'customer' AND "balance" - "held_amount" < -"overdraft_limit"
+    ORDER BY "id"
+  LOOP
+    RAISE NOTICE 'account % of % is overdrawn by % % beyond its overdraft limit of %, the limit is raised to %',
+      "account"."id", "account"."owner", -("account"."balance" - "account"."held_amount") - "account"."overdraft_limit",
+      "account"."currency", "account"."overdraft_limit", "account"."held_amount" - "account"."balance";
+  END LOOP;
+END $$;
+
+UPDATE "accounts" SET "overdraft_limit" = "held_amount" - "balance"
+WHERE "kind" = 'customer' AND "balance" - "held_amount" < -"overdraft_limit";
+
+ALTER TABLE "accounts" VALIDATE CONSTRAINT "accounts_balance_check";
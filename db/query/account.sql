@@ -13,14 +13,40 @@ WHERE id = $1 LIMIT 1;
 
 -- name: ListAccounts :many
 SELECT * FROM accounts
-WHERE owner = $1
+WHERE owner = sqlc.arg(owner) AND id > sqlc.arg(after_id)
 ORDER BY id
-LIMIT $2
-OFFSET $3;
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetAccountForUpdate :one
+SELECT * FROM accounts
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: GetSuspenseAccount :one
+SELECT * FROM accounts
+WHERE kind = 'suspense' AND currency = $1
+LIMIT 1;
+
+-- name: GetFXPositionAccount :one
+SELECT * FROM accounts
+WHERE kind = 'fx_position' AND currency = $1
+LIMIT 1;
 
 -- name: UpdateAccount :one
 UPDATE accounts
-SET balance = $2
+SET nickname = COALESCE(sqlc.narg(nickname), nickname)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateAccountStatus :one
+UPDATE accounts
+SET status = sqlc.arg(status)
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(from_status)
+RETURNING *;
+
+-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $2
 WHERE id = $1
 RETURNING *;
 
@@ -32,4 +58,20 @@ WHERE id = $1;
 UPDATE accounts
 SET balance = balance + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: GetPayee :one
+-- Resolves a payee, known by username or email, to their active customer account in a currency.
+SELECT a.id AS account_id, a.owner, a.currency, u.full_name FROM accounts a
+JOIN users u ON u.username = a.owner
+WHERE (u.username = sqlc.narg(username) OR lower(u.email) = lower(sqlc.narg(email)))
+  AND a.currency = sqlc.arg(currency)
+  AND a.kind = 'customer'
+  AND a.status = 'active'
+LIMIT 1;
+
+-- name: AddAccountHeldAmount :one
+UPDATE accounts
+SET held_amount = held_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
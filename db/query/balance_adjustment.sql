@@ -0,0 +1,23 @@
+-- name: CreateBalanceAdjustment :one
+INSERT INTO balance_adjustments (
+  account_id,
+  suspense_account_id,
+  entry_id,
+  suspense_entry_id,
+  amount,
+  reason_code,
+  note,
+  created_by
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetBalanceAdjustment :one
+SELECT * FROM balance_adjustments
+WHERE id = $1 LIMIT 1;
+
+-- name: ListBalanceAdjustments :many
+SELECT * FROM balance_adjustments
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,44 @@
+-- name: CreateBeneficiary :one
+-- Saving an account the owner deleted restores its beneficiary with the first created_at, so deleting
+-- and saving it again doesn't restart the cooling-off period. Returns no rows if the account is already saved.
+INSERT INTO beneficiaries (
+  owner,
+  nickname,
+  account_id,
+  currency
+) VALUES (
+  $1, $2, $3, $4
+) ON CONFLICT (owner, account_id) DO UPDATE
+SET
+  nickname = EXCLUDED.nickname,
+  currency = EXCLUDED.currency,
+  deleted_at = NULL
+WHERE beneficiaries.deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: GetBeneficiary :one
+SELECT * FROM beneficiaries
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetBeneficiaryByAccount :one
+-- Deleted beneficiaries are returned too, since the cooling-off period of their account still applies.
+SELECT * FROM beneficiaries
+WHERE owner = $1 AND account_id = $2 LIMIT 1;
+
+-- name: ListBeneficiaries :many
+SELECT * FROM beneficiaries
+WHERE owner = sqlc.arg(owner) AND id > sqlc.arg(after_id) AND deleted_at IS NULL
+ORDER BY id
+LIMIT sqlc.arg(page_limit);
+
+-- name: UpdateBeneficiary :one
+UPDATE beneficiaries
+SET nickname = $2
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteBeneficiary :exec
+-- The row is kept, so the cooling-off period of the account outlives the beneficiary.
+UPDATE beneficiaries
+SET deleted_at = now()
+WHERE id = $1 AND deleted_at IS NULL;
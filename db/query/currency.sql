@@ -0,0 +1,8 @@
+-- name: GetCurrency :one
+SELECT * FROM currencies
+WHERE code = $1
+LIMIT 1;
+
+-- name: ListCurrencies :many
+SELECT * FROM currencies
+ORDER BY code;
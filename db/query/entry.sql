@@ -1,9 +1,12 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id,
+  description,
+  balance_after
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4, $5
 ) RETURNING *;
 
 -- name: GetEntry :one
@@ -14,7 +17,36 @@ LIMIT 1;
 -- name: ListEntries :many
 SELECT *
 FROM entries
-WHERE account_id = $3
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id)
 ORDER BY id
-LIMIT $1
-OFFSET $2;
\ No newline at end of file
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListStatementEntries :many
+-- Every entry records the balance it left its account with, so a page only reads its own entries.
+SELECT
+  entries.id,
+  entries.account_id,
+  entries.amount,
+  entries.transfer_id,
+  entries.description,
+  entries.created_at,
+  entries.balance_after,
+  counterparty.id AS counterparty_account_id,
+  counterparty.owner AS counterparty_owner
+FROM entries
+LEFT JOIN transfers ON transfers.id = entries.transfer_id
+LEFT JOIN accounts AS counterparty ON counterparty.id = CASE
+  WHEN transfers.from_account_id = entries.account_id THEN transfers.to_account_id
+  ELSE transfers.from_account_id
+END
+WHERE entries.account_id = sqlc.arg(account_id)
+  AND entries.id > sqlc.arg(after_id)
+  AND (sqlc.narg(from_time)::timestamptz IS NULL OR entries.created_at >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamptz IS NULL OR entries.created_at < sqlc.narg(to_time))
+  AND (
+    sqlc.arg(direction)::varchar = ''
+    OR (sqlc.arg(direction) = 'credit' AND entries.amount > 0)
+    OR (sqlc.arg(direction) = 'debit' AND entries.amount < 0)
+  )
+ORDER BY entries.id
+LIMIT sqlc.arg(page_limit);
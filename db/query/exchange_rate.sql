@@ -0,0 +1,47 @@
+-- name: CreateExchangeRate :execrows
+-- A rate already stored for the same pair and valid_from is left as is, and so is a rate
+-- equal to the one already in effect at valid_from.
+INSERT INTO exchange_rates (
+  base_currency,
+  quote_currency,
+  rate,
+  valid_from,
+  source
+)
+SELECT sqlc.arg(base_currency)::varchar, sqlc.arg(quote_currency)::varchar, sqlc.arg(rate)::numeric, sqlc.arg(valid_from)::timestamptz, sqlc.arg(source)::varchar
+WHERE NOT EXISTS (
+  SELECT 1 FROM (
+    SELECT rate FROM exchange_rates
+    WHERE base_currency = sqlc.arg(base_currency)
+      AND quote_currency = sqlc.arg(quote_currency)
+      AND valid_from <= sqlc.arg(valid_from)
+    ORDER BY valid_from DESC
+    LIMIT 1
+  ) AS latest
+  WHERE latest.rate = sqlc.arg(rate)
+)
+ON CONFLICT (base_currency, quote_currency, valid_from) DO NOTHING;
+
+-- name: GetExchangeRate :one
+-- Returns the rate in effect at the given time.
+SELECT * FROM exchange_rates
+WHERE base_currency = sqlc.arg(base_currency)
+  AND quote_currency = sqlc.arg(quote_currency)
+  AND valid_from <= sqlc.arg(at)
+ORDER BY valid_from DESC
+LIMIT 1;
+
+-- name: ListLatestExchangeRates :many
+SELECT DISTINCT ON (base_currency, quote_currency) *
+FROM exchange_rates
+WHERE valid_from <= now()
+ORDER BY base_currency, quote_currency, valid_from DESC;
+
+-- name: ListExchangeRateHistory :many
+-- Lists the rates of a pair, newest first.
+SELECT * FROM exchange_rates
+WHERE base_currency = sqlc.arg(base_currency)
+  AND quote_currency = sqlc.arg(quote_currency)
+  AND (sqlc.narg(before)::timestamptz IS NULL OR valid_from < sqlc.narg(before))
+ORDER BY valid_from DESC
+LIMIT sqlc.arg(page_limit);
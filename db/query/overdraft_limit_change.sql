@@ -0,0 +1,15 @@
+-- name: CreateOverdraftLimitChange :one
+INSERT INTO overdraft_limit_changes (
+  account_id,
+  old_limit,
+  new_limit,
+  changed_by
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListOverdraftLimitChanges :many
+SELECT * FROM overdraft_limit_changes
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_limit);
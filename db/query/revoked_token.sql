@@ -0,0 +1,13 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (
+  id,
+  username,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) ON CONFLICT (id) DO NOTHING;
+
+-- name: ListRevokedTokensSince :many
+SELECT * FROM revoked_tokens
+WHERE revoked_at > $1 AND expires_at > now()
+ORDER BY revoked_at;
\ No newline at end of file
@@ -0,0 +1,87 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner,
+  from_account_id,
+  to_account_id,
+  amount,
+  currency,
+  description,
+  frequency,
+  start_at,
+  end_at,
+  max_runs,
+  insufficient_funds_policy,
+  max_retries,
+  next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $8
+) RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: ListScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE owner = sqlc.arg(owner) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_limit);
+
+-- name: UpdateScheduledTransfer :one
+-- Only active schedules can change. The executor checks end_at and max_runs again before each run.
+UPDATE scheduled_transfers
+SET
+  amount = COALESCE(sqlc.narg(amount), amount),
+  description = COALESCE(sqlc.narg(description), description),
+  end_at = COALESCE(sqlc.narg(end_at), end_at),
+  max_runs = COALESCE(sqlc.narg(max_runs), max_runs),
+  insufficient_funds_policy = COALESCE(sqlc.narg(insufficient_funds_policy), insufficient_funds_policy),
+  max_retries = COALESCE(sqlc.narg(max_retries), max_retries)
+WHERE id = sqlc.arg(id) AND status = 'active'
+RETURNING *;
+
+-- name: CancelScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  status = 'cancelled',
+  next_run_at = NULL
+WHERE id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: ListDueScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE status = 'active' AND next_run_at <= sqlc.arg(now)
+ORDER BY next_run_at, id
+LIMIT sqlc.arg(page_limit);
+
+-- name: AdvanceScheduledTransfer :one
+-- Moves a schedule on from the run that was due at from_next_run_at.
+-- Returns no rows if another executor already moved it on or the schedule is no longer active.
+UPDATE scheduled_transfers
+SET
+  runs = sqlc.arg(runs),
+  attempts = sqlc.arg(attempts),
+  next_run_at = sqlc.narg(next_run_at),
+  status = sqlc.arg(status)
+WHERE id = sqlc.arg(id) AND status = 'active' AND next_run_at = sqlc.arg(from_next_run_at)
+RETURNING *;
+
+-- name: CreateScheduledTransferExecution :one
+-- Returns no rows if the transfer of a succeeded attempt was already recorded.
+INSERT INTO scheduled_transfer_executions (
+  scheduled_transfer_id,
+  scheduled_for,
+  attempt,
+  status,
+  transfer_id,
+  error
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) ON CONFLICT (transfer_id) DO NOTHING
+RETURNING *;
+
+-- name: ListScheduledTransferExecutions :many
+SELECT * FROM scheduled_transfer_executions
+WHERE scheduled_transfer_id = sqlc.arg(scheduled_transfer_id) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_limit);
@@ -2,9 +2,16 @@
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  to_amount,
+  exchange_rate,
+  description,
+  reference,
+  metadata,
+  reversed_transfer_id,
+  status
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 ) RETURNING *;
 
 -- name: GetTransfer :one
@@ -12,10 +19,82 @@ SELECT * FROM transfers
 WHERE id = $1
 LIMIT 1;
 
+-- name: GetTransferForUpdate :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: AddTransferReversedAmount :one
+UPDATE transfers
+SET reversed_amount = reversed_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateTransferStatus :one
+UPDATE transfers
+SET status = sqlc.arg(status)
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(from_status)
+RETURNING *;
+
 -- name: ListTransfers :many
 SELECT *
 FROM transfers
-WHERE from_account_id = $3 OR to_account_id = $4  -- List transfers involving a specific account (either sender or receiver)
+WHERE (from_account_id = sqlc.arg(account_id) OR to_account_id = sqlc.arg(account_id))  -- List transfers involving a specific account (either sender or receiver)
+  AND id > sqlc.arg(after_id)
 ORDER BY id
-LIMIT $1
-OFFSET $2;
\ No newline at end of file
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetTransferWithOwners :one
+SELECT
+  transfers.*,
+  from_account.owner AS from_owner,
+  to_account.owner AS to_owner,
+  from_account.currency AS from_currency,
+  to_account.currency AS to_currency
+FROM transfers
+JOIN accounts AS from_account ON from_account.id = transfers.from_account_id
+JOIN accounts AS to_account ON to_account.id = transfers.to_account_id
+WHERE transfers.id = $1
+LIMIT 1;
+
+-- name: ListUserTransfers :many
+-- Lists transfers touching any account of the owner. Direction and counterparty are seen from the owner's side.
+SELECT
+  transfers.*,
+  from_account.currency AS from_currency,
+  to_account.currency AS to_currency
+FROM transfers
+JOIN accounts AS from_account ON from_account.id = transfers.from_account_id
+JOIN accounts AS to_account ON to_account.id = transfers.to_account_id
+WHERE (from_account.owner = sqlc.arg(owner) OR to_account.owner = sqlc.arg(owner))
+  AND transfers.id > sqlc.arg(after_id)
+  AND (
+    sqlc.narg(account_id)::bigint IS NULL
+    OR (transfers.from_account_id = sqlc.narg(account_id) AND from_account.owner = sqlc.arg(owner))
+    OR (transfers.to_account_id = sqlc.narg(account_id) AND to_account.owner = sqlc.arg(owner))
+  )
+  AND (
+    sqlc.arg(direction)::varchar = ''
+    OR (
+      sqlc.arg(direction) = 'outgoing' AND from_account.owner = sqlc.arg(owner)
+      AND (sqlc.narg(account_id) IS NULL OR transfers.from_account_id = sqlc.narg(account_id))
+    )
+    OR (
+      sqlc.arg(direction) = 'incoming' AND to_account.owner = sqlc.arg(owner)
+      AND (sqlc.narg(account_id) IS NULL OR transfers.to_account_id = sqlc.narg(account_id))
+    )
+  )
+  AND (
+    sqlc.narg(counterparty_account_id)::bigint IS NULL
+    OR (transfers.from_account_id = sqlc.narg(counterparty_account_id) AND to_account.owner = sqlc.arg(owner))
+    OR (transfers.to_account_id = sqlc.narg(counterparty_account_id) AND from_account.owner = sqlc.arg(owner))
+  )
+  AND (sqlc.narg(min_amount)::bigint IS NULL OR transfers.amount >= sqlc.narg(min_amount))
+  AND (sqlc.narg(max_amount)::bigint IS NULL OR transfers.amount <= sqlc.narg(max_amount))
+  AND (sqlc.narg(from_time)::timestamptz IS NULL OR transfers.created_at >= sqlc.narg(from_time))
+  AND (sqlc.narg(to_time)::timestamptz IS NULL OR transfers.created_at < sqlc.narg(to_time))
+  AND (sqlc.narg(reference)::varchar IS NULL OR transfers.reference = sqlc.narg(reference))
+  AND (sqlc.narg(description)::varchar IS NULL OR strpos(lower(transfers.description), lower(sqlc.narg(description))) > 0)
+  AND (sqlc.narg(metadata)::varchar IS NULL OR transfers.metadata @> sqlc.narg(metadata)::jsonb)
+ORDER BY transfers.id
+LIMIT sqlc.arg(page_limit);
@@ -10,4 +10,21 @@ INSERT INTO users (
 
 -- name: GetUser :one
 SELECT * FROM users
-WHERE username = $1 LIMIT 1;
\ No newline at end of file
+WHERE username = $1 LIMIT 1;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE username > sqlc.arg(after_username)
+ORDER BY username
+LIMIT sqlc.arg(page_limit);
+
+-- name: RevokeUserTokens :one
+UPDATE users
+SET tokens_revoked_at = now()
+WHERE username = $1
+RETURNING *;
+
+-- name: ListUserTokenRevocationsSince :many
+SELECT username, tokens_revoked_at FROM users
+WHERE tokens_revoked_at > $1
+ORDER BY tokens_revoked_at;
\ No newline at end of file
@@ -7,13 +7,14 @@ package db
 
 import (
 	"context"
+	"database/sql"
 )
 
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount
 `
 
 type AddAccountBalanceParams struct {
@@ -30,6 +31,41 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
+	)
+	return i, err
+}
+
+const addAccountHeldAmount = `-- name: AddAccountHeldAmount :one
+UPDATE accounts
+SET held_amount = held_amount + $1
+WHERE id = $2
+RETURNING id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount
+`
+
+type AddAccountHeldAmountParams struct {
+	Amount int64 `json:"amount"`
+	ID     int64 `json:"id"`
+}
+
+func (q *Queries) AddAccountHeldAmount(ctx context.Context, arg AddAccountHeldAmountParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, addAccountHeldAmount, arg.Amount, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
 	)
 	return i, err
 }
@@ -41,7 +77,7 @@ INSERT INTO accounts (
   currency
 ) VALUES (
   $1, $2, $3
-) RETURNING id, owner, balance, currency, created_at
+) RETURNING id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount
 `
 
 type CreateAccountParams struct {
@@ -59,6 +95,11 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
 	)
 	return i, err
 }
@@ -74,7 +115,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount FROM accounts
 WHERE id = $1 LIMIT 1
 `
 
@@ -87,26 +128,138 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
+	)
+	return i, err
+}
+
+const getAccountForUpdate = `-- name: GetAccountForUpdate :one
+SELECT id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount FROM accounts
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccountForUpdate, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
+	)
+	return i, err
+}
+
+const getFXPositionAccount = `-- name: GetFXPositionAccount :one
+SELECT id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount FROM accounts
+WHERE kind = 'fx_position' AND currency = $1
+LIMIT 1
+`
+
+func (q *Queries) GetFXPositionAccount(ctx context.Context, currency string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getFXPositionAccount, currency)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
+	)
+	return i, err
+}
+
+const getPayee = `-- name: GetPayee :one
+SELECT a.id AS account_id, a.owner, a.currency, u.full_name FROM accounts a
+JOIN users u ON u.username = a.owner
+WHERE (u.username = $1 OR lower(u.email) = lower($2))
+  AND a.currency = $3
+  AND a.kind = 'customer'
+  AND a.status = 'active'
+LIMIT 1
+`
+
+type GetPayeeParams struct {
+	Username sql.NullString `json:"username"`
+	Email    sql.NullString `json:"email"`
+	Currency string         `json:"currency"`
+}
+
+type GetPayeeRow struct {
+	AccountID int64  `json:"account_id"`
+	Owner     string `json:"owner"`
+	Currency  string `json:"currency"`
+	FullName  string `json:"full_name"`
+}
+
+// Resolves a payee, known by username or email, to their active customer account in a currency.
+func (q *Queries) GetPayee(ctx context.Context, arg GetPayeeParams) (GetPayeeRow, error) {
+	row := q.db.QueryRowContext(ctx, getPayee, arg.Username, arg.Email, arg.Currency)
+	var i GetPayeeRow
+	err := row.Scan(
+		&i.AccountID,
+		&i.Owner,
+		&i.Currency,
+		&i.FullName,
+	)
+	return i, err
+}
+
+const getSuspenseAccount = `-- name: GetSuspenseAccount :one
+SELECT id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount FROM accounts
+WHERE kind = 'suspense' AND currency = $1
+LIMIT 1
+`
+
+func (q *Queries) GetSuspenseAccount(ctx context.Context, currency string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getSuspenseAccount, currency)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
 	)
 	return i, err
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
-WHERE owner = $1
+SELECT id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount FROM accounts
+WHERE owner = $1 AND id > $2
 ORDER BY id
-LIMIT $2
-OFFSET $3
+LIMIT $3
 `
 
 type ListAccountsParams struct {
-	Owner  string `json:"owner"`
-	Limit  int32  `json:"limit"`
-	Offset int32  `json:"offset"`
+	Owner     string `json:"owner"`
+	AfterID   int64  `json:"after_id"`
+	PageLimit int32  `json:"page_limit"`
 }
 
 func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error) {
-	rows, err := q.db.QueryContext(ctx, listAccounts, arg.Owner, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listAccounts, arg.Owner, arg.AfterID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +273,11 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.Status,
+			&i.Nickname,
+			&i.Kind,
+			&i.OverdraftLimit,
+			&i.HeldAmount,
 		); err != nil {
 			return nil, err
 		}
@@ -136,18 +294,79 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 
 const updateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
-SET balance = $2
-WHERE id = $1
-RETURNING id, owner, balance, currency, created_at
+SET nickname = COALESCE($1, nickname)
+WHERE id = $2
+RETURNING id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount
 `
 
 type UpdateAccountParams struct {
-	ID      int64 `json:"id"`
-	Balance int64 `json:"balance"`
+	Nickname sql.NullString `json:"nickname"`
+	ID       int64          `json:"id"`
 }
 
 func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error) {
-	row := q.db.QueryRowContext(ctx, updateAccount, arg.ID, arg.Balance)
+	row := q.db.QueryRowContext(ctx, updateAccount, arg.Nickname, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
+	)
+	return i, err
+}
+
+const updateAccountOverdraftLimit = `-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount
+`
+
+type UpdateAccountOverdraftLimitParams struct {
+	ID             int64 `json:"id"`
+	OverdraftLimit int64 `json:"overdraft_limit"`
+}
+
+func (q *Queries) UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountOverdraftLimit, arg.ID, arg.OverdraftLimit)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
+	)
+	return i, err
+}
+
+const updateAccountStatus = `-- name: UpdateAccountStatus :one
+UPDATE accounts
+SET status = $1
+WHERE id = $2 AND status = $3
+RETURNING id, owner, balance, currency, created_at, status, nickname, kind, overdraft_limit, held_amount
+`
+
+type UpdateAccountStatusParams struct {
+	Status     string `json:"status"`
+	ID         int64  `json:"id"`
+	FromStatus string `json:"from_status"`
+}
+
+func (q *Queries) UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountStatus, arg.Status, arg.ID, arg.FromStatus)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -155,6 +374,11 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Status,
+		&i.Nickname,
+		&i.Kind,
+		&i.OverdraftLimit,
+		&i.HeldAmount,
 	)
 	return i, err
 }
@@ -0,0 +1,8 @@
+package db
+
+// Account kinds stored in accounts.kind
+const (
+	AccountKindCustomer   = "customer"    // AccountKindCustomer accounts belong to a bank customer
+	AccountKindSuspense   = "suspense"    // AccountKindSuspense accounts absorb the other side of balance adjustments
+	AccountKindFXPosition = "fx_position" // AccountKindFXPosition accounts carry the bank's position in a currency from cross-currency transfers
+)
@@ -0,0 +1,40 @@
+package db
+
+import "fmt"
+
+// Account statuses stored in accounts.status
+const (
+	AccountStatusActive = "active" // AccountStatusActive accounts can send and receive money
+	AccountStatusFrozen = "frozen" // AccountStatusFrozen accounts are blocked by a banker and cannot move money
+	AccountStatusClosed = "closed" // AccountStatusClosed accounts are closed for good but stay readable for statements
+)
+
+// accountStatusTransitions lists the statuses an account may move to from each status.
+var accountStatusTransitions = map[string][]string{
+	AccountStatusActive: {AccountStatusFrozen, AccountStatusClosed},
+	AccountStatusFrozen: {AccountStatusActive},
+}
+
+// CanTransitionAccountStatus reports whether an account may move from one status to another.
+func CanTransitionAccountStatus(from string, to string) bool {
+	for _, status := range accountStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccountActive returns ErrAccountFrozen or ErrAccountClosed, wrapped with the account ID,
+// if the account cannot move money.
+func checkAccountActive(account Account) error {
+	switch account.Status {
+	case AccountStatusActive:
+		return nil
+	case AccountStatusFrozen:
+		return fmt.Errorf("account [%d]: %w", account.ID, ErrAccountFrozen)
+	case AccountStatusClosed:
+		return fmt.Errorf("account [%d]: %w", account.ID, ErrAccountClosed)
+	}
+	return fmt.Errorf("account [%d] has unknown status %s", account.ID, account.Status)
+}
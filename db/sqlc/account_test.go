@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"testing"
 	"time"
 
@@ -58,8 +59,8 @@ func TestUpdateAccount(t *testing.T) {
 	account1 := createRandomAccount(t) // Create an account to update
 
 	arg := UpdateAccountParams{
-		ID:      account1.ID,
-		Balance: util.RandomMoney(), // Update balance with a new random amount
+		ID:       account1.ID,
+		Nickname: sql.NullString{String: util.RandomString(8), Valid: true}, // Update nickname with a new random name
 	}
 
 	account2, err := testQueries.UpdateAccount(context.Background(), arg) // Update the account
@@ -69,7 +70,8 @@ func TestUpdateAccount(t *testing.T) {
 	require.Equal(t, account1.ID, account2.ID)                                     // IDs should be the same
 	require.Equal(t, account1.Owner, account2.Owner)                               // Owner should remain the same
 	require.Equal(t, account1.Currency, account2.Currency)                         // Currency should remain the same
-	require.Equal(t, arg.Balance, account2.Balance)                                // Balance should be updated to the new random amount
+	require.Equal(t, account1.Balance, account2.Balance)                           // Balance can't be changed by UpdateAccount
+	require.Equal(t, arg.Nickname.String, account2.Nickname)                       // Nickname should be updated to the new random name
 	require.WithinDuration(t, account1.CreatedAt, account2.CreatedAt, time.Second) // CreatedAt should be roughly the same
 }
 
@@ -94,12 +96,12 @@ func TestListAccounts(t *testing.T) {
 	}
 
 	arg := ListAccountsParams{
-		Owner:  lastAccount.Owner,
-		Limit:  5, // Limit to 5 accounts per page
-		Offset: 0, // Start from the 6th account (for the second "page")
+		Owner:     lastAccount.Owner,
+		AfterID:   0, // Start from the first account
+		PageLimit: 5, // Limit to 5 accounts per page
 	}
 
-	accounts, err := testQueries.ListAccounts(context.Background(), arg) // List accounts with limit and offset
+	accounts, err := testQueries.ListAccounts(context.Background(), arg) // List accounts after the cursor
 	require.NoError(t, err)
 	require.NotEmpty(t, accounts)
 
@@ -107,4 +109,96 @@ func TestListAccounts(t *testing.T) {
 		require.NotEmpty(t, account) // Ensure each account in the list is not empty
 		require.Equal(t, lastAccount.Owner, account.Owner)
 	}
+
+	arg.AfterID = accounts[len(accounts)-1].ID
+	accounts, err = testQueries.ListAccounts(context.Background(), arg) // The next page starts after the last account
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+}
+
+func TestUpdateAccountStatus(t *testing.T) {
+	account1 := createRandomAccount(t)
+	require.Equal(t, AccountStatusActive, account1.Status)
+
+	account2, err := testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:         account1.ID,
+		Status:     AccountStatusFrozen,
+		FromStatus: AccountStatusActive,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.Equal(t, AccountStatusFrozen, account2.Status)
+	require.Equal(t, account1.Balance, account2.Balance)
+
+	// The update only applies while the account is still in the expected status
+	_, err = testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:         account1.ID,
+		Status:     AccountStatusClosed,
+		FromStatus: AccountStatusActive,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestCanTransitionAccountStatus(t *testing.T) {
+	require.True(t, CanTransitionAccountStatus(AccountStatusActive, AccountStatusFrozen))
+	require.True(t, CanTransitionAccountStatus(AccountStatusFrozen, AccountStatusActive))
+	require.True(t, CanTransitionAccountStatus(AccountStatusActive, AccountStatusClosed))
+	require.False(t, CanTransitionAccountStatus(AccountStatusFrozen, AccountStatusClosed))
+	require.False(t, CanTransitionAccountStatus(AccountStatusClosed, AccountStatusActive))
+	require.False(t, CanTransitionAccountStatus(AccountStatusClosed, AccountStatusFrozen))
+	require.False(t, CanTransitionAccountStatus(AccountStatusActive, AccountStatusActive))
+}
+
+func TestGetSuspenseAccount(t *testing.T) {
+	for _, currency := range []string{util.USD, util.EUR, util.CAD} {
+		account, err := testQueries.GetSuspenseAccount(context.Background(), currency)
+		require.NoError(t, err)
+		require.Equal(t, AccountKindSuspense, account.Kind)
+		require.Equal(t, currency, account.Currency)
+	}
+}
+
+func TestGetFXPositionAccount(t *testing.T) {
+	for _, currency := range []string{util.USD, util.EUR, util.CAD} {
+		account, err := testQueries.GetFXPositionAccount(context.Background(), currency)
+		require.NoError(t, err)
+		require.Equal(t, AccountKindFXPosition, account.Kind)
+		require.Equal(t, currency, account.Currency)
+	}
+}
+
+func TestGetPayee(t *testing.T) {
+	account := createRandomAccount(t)
+	user, err := testQueries.GetUser(context.Background(), account.Owner)
+	require.NoError(t, err)
+
+	payee, err := testQueries.GetPayee(context.Background(), GetPayeeParams{
+		Username: sql.NullString{String: user.Username, Valid: true},
+		Currency: account.Currency,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account.ID, payee.AccountID)
+	require.Equal(t, user.FullName, payee.FullName)
+
+	// Emails are matched ignoring case
+	payee, err = testQueries.GetPayee(context.Background(), GetPayeeParams{
+		Email:    sql.NullString{String: strings.ToUpper(user.Email), Valid: true},
+		Currency: account.Currency,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account.ID, payee.AccountID)
+
+	// Only active accounts receive money from payee transfers
+	_, err = testQueries.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:         account.ID,
+		Status:     AccountStatusFrozen,
+		FromStatus: AccountStatusActive,
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.GetPayee(context.Background(), GetPayeeParams{
+		Username: sql.NullString{String: user.Username, Valid: true},
+		Currency: account.Currency,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
 }
@@ -0,0 +1,10 @@
+package db
+
+// Reason codes stored in balance_adjustments.reason_code
+const (
+	AdjustmentReasonCorrection = "correction" // AdjustmentReasonCorrection fixes a posting error
+	AdjustmentReasonFee        = "fee"        // AdjustmentReasonFee charges a fee to the account
+	AdjustmentReasonFeeRefund  = "fee_refund" // AdjustmentReasonFeeRefund gives a fee back
+	AdjustmentReasonInterest   = "interest"   // AdjustmentReasonInterest pays out interest
+	AdjustmentReasonWriteOff   = "write_off"  // AdjustmentReasonWriteOff writes off a balance the bank will not collect
+)
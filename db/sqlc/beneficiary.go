@@ -0,0 +1,27 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+)
+
+// CoolingOffUntil returns the end of the cooling-off period of the beneficiary, which starts when it is first saved.
+func (beneficiary Beneficiary) CoolingOffUntil(period time.Duration) time.Time {
+	return beneficiary.CreatedAt.Add(period)
+}
+
+// CheckCoolingOff returns ErrCoolingOffLimitExceeded if an amount of the currency paid into the account of the
+// beneficiary at the given time is above the cooling-off limit of the currency. The limit only applies until
+// the cooling-off period is over.
+func (beneficiary Beneficiary) CheckCoolingOff(period time.Duration, currency Currency, amount int64, at time.Time) error {
+	until := beneficiary.CoolingOffUntil(period)
+	if !at.Before(until) || amount <= currency.BeneficiaryCoolingOffLimit {
+		return nil
+	}
+
+	return fmt.Errorf("%w: beneficiary [%d] is new, transfers to its account are limited to %s %s until %s",
+		ErrCoolingOffLimitExceeded, beneficiary.ID, util.FormatAmount(currency.BeneficiaryCoolingOffLimit, currency.MinorUnit),
+		currency.Code, until.Format(time.RFC3339))
+}
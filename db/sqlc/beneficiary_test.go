@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomBeneficiary(t *testing.T) Beneficiary {
+	owner := createRandomUser(t)
+	account := createRandomAccount(t)
+
+	arg := CreateBeneficiaryParams{
+		Owner:     owner.Username,
+		Nickname:  util.RandomString(8),
+		AccountID: account.ID,
+		Currency:  account.Currency,
+	}
+
+	beneficiary, err := testQueries.CreateBeneficiary(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, beneficiary.ID)
+
+	require.Equal(t, arg.Owner, beneficiary.Owner)
+	require.Equal(t, arg.Nickname, beneficiary.Nickname)
+	require.Equal(t, arg.AccountID, beneficiary.AccountID)
+	require.Equal(t, arg.Currency, beneficiary.Currency)
+	require.NotZero(t, beneficiary.CreatedAt)
+
+	return beneficiary
+}
+
+func TestCreateBeneficiary(t *testing.T) {
+	beneficiary := createRandomBeneficiary(t)
+
+	// The same account can't be saved twice by an owner, nor two accounts under one nickname
+	_, err := testQueries.CreateBeneficiary(context.Background(), CreateBeneficiaryParams{
+		Owner:     beneficiary.Owner,
+		Nickname:  util.RandomString(8),
+		AccountID: beneficiary.AccountID,
+		Currency:  beneficiary.Currency,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	account := createRandomAccount(t)
+	_, err = testQueries.CreateBeneficiary(context.Background(), CreateBeneficiaryParams{
+		Owner:     beneficiary.Owner,
+		Nickname:  beneficiary.Nickname,
+		AccountID: account.ID,
+		Currency:  account.Currency,
+	})
+	require.Error(t, err)
+}
+
+func TestGetBeneficiary(t *testing.T) {
+	beneficiary1 := createRandomBeneficiary(t)
+
+	beneficiary2, err := testQueries.GetBeneficiary(context.Background(), beneficiary1.ID)
+	require.NoError(t, err)
+	require.Equal(t, beneficiary1.ID, beneficiary2.ID)
+	require.Equal(t, beneficiary1.Owner, beneficiary2.Owner)
+	require.Equal(t, beneficiary1.Nickname, beneficiary2.Nickname)
+	require.WithinDuration(t, beneficiary1.CreatedAt, beneficiary2.CreatedAt, time.Second)
+}
+
+func TestGetBeneficiaryByAccount(t *testing.T) {
+	beneficiary1 := createRandomBeneficiary(t)
+
+	beneficiary2, err := testQueries.GetBeneficiaryByAccount(context.Background(), GetBeneficiaryByAccountParams{
+		Owner:     beneficiary1.Owner,
+		AccountID: beneficiary1.AccountID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, beneficiary1.ID, beneficiary2.ID)
+
+	// Another user paying the same account has not saved it
+	other := createRandomUser(t)
+	_, err = testQueries.GetBeneficiaryByAccount(context.Background(), GetBeneficiaryByAccountParams{
+		Owner:     other.Username,
+		AccountID: beneficiary1.AccountID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListBeneficiaries(t *testing.T) {
+	beneficiary := createRandomBeneficiary(t)
+
+	beneficiaries, err := testQueries.ListBeneficiaries(context.Background(), ListBeneficiariesParams{
+		Owner:     beneficiary.Owner,
+		PageLimit: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, beneficiaries, 1)
+	require.Equal(t, beneficiary.ID, beneficiaries[0].ID)
+
+	beneficiaries, err = testQueries.ListBeneficiaries(context.Background(), ListBeneficiariesParams{
+		Owner:     beneficiary.Owner,
+		AfterID:   beneficiary.ID,
+		PageLimit: 5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, beneficiaries)
+}
+
+func TestUpdateBeneficiary(t *testing.T) {
+	beneficiary1 := createRandomBeneficiary(t)
+	nickname := util.RandomString(8)
+
+	beneficiary2, err := testQueries.UpdateBeneficiary(context.Background(), UpdateBeneficiaryParams{
+		ID:       beneficiary1.ID,
+		Nickname: nickname,
+	})
+	require.NoError(t, err)
+	require.Equal(t, nickname, beneficiary2.Nickname)
+	require.Equal(t, beneficiary1.AccountID, beneficiary2.AccountID)
+	require.WithinDuration(t, beneficiary1.CreatedAt, beneficiary2.CreatedAt, time.Second)
+}
+
+func TestDeleteBeneficiary(t *testing.T) {
+	beneficiary1 := createRandomBeneficiary(t)
+
+	err := testQueries.DeleteBeneficiary(context.Background(), beneficiary1.ID)
+	require.NoError(t, err)
+
+	beneficiary2, err := testQueries.GetBeneficiary(context.Background(), beneficiary1.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Empty(t, beneficiary2)
+
+	// The cooling-off period of the account still applies
+	beneficiary3, err := testQueries.GetBeneficiaryByAccount(context.Background(), GetBeneficiaryByAccountParams{
+		Owner:     beneficiary1.Owner,
+		AccountID: beneficiary1.AccountID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, beneficiary1.ID, beneficiary3.ID)
+	require.True(t, beneficiary3.DeletedAt.Valid)
+	require.WithinDuration(t, beneficiary1.CreatedAt, beneficiary3.CreatedAt, time.Second)
+
+	beneficiaries, err := testQueries.ListBeneficiaries(context.Background(), ListBeneficiariesParams{
+		Owner:     beneficiary1.Owner,
+		PageLimit: 5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, beneficiaries)
+}
+
+func TestCreateBeneficiaryRestoresDeleted(t *testing.T) {
+	beneficiary1 := createRandomBeneficiary(t)
+
+	err := testQueries.DeleteBeneficiary(context.Background(), beneficiary1.ID)
+	require.NoError(t, err)
+
+	// Saving the account again doesn't restart its cooling-off period
+	nickname := util.RandomString(8)
+	beneficiary2, err := testQueries.CreateBeneficiary(context.Background(), CreateBeneficiaryParams{
+		Owner:     beneficiary1.Owner,
+		Nickname:  nickname,
+		AccountID: beneficiary1.AccountID,
+		Currency:  beneficiary1.Currency,
+	})
+	require.NoError(t, err)
+	require.Equal(t, beneficiary1.ID, beneficiary2.ID)
+	require.Equal(t, nickname, beneficiary2.Nickname)
+	require.False(t, beneficiary2.DeletedAt.Valid)
+	require.WithinDuration(t, beneficiary1.CreatedAt, beneficiary2.CreatedAt, time.Second)
+}
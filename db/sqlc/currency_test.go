@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCurrency(t *testing.T) {
+	testCases := []struct {
+		code        string
+		numericCode int32
+		minorUnit   int32
+	}{
+		{code: util.USD, numericCode: 840, minorUnit: 2},
+		{code: util.EUR, numericCode: 978, minorUnit: 2},
+		{code: util.CAD, numericCode: 124, minorUnit: 2},
+		{code: util.JPY, numericCode: 392, minorUnit: 0},
+		{code: util.KWD, numericCode: 414, minorUnit: 3},
+	}
+
+	for _, tc := range testCases {
+		currency, err := testQueries.GetCurrency(context.Background(), tc.code)
+		require.NoError(t, err)
+		require.Equal(t, tc.numericCode, currency.NumericCode)
+		require.Equal(t, tc.minorUnit, currency.MinorUnit)
+		require.True(t, currency.Enabled)
+		require.Positive(t, currency.BeneficiaryCoolingOffLimit)
+
+		// Every currency has the system accounts that balance its ledger
+		_, err = testQueries.GetSuspenseAccount(context.Background(), tc.code)
+		require.NoError(t, err)
+		_, err = testQueries.GetFXPositionAccount(context.Background(), tc.code)
+		require.NoError(t, err)
+	}
+
+	_, err := testQueries.GetCurrency(context.Background(), "XYZ")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListCurrencies(t *testing.T) {
+	currencies, err := testQueries.ListCurrencies(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(currencies), 5)
+
+	for i := 1; i < len(currencies); i++ {
+		require.Less(t, currencies[i-1].Code, currencies[i].Code)
+	}
+}
+
+func TestCreateAccountUnknownCurrency(t *testing.T) {
+	user := createRandomUser(t)
+
+	_, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Currency: "XYZ",
+	})
+	require.Error(t, err)
+}
@@ -7,36 +7,53 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createEntry = `-- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id,
+  description,
+  balance_after
 ) VALUES (
-  $1, $2
-) RETURNING id, account_id, amount, created_at
+  $1, $2, $3, $4, $5
+) RETURNING id, account_id, amount, created_at, transfer_id, description, balance_after
 `
 
 type CreateEntryParams struct {
-	AccountID int64 `json:"account_id"`
-	Amount    int64 `json:"amount"`
+	AccountID    int64  `json:"account_id"`
+	Amount       int64  `json:"amount"`
+	TransferID   *int64 `json:"transfer_id"`
+	Description  string `json:"description"`
+	BalanceAfter int64  `json:"balance_after"`
 }
 
 func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createEntry,
+		arg.AccountID,
+		arg.Amount,
+		arg.TransferID,
+		arg.Description,
+		arg.BalanceAfter,
+	)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
+		&i.Description,
+		&i.BalanceAfter,
 	)
 	return i, err
 }
 
 const getEntry = `-- name: GetEntry :one
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, transfer_id, description, balance_after FROM entries
 WHERE id = $1
 LIMIT 1
 `
@@ -49,27 +66,29 @@ func (q *Queries) GetEntry(ctx context.Context, id int64) (Entry, error) {
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
+		&i.Description,
+		&i.BalanceAfter,
 	)
 	return i, err
 }
 
 const listEntries = `-- name: ListEntries :many
-SELECT id, account_id, amount, created_at
+SELECT id, account_id, amount, created_at, transfer_id, description, balance_after
 FROM entries
-WHERE account_id = $3
+WHERE account_id = $1 AND id > $2
 ORDER BY id
-LIMIT $1
-OFFSET $2
+LIMIT $3
 `
 
 type ListEntriesParams struct {
-	Limit     int32 `json:"limit"`
-	Offset    int32 `json:"offset"`
 	AccountID int64 `json:"account_id"`
+	AfterID   int64 `json:"after_id"`
+	PageLimit int32 `json:"page_limit"`
 }
 
 func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error) {
-	rows, err := q.db.QueryContext(ctx, listEntries, arg.Limit, arg.Offset, arg.AccountID)
+	rows, err := q.db.QueryContext(ctx, listEntries, arg.AccountID, arg.AfterID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +101,101 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 			&i.AccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.TransferID,
+			&i.Description,
+			&i.BalanceAfter,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStatementEntries = `-- name: ListStatementEntries :many
+SELECT
+  entries.id,
+  entries.account_id,
+  entries.amount,
+  entries.transfer_id,
+  entries.description,
+  entries.created_at,
+  entries.balance_after,
+  counterparty.id AS counterparty_account_id,
+  counterparty.owner AS counterparty_owner
+FROM entries
+LEFT JOIN transfers ON transfers.id = entries.transfer_id
+LEFT JOIN accounts AS counterparty ON counterparty.id = CASE
+  WHEN transfers.from_account_id = entries.account_id THEN transfers.to_account_id
+  ELSE transfers.from_account_id
+END
+WHERE entries.account_id = $1
+  AND entries.id > $2
+  AND ($3::timestamptz IS NULL OR entries.created_at >= $3)
+  AND ($4::timestamptz IS NULL OR entries.created_at < $4)
+  AND (
+    $5::varchar = ''
+    OR ($5 = 'credit' AND entries.amount > 0)
+    OR ($5 = 'debit' AND entries.amount < 0)
+  )
+ORDER BY entries.id
+LIMIT $6
+`
+
+type ListStatementEntriesParams struct {
+	AccountID int64        `json:"account_id"`
+	AfterID   int64        `json:"after_id"`
+	FromTime  sql.NullTime `json:"from_time"`
+	ToTime    sql.NullTime `json:"to_time"`
+	Direction string       `json:"direction"`
+	PageLimit int32        `json:"page_limit"`
+}
+
+type ListStatementEntriesRow struct {
+	ID                    int64          `json:"id"`
+	AccountID             int64          `json:"account_id"`
+	Amount                int64          `json:"amount"`
+	TransferID            *int64         `json:"transfer_id"`
+	Description           string         `json:"description"`
+	CreatedAt             time.Time      `json:"created_at"`
+	BalanceAfter          int64          `json:"balance_after"`
+	CounterpartyAccountID sql.NullInt64  `json:"counterparty_account_id"`
+	CounterpartyOwner     sql.NullString `json:"counterparty_owner"`
+}
+
+// Every entry records the balance it left its account with, so a page only reads its own entries.
+func (q *Queries) ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStatementEntries,
+		arg.AccountID,
+		arg.AfterID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.Direction,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStatementEntriesRow{}
+	for rows.Next() {
+		var i ListStatementEntriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.TransferID,
+			&i.Description,
+			&i.CreatedAt,
+			&i.BalanceAfter,
+			&i.CounterpartyAccountID,
+			&i.CounterpartyOwner,
 		); err != nil {
 			return nil, err
 		}
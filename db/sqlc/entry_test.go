@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -12,9 +13,12 @@ import (
 
 // Helper function to create a random entry for testing
 func createRandomEntry(t *testing.T, account Account) Entry {
+	amount := util.RandomMoney()
+
 	arg := CreateEntryParams{
-		AccountID: account.ID,
-		Amount:    util.RandomMoney(),
+		AccountID:    account.ID,
+		Amount:       amount,
+		BalanceAfter: account.Balance + amount,
 	}
 
 	entry, err := testQueries.CreateEntry(context.Background(), arg)
@@ -23,6 +27,7 @@ func createRandomEntry(t *testing.T, account Account) Entry {
 
 	require.Equal(t, arg.AccountID, entry.AccountID)
 	require.Equal(t, arg.Amount, entry.Amount)
+	require.Equal(t, arg.BalanceAfter, entry.BalanceAfter)
 
 	require.NotZero(t, entry.ID)
 	require.NotZero(t, entry.CreatedAt)
@@ -57,10 +62,14 @@ func TestListEntries(t *testing.T) {
 
 	arg := ListEntriesParams{
 		AccountID: account.ID, // Still need AccountID for ListEntries
-		Limit:     5,
-		Offset:    5,
+		PageLimit: 5,
 	}
 
+	firstPage, err := testQueries.ListEntries(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, firstPage, 5)
+
+	arg.AfterID = firstPage[len(firstPage)-1].ID
 	entries, err := testQueries.ListEntries(context.Background(), arg)
 	require.NoError(t, err)
 	require.Len(t, entries, 5)
@@ -68,5 +77,93 @@ func TestListEntries(t *testing.T) {
 	for _, entry := range entries {
 		require.NotEmpty(t, entry)
 		require.Equal(t, arg.AccountID, entry.AccountID)
+		require.Greater(t, entry.ID, arg.AfterID)
+	}
+}
+
+func TestListStatementEntries(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+
+	// account2 has to match account1's currency to receive transfers
+	user := createRandomUser(t)
+	account2, err := store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  100,
+		Currency: account1.Currency,
+	})
+	require.NoError(t, err)
+
+	for _, amount := range []int64{30, 20} {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        util.NewMoney(amount, account1.Currency),
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        util.NewMoney(5, account2.Currency),
+	})
+	require.NoError(t, err)
+
+	entries, err := store.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// The initial balance of 100 is not an entry, running balances start from it
+	require.Equal(t, []int64{-30, -20, 5}, []int64{entries[0].Amount, entries[1].Amount, entries[2].Amount})
+	require.Equal(t, []int64{70, 50, 55}, []int64{entries[0].BalanceAfter, entries[1].BalanceAfter, entries[2].BalanceAfter})
+	for _, entry := range entries {
+		require.NotNil(t, entry.TransferID)
+		require.True(t, entry.CounterpartyAccountID.Valid)
+		require.Equal(t, account2.ID, entry.CounterpartyAccountID.Int64)
+		require.Equal(t, account2.Owner, entry.CounterpartyOwner.String)
 	}
+
+	// Filters don't change the balance an entry left behind
+	credits, err := store.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		Direction: "credit",
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, credits, 1)
+	require.Equal(t, int64(55), credits[0].BalanceAfter)
+
+	page, err := store.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		FromTime:  sql.NullTime{Time: entries[1].CreatedAt, Valid: true},
+		PageLimit: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, entries[1].ID, page[0].ID)
+	require.Equal(t, int64(50), page[0].BalanceAfter)
+
+	// nor does where the page starts
+	page, err = store.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		AfterID:   entries[1].ID,
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, entries[2].ID, page[0].ID)
+	require.Equal(t, int64(55), page[0].BalanceAfter)
+
+	none, err := store.ListStatementEntries(context.Background(), ListStatementEntriesParams{
+		AccountID: account1.ID,
+		ToTime:    sql.NullTime{Time: entries[0].CreatedAt, Valid: true},
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, none)
 }
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/lib/pq"
+)
+
+// ErrInsufficientFunds is returned when a transaction would take an account below its allowed balance.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrBalanceBelowOverdraftLimit is returned when an overdraft limit is lowered below what the account already owes.
+var ErrBalanceBelowOverdraftLimit = errors.New("account balance is below the new overdraft limit")
+
+// ErrAccountFrozen is returned when money would move in or out of a frozen account.
+var ErrAccountFrozen = errors.New("account is frozen")
+
+// ErrAccountClosed is returned when money would move in or out of a closed account.
+var ErrAccountClosed = errors.New("account is closed")
+
+// ErrNonZeroBalance is returned when an account that still holds or owes money would be closed.
+var ErrNonZeroBalance = errors.New("account balance is not zero")
+
+// ErrCurrencyMismatch is returned when money would move between accounts of different currencies.
+var ErrCurrencyMismatch = errors.New("account currencies do not match")
+
+// ErrNonPositiveAmount is returned when a transfer amount is zero or negative.
+var ErrNonPositiveAmount = errors.New("amount must be positive")
+
+// ErrInvalidExchangeRate is returned when a cross-currency transfer has no usable exchange rate.
+var ErrInvalidExchangeRate = errors.New("invalid exchange rate")
+
+// ErrAmountTooSmall is returned when an amount converts to nothing in the destination currency.
+var ErrAmountTooSmall = errors.New("amount is too small to convert")
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different request.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// ErrTransferAlreadyReversed is returned when a transfer that was already paid back in full would be reversed again.
+var ErrTransferAlreadyReversed = errors.New("transfer was already reversed in full")
+
+// ErrReversalExceedsTransfer is returned when a reversal would pay back more than is left of a transfer.
+var ErrReversalExceedsTransfer = errors.New("reversal amount exceeds what is left of the transfer")
+
+// ErrTransferIsReversal is returned when a reversal would itself be reversed.
+var ErrTransferIsReversal = errors.New("transfer is a reversal and cannot be reversed")
+
+// ErrInvalidTransferTransition is matched by every TransferStatusError, for callers that don't need the statuses.
+var ErrInvalidTransferTransition = errors.New("invalid transfer status transition")
+
+// ErrCoolingOffLimitExceeded is returned when a transfer into an account saved as a beneficiary during its
+// cooling-off period is above the cooling-off limit of its currency.
+var ErrCoolingOffLimitExceeded = errors.New("amount exceeds the cooling-off limit of a new beneficiary")
+
+// ErrFundsOnHold is returned when an account that still holds money for pending transfers would be closed.
+var ErrFundsOnHold = errors.New("account has funds on hold for pending transfers")
+
+// accountsBalanceCheck is the constraint that keeps customer balances from going below their overdraft limit.
+const accountsBalanceCheck = "accounts_balance_check"
+
+// balanceError maps a violation of accountsBalanceCheck to ErrInsufficientFunds and a balance that overflows
+// a bigint to util.ErrAmountOverflow. It returns other errors unchanged.
+func balanceError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Name() == "check_violation" && pqErr.Constraint == accountsBalanceCheck:
+			return ErrInsufficientFunds
+		case pqErr.Code.Name() == "numeric_value_out_of_range":
+			return util.ErrAmountOverflow
+		}
+	}
+	return err
+}
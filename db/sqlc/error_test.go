@@ -0,0 +1,20 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceError(t *testing.T) {
+	err := &pq.Error{Code: "23514", Constraint: accountsBalanceCheck}
+	require.ErrorIs(t, balanceError(err), ErrInsufficientFunds)
+
+	other := &pq.Error{Code: "23514", Constraint: "users_role_check"}
+	require.Equal(t, error(other), balanceError(other))
+
+	require.Equal(t, sql.ErrConnDone, balanceError(sql.ErrConnDone))
+	require.NoError(t, balanceError(nil))
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"math/big"
+	"strings"
+)
+
+// exchangeRateOne is stored on transfers within a currency.
+const exchangeRateOne = "1"
+
+// convertAmount converts an amount in minor units at a decimal exchange rate, rounding half up.
+// The rate is quoted between major units, so the result is scaled by the difference in minor units
+// of the two currencies: 1000 USD cents at 150 are 1500 JPY, 1000 JPY at 0.0067 are 670 cents.
+// It returns ErrInvalidExchangeRate if the rate is not a positive decimal, and ErrAmountTooSmall
+// if the converted amount rounds to zero.
+func convertAmount(amount int64, rate string, fromMinorUnit int32, toMinorUnit int32) (int64, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return 0, ErrInvalidExchangeRate
+	}
+
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), r)
+
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs32(toMinorUnit-fromMinorUnit))), nil))
+	if toMinorUnit > fromMinorUnit {
+		converted.Mul(converted, scale)
+	} else {
+		converted.Quo(converted, scale)
+	}
+
+	// floor(x + 1/2) = floor((2 * num + denom) / (2 * denom))
+	num := new(big.Int).Mul(converted.Num(), big.NewInt(2))
+	num.Add(num, converted.Denom())
+	denom := new(big.Int).Mul(converted.Denom(), big.NewInt(2))
+	rounded := new(big.Int).Quo(num, denom)
+
+	if !rounded.IsInt64() {
+		return 0, ErrInvalidExchangeRate
+	}
+	if rounded.Sign() <= 0 {
+		return 0, ErrAmountTooSmall
+	}
+	return rounded.Int64(), nil
+}
+
+// invertExchangeRate returns the rate of the opposite direction to 10 decimal places, e.g. 0.8 becomes 1.25.
+// It returns ErrInvalidExchangeRate if the rate is not a positive decimal.
+func invertExchangeRate(rate string) (string, error) {
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return "", ErrInvalidExchangeRate
+	}
+
+	inverted := strings.TrimRight(new(big.Rat).Inv(r).FloatString(10), "0")
+	return strings.TrimSuffix(inverted, "."), nil
+}
+
+// reversalToAmount returns what a reversal of amount, in the to currency of a cross-currency transfer, pays back
+// in its from currency. It goes by what the transfer moved rather than by its rate, so the reversals of a transfer
+// add up to exactly its amount once it is paid back in full.
+// It returns ErrAmountTooSmall if a partial reversal would pay back nothing.
+func reversalToAmount(transfer Transfer, amount int64) (int64, error) {
+	paidBack := func(reversed int64) *big.Int {
+		n := new(big.Int).Mul(big.NewInt(transfer.Amount), big.NewInt(reversed))
+		return n.Quo(n, big.NewInt(transfer.ToAmount))
+	}
+
+	toAmount := new(big.Int).Sub(paidBack(transfer.ReversedAmount+amount), paidBack(transfer.ReversedAmount))
+	if toAmount.Sign() <= 0 {
+		return 0, ErrAmountTooSmall
+	}
+	return toAmount.Int64(), nil
+}
+
+func abs32(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertAmount(t *testing.T) {
+	testCases := []struct {
+		name          string
+		amount        int64
+		rate          string
+		fromMinorUnit int32
+		toMinorUnit   int32
+		want          int64
+		err           error
+	}{
+		{name: "Exact", amount: 1000, rate: "0.92", fromMinorUnit: 2, toMinorUnit: 2, want: 920},
+		{name: "RoundDown", amount: 101, rate: "1.5049", fromMinorUnit: 2, toMinorUnit: 2, want: 152},
+		{name: "RoundHalfUp", amount: 1, rate: "1.5", fromMinorUnit: 2, toMinorUnit: 2, want: 2},
+		{name: "One", amount: 1234, rate: "1", fromMinorUnit: 2, toMinorUnit: 2, want: 1234},
+		{name: "TooSmall", amount: 1, rate: "0.4", fromMinorUnit: 2, toMinorUnit: 2, err: ErrAmountTooSmall},
+		{name: "ZeroRate", amount: 100, rate: "0", fromMinorUnit: 2, toMinorUnit: 2, err: ErrInvalidExchangeRate},
+		{name: "NegativeRate", amount: 100, rate: "-1.2", fromMinorUnit: 2, toMinorUnit: 2, err: ErrInvalidExchangeRate},
+		{name: "NotANumber", amount: 100, rate: "abc", fromMinorUnit: 2, toMinorUnit: 2, err: ErrInvalidExchangeRate},
+		{name: "ToZeroDecimals", amount: 1000, rate: "150", fromMinorUnit: 2, toMinorUnit: 0, want: 1500},
+		{name: "FromZeroDecimals", amount: 1000, rate: "0.0067", fromMinorUnit: 0, toMinorUnit: 2, want: 670},
+		{name: "ToThreeDecimals", amount: 1000, rate: "0.31", fromMinorUnit: 2, toMinorUnit: 3, want: 3100},
+		{name: "ScaledTooSmall", amount: 1, rate: "1.5", fromMinorUnit: 3, toMinorUnit: 0, err: ErrAmountTooSmall},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := convertAmount(tc.amount, tc.rate, tc.fromMinorUnit, tc.toMinorUnit)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestInvertExchangeRate(t *testing.T) {
+	for rate, want := range map[string]string{"0.8": "1.25", "2": "0.5", "1": "1", "0.92": "1.0869565217", "150": "0.0066666667"} {
+		inverted, err := invertExchangeRate(rate)
+		require.NoError(t, err)
+		require.Equal(t, want, inverted, rate)
+	}
+
+	for _, rate := range []string{"0", "-1.2", "abc"} {
+		_, err := invertExchangeRate(rate)
+		require.ErrorIs(t, err, ErrInvalidExchangeRate, rate)
+	}
+}
+
+func TestReversalToAmount(t *testing.T) {
+	// 500 USD cents were paid as 460 EUR cents
+	transfer := Transfer{Amount: 500, ToAmount: 460}
+
+	toAmount, err := reversalToAmount(transfer, 230)
+	require.NoError(t, err)
+	require.Equal(t, int64(250), toAmount)
+
+	// Rounding down along the way, the parts still add up to the whole
+	paidBack := int64(0)
+	for _, amount := range []int64{7, 100, 3, 350} {
+		toAmount, err := reversalToAmount(transfer, amount)
+		require.NoError(t, err)
+		paidBack += toAmount
+		transfer.ReversedAmount += amount
+	}
+	require.Equal(t, transfer.Amount, paidBack)
+
+	// A reversal too small to pay back a minor unit is refused
+	_, err = reversalToAmount(Transfer{Amount: 1, ToAmount: 150}, 1)
+	require.ErrorIs(t, err, ErrAmountTooSmall)
+}
+
+// storeExchangeRate stores a USD/EUR rate valid from the given time
+func storeExchangeRate(t *testing.T, rate string, validFrom time.Time) ExchangeRate {
+	arg := CreateExchangeRateParams{
+		BaseCurrency:  util.USD,
+		QuoteCurrency: util.EUR,
+		Rate:          rate,
+		ValidFrom:     validFrom,
+		Source:        "test",
+	}
+
+	rows, err := testQueries.CreateExchangeRate(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	// Storing the same pair and valid_from again is a no-op
+	rows, err = testQueries.CreateExchangeRate(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	stored, err := testQueries.GetExchangeRate(context.Background(), GetExchangeRateParams{
+		BaseCurrency:  arg.BaseCurrency,
+		QuoteCurrency: arg.QuoteCurrency,
+		At:            validFrom,
+	})
+	require.NoError(t, err)
+	require.Equal(t, arg.Source, stored.Source)
+	require.WithinDuration(t, validFrom, stored.ValidFrom, time.Second)
+	return stored
+}
+
+// randomRate returns a USD/EUR rate unlikely to equal the rate already in effect
+func randomRate() string {
+	return fmt.Sprintf("0.%06d", util.RandomInt(100_000, 999_999))
+}
+
+// randomPastTime returns a time far enough in the past not to collide with rates of other tests
+func randomPastTime() time.Time {
+	return time.Unix(util.RandomInt(0, 900_000_000), 0).UTC()
+}
+
+func TestGetExchangeRate(t *testing.T) {
+	validFrom := randomPastTime()
+	older := storeExchangeRate(t, randomRate(), validFrom)
+	newer := storeExchangeRate(t, randomRate(), validFrom.Add(time.Hour))
+
+	rate, err := testQueries.GetExchangeRate(context.Background(), GetExchangeRateParams{
+		BaseCurrency:  util.USD,
+		QuoteCurrency: util.EUR,
+		At:            validFrom.Add(30 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, older.ID, rate.ID)
+	require.Equal(t, older.Rate, rate.Rate)
+
+	rate, err = testQueries.GetExchangeRate(context.Background(), GetExchangeRateParams{
+		BaseCurrency:  util.USD,
+		QuoteCurrency: util.EUR,
+		At:            validFrom.Add(90 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, newer.ID, rate.ID)
+
+	_, err = testQueries.GetExchangeRate(context.Background(), GetExchangeRateParams{
+		BaseCurrency:  util.USD,
+		QuoteCurrency: util.EUR,
+		At:            time.Unix(-1, 0),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestCreateExchangeRateUnchanged(t *testing.T) {
+	validFrom := randomPastTime()
+	stored := storeExchangeRate(t, randomRate(), validFrom)
+
+	// The rate already in effect is not stored again with a later valid_from
+	rows, err := testQueries.CreateExchangeRate(context.Background(), CreateExchangeRateParams{
+		BaseCurrency:  util.USD,
+		QuoteCurrency: util.EUR,
+		Rate:          stored.Rate,
+		ValidFrom:     validFrom.Add(time.Second),
+		Source:        "test",
+	})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+}
+
+func TestListExchangeRateHistory(t *testing.T) {
+	validFrom := randomPastTime()
+	older := storeExchangeRate(t, randomRate(), validFrom)
+	newer := storeExchangeRate(t, randomRate(), validFrom.Add(time.Minute))
+
+	rates, err := testQueries.ListExchangeRateHistory(context.Background(), ListExchangeRateHistoryParams{
+		BaseCurrency:  util.USD,
+		QuoteCurrency: util.EUR,
+		Before:        sql.NullTime{Time: validFrom.Add(2 * time.Minute), Valid: true},
+		PageLimit:     2,
+	})
+	require.NoError(t, err)
+	require.Len(t, rates, 2)
+	require.Equal(t, newer.ID, rates[0].ID)
+	require.Equal(t, older.ID, rates[1].ID)
+}
+
+func TestListLatestExchangeRates(t *testing.T) {
+	storeExchangeRate(t, randomRate(), time.Now().Add(-time.Second).UTC().Truncate(time.Microsecond))
+
+	rates, err := testQueries.ListLatestExchangeRates(context.Background())
+	require.NoError(t, err)
+
+	pairs := make(map[string]bool)
+	for _, rate := range rates {
+		pair := rate.BaseCurrency + "/" + rate.QuoteCurrency
+		require.False(t, pairs[pair], pair)
+		require.False(t, rate.ValidFrom.After(time.Now()))
+		pairs[pair] = true
+	}
+	require.True(t, pairs[util.USD+"/"+util.EUR])
+}
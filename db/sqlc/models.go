@@ -5,7 +5,11 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -14,6 +18,57 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	// frozen and closed accounts cannot send or receive transfers, closed accounts stay readable
+	Status   string `json:"status"`
+	Nickname string `json:"nickname"`
+	// system accounts such as suspense balance the ledger and are not owned by a customer
+	Kind string `json:"kind"`
+	// how far below zero the balance may go
+	OverdraftLimit int64 `json:"overdraft_limit"`
+	// part of the balance held for pending transfers, it can't be spent
+	HeldAmount int64 `json:"held_amount"`
+}
+
+type BalanceAdjustment struct {
+	ID                int64 `json:"id"`
+	AccountID         int64 `json:"account_id"`
+	SuspenseAccountID int64 `json:"suspense_account_id"`
+	EntryID           int64 `json:"entry_id"`
+	SuspenseEntryID   int64 `json:"suspense_entry_id"`
+	// credited to the account and debited from the suspense account, can be negative
+	Amount     int64     `json:"amount"`
+	ReasonCode string    `json:"reason_code"`
+	Note       string    `json:"note"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Beneficiary struct {
+	ID       int64  `json:"id"`
+	Owner    string `json:"owner"`
+	Nickname string `json:"nickname"`
+	// account the owner pays when transferring to the beneficiary
+	AccountID int64 `json:"account_id"`
+	// currency of the account, checked when the beneficiary is saved
+	Currency string `json:"currency"`
+	// start of the cooling-off period, during which transfers to the beneficiary are capped
+	CreatedAt time.Time `json:"created_at"`
+	// set when the owner deletes the beneficiary, the row is kept for the cooling-off period of the account
+	DeletedAt sql.NullTime `json:"deleted_at"`
+}
+
+type Currency struct {
+	// ISO 4217 alphabetic code
+	Code string `json:"code"`
+	// ISO 4217 numeric code
+	NumericCode int32 `json:"numeric_code"`
+	// number of decimal places, amounts are stored in 10^-minor_unit of the currency
+	MinorUnit int32 `json:"minor_unit"`
+	// disabled currencies cannot be used for new accounts or transfers, existing accounts stay readable
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	// most that can be sent, in minor units, into an account saved as a beneficiary during its cooling-off period
+	BeneficiaryCoolingOffLimit int64 `json:"beneficiary_cooling_off_limit"`
 }
 
 type Entry struct {
@@ -22,15 +77,128 @@ type Entry struct {
 	// can be negative or positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// transfer the entry belongs to, null for balance adjustments
+	TransferID *int64 `json:"transfer_id"`
+	// description of the transfer the entry belongs to
+	Description string `json:"description"`
+	// balance of the account right after the entry was posted
+	BalanceAfter int64 `json:"balance_after"`
+}
+
+type ExchangeRate struct {
+	ID            int64  `json:"id"`
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+	// units of the quote currency bought by one unit of the base currency
+	Rate      string    `json:"rate"`
+	ValidFrom time.Time `json:"valid_from"`
+	// rate provider the rate was loaded from
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdempotencyKey struct {
+	Username string `json:"username"`
+	Key      string `json:"key"`
+	// fingerprint of the request the key was first used with
+	RequestHash string `json:"request_hash"`
+	TransferID  *int64 `json:"transfer_id"`
+	// transfer result returned to the first request and replayed to retries
+	Response  json.RawMessage `json:"response"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type OverdraftLimitChange struct {
+	ID        int64     `json:"id"`
+	AccountID int64     `json:"account_id"`
+	OldLimit  int64     `json:"old_limit"`
+	NewLimit  int64     `json:"new_limit"`
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RevokedToken struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	Owner         string `json:"owner"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	// must be positive, in currency, which is the currency of the from account
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Description string `json:"description"`
+	Frequency   string `json:"frequency"`
+	// first run, later runs of a recurring schedule follow it by day, week or calendar month
+	StartAt time.Time `json:"start_at"`
+	// no run is scheduled after it, null runs until max_runs or for ever
+	EndAt   sql.NullTime  `json:"end_at"`
+	MaxRuns sql.NullInt32 `json:"max_runs"`
+	// skip gives up on a run that lacks funds, retry tries it again up to max_retries times
+	InsufficientFundsPolicy string `json:"insufficient_funds_policy"`
+	MaxRetries              int32  `json:"max_retries"`
+	Status                  string `json:"status"`
+	// runs done, paid or given up, the next run is run number runs
+	Runs int32 `json:"runs"`
+	// failed attempts at the next run
+	Attempts int32 `json:"attempts"`
+	// when the executor picks the schedule up next, null once it is no longer active
+	NextRunAt sql.NullTime `json:"next_run_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type ScheduledTransferExecution struct {
+	ID                  int64 `json:"id"`
+	ScheduledTransferID int64 `json:"scheduled_transfer_id"`
+	// the run the attempt was for, retries share it
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Attempt      int32     `json:"attempt"`
+	Status       string    `json:"status"`
+	// transfer made by a succeeded attempt
+	TransferID *int64    `json:"transfer_id"`
+	Error      string    `json:"error"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	RefreshToken string    `json:"refresh_token"`
+	UserAgent    string    `json:"user_agent"`
+	ClientIp     string    `json:"client_ip"`
+	IsBlocked    bool      `json:"is_blocked"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Transfer struct {
 	ID            int64 `json:"id"`
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
-	// must be positive
+	// must be positive, in the currency of the from account
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// credited to the to account in its currency
+	ToAmount int64 `json:"to_amount"`
+	// units of the to currency bought by one unit of the from currency, 1 within a currency
+	ExchangeRate string `json:"exchange_rate"`
+	// free text shown to both sides of the transfer
+	Description string `json:"description"`
+	// set by the client to match the transfer with its own records, e.g. an invoice number
+	Reference string `json:"reference"`
+	// string keys and values set by the client
+	Metadata json.RawMessage `json:"metadata"`
+	// transfer this one pays back, in full or in part, null for an ordinary transfer
+	ReversedTransferID *int64 `json:"reversed_transfer_id"`
+	// part of to_amount already paid back by reversals, in the currency of the to account
+	ReversedAmount int64 `json:"reversed_amount"`
+	// pending transfers hold their amount on the from account, only completed ones have entries
+	Status string `json:"status"`
 }
 
 type User struct {
@@ -40,4 +208,7 @@ type User struct {
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	// tokens issued before this time are rejected
+	TokensRevokedAt time.Time `json:"tokens_revoked_at"`
+	Role            string    `json:"role"`
 }
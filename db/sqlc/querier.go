@@ -6,23 +6,93 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	AddAccountHeldAmount(ctx context.Context, arg AddAccountHeldAmountParams) (Account, error)
+	AddTransferReversedAmount(ctx context.Context, arg AddTransferReversedAmountParams) (Transfer, error)
+	// Moves a schedule on from the run that was due at from_next_run_at.
+	// Returns no rows if another executor already moved it on or the schedule is no longer active.
+	AdvanceScheduledTransfer(ctx context.Context, arg AdvanceScheduledTransferParams) (ScheduledTransfer, error)
+	BlockSession(ctx context.Context, id uuid.UUID) error
+	BlockUserSessions(ctx context.Context, username string) error
+	CancelScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateBalanceAdjustment(ctx context.Context, arg CreateBalanceAdjustmentParams) (BalanceAdjustment, error)
+	// Saving an account the owner deleted restores its beneficiary with the first created_at, so deleting
+	// and saving it again doesn't restart the cooling-off period. Returns no rows if the account is already saved.
+	CreateBeneficiary(ctx context.Context, arg CreateBeneficiaryParams) (Beneficiary, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	// A rate already stored for the same pair and valid_from is left as is, and so is a rate
+	// equal to the one already in effect at valid_from.
+	CreateExchangeRate(ctx context.Context, arg CreateExchangeRateParams) (int64, error)
+	// Returns no rows if the key is already taken. A concurrent insert of the same key waits for the first transaction to end.
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error)
+	CreateOverdraftLimitChange(ctx context.Context, arg CreateOverdraftLimitChangeParams) (OverdraftLimitChange, error)
+	CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error)
+	// Returns no rows if the transfer of a succeeded attempt was already recorded.
+	CreateScheduledTransferExecution(ctx context.Context, arg CreateScheduledTransferExecutionParams) (ScheduledTransferExecution, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	// The row is kept, so the cooling-off period of the account outlives the beneficiary.
+	DeleteBeneficiary(ctx context.Context, id int64) error
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetBalanceAdjustment(ctx context.Context, id int64) (BalanceAdjustment, error)
+	GetBeneficiary(ctx context.Context, id int64) (Beneficiary, error)
+	// Deleted beneficiaries are returned too, since the cooling-off period of their account still applies.
+	GetBeneficiaryByAccount(ctx context.Context, arg GetBeneficiaryByAccountParams) (Beneficiary, error)
+	GetCurrency(ctx context.Context, code string) (Currency, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	// Returns the rate in effect at the given time.
+	GetExchangeRate(ctx context.Context, arg GetExchangeRateParams) (ExchangeRate, error)
+	GetFXPositionAccount(ctx context.Context, currency string) (Account, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	// Resolves a payee, known by username or email, to their active customer account in a currency.
+	GetPayee(ctx context.Context, arg GetPayeeParams) (GetPayeeRow, error)
+	GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetSuspenseAccount(ctx context.Context, currency string) (Account, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error)
+	GetTransferWithOwners(ctx context.Context, id int64) (GetTransferWithOwnersRow, error)
 	GetUser(ctx context.Context, username string) (User, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListBalanceAdjustments(ctx context.Context, arg ListBalanceAdjustmentsParams) ([]BalanceAdjustment, error)
+	ListBeneficiaries(ctx context.Context, arg ListBeneficiariesParams) ([]Beneficiary, error)
+	ListCurrencies(ctx context.Context) ([]Currency, error)
+	ListDueScheduledTransfers(ctx context.Context, arg ListDueScheduledTransfersParams) ([]ScheduledTransfer, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	// Lists the rates of a pair, newest first.
+	ListExchangeRateHistory(ctx context.Context, arg ListExchangeRateHistoryParams) ([]ExchangeRate, error)
+	ListLatestExchangeRates(ctx context.Context) ([]ExchangeRate, error)
+	ListOverdraftLimitChanges(ctx context.Context, arg ListOverdraftLimitChangesParams) ([]OverdraftLimitChange, error)
+	ListRevokedTokensSince(ctx context.Context, revokedAt time.Time) ([]RevokedToken, error)
+	ListScheduledTransferExecutions(ctx context.Context, arg ListScheduledTransferExecutionsParams) ([]ScheduledTransferExecution, error)
+	ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error)
+	// Every entry records the balance it left its account with, so a page only reads its own entries.
+	ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	ListUserTokenRevocationsSince(ctx context.Context, tokensRevokedAt time.Time) ([]ListUserTokenRevocationsSinceRow, error)
+	// Lists transfers touching any account of the owner. Direction and counterparty are seen from the owner's side.
+	ListUserTransfers(ctx context.Context, arg ListUserTransfersParams) ([]ListUserTransfersRow, error)
+	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	RevokeUserTokens(ctx context.Context, username string) (User, error)
+	SetIdempotencyKeyResponse(ctx context.Context, arg SetIdempotencyKeyResponseParams) (IdempotencyKey, error)
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error)
+	UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error)
+	UpdateBeneficiary(ctx context.Context, arg UpdateBeneficiaryParams) (Beneficiary, error)
+	// Only active schedules can change. The executor checks end_at and max_runs again before each run.
+	UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error)
+	UpdateTransferStatus(ctx context.Context, arg UpdateTransferStatusParams) (Transfer, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	user := createRandomUser(t)
+	since := time.Now().Add(-time.Minute)
+
+	arg := RevokeTokenParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	err := testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	// Revoking the same token twice is a no-op.
+	err = testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	revokedTokens, err := testQueries.ListRevokedTokensSince(context.Background(), since)
+	require.NoError(t, err)
+
+	var found bool
+	for _, revokedToken := range revokedTokens {
+		if revokedToken.ID == arg.ID {
+			found = true
+			require.Equal(t, arg.Username, revokedToken.Username)
+			require.WithinDuration(t, arg.ExpiresAt, revokedToken.ExpiresAt, time.Second)
+			require.NotZero(t, revokedToken.RevokedAt)
+		}
+	}
+	require.True(t, found)
+}
+
+func TestListRevokedTokensSinceSkipsExpired(t *testing.T) {
+	user := createRandomUser(t)
+	since := time.Now().Add(-time.Minute)
+
+	arg := RevokeTokenParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(-time.Second),
+	}
+
+	err := testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	revokedTokens, err := testQueries.ListRevokedTokensSince(context.Background(), since)
+	require.NoError(t, err)
+
+	for _, revokedToken := range revokedTokens {
+		require.NotEqual(t, arg.ID, revokedToken.ID)
+	}
+}
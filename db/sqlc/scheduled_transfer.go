@@ -0,0 +1,83 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Frequencies stored in scheduled_transfers.frequency
+const (
+	ScheduleFrequencyOnce    = "once"    // ScheduleFrequencyOnce runs at start_at only
+	ScheduleFrequencyDaily   = "daily"   // ScheduleFrequencyDaily runs every day at the time of start_at
+	ScheduleFrequencyWeekly  = "weekly"  // ScheduleFrequencyWeekly runs every week on the weekday of start_at
+	ScheduleFrequencyMonthly = "monthly" // ScheduleFrequencyMonthly runs every month on the day of start_at, or the last day of shorter months
+)
+
+// Policies stored in scheduled_transfers.insufficient_funds_policy
+const (
+	InsufficientFundsPolicySkip  = "skip"  // InsufficientFundsPolicySkip gives up on a run the from account can't pay
+	InsufficientFundsPolicyRetry = "retry" // InsufficientFundsPolicyRetry tries the run again up to max_retries times
+)
+
+// Statuses stored in scheduled_transfers.status
+const (
+	ScheduledTransferStatusActive    = "active"    // ScheduledTransferStatusActive schedules have runs left
+	ScheduledTransferStatusCompleted = "completed" // ScheduledTransferStatusCompleted schedules are past their last run
+	ScheduledTransferStatusCancelled = "cancelled" // ScheduledTransferStatusCancelled schedules were stopped by their owner
+)
+
+// Statuses stored in scheduled_transfer_executions.status
+const (
+	ExecutionStatusSucceeded = "succeeded" // ExecutionStatusSucceeded attempts made the transfer
+	ExecutionStatusRetrying  = "retrying"  // ExecutionStatusRetrying attempts lacked funds and the run will be tried again
+	ExecutionStatusSkipped   = "skipped"   // ExecutionStatusSkipped attempts lacked funds and the run was given up
+	ExecutionStatusFailed    = "failed"    // ExecutionStatusFailed attempts could not move money for another reason, the run was given up
+)
+
+// ScheduledTransferKeyPrefix starts the idempotency keys runs are made under.
+// Runs share the owner's idempotency keys, so clients can't use keys with this prefix.
+const ScheduledTransferKeyPrefix = "scheduled_transfer/"
+
+// RunKey returns the idempotency key of the run of the schedule due at runAt.
+func (schedule ScheduledTransfer) RunKey(runAt time.Time) string {
+	return fmt.Sprintf("%s%d/%s", ScheduledTransferKeyPrefix, schedule.ID, runAt.Format(time.RFC3339))
+}
+
+// RunAt returns when run number n of the schedule is due, counting from 0.
+// Runs are counted from start_at in UTC rather than from the previous run, so a monthly
+// schedule started on the 31st comes back to the 31st after a short month.
+func (schedule ScheduledTransfer) RunAt(n int32) time.Time {
+	start := schedule.StartAt.UTC()
+
+	switch schedule.Frequency {
+	case ScheduleFrequencyDaily:
+		return start.AddDate(0, 0, int(n))
+	case ScheduleFrequencyWeekly:
+		return start.AddDate(0, 0, 7*int(n))
+	case ScheduleFrequencyMonthly:
+		// The first of the target month can't overflow into the next one, unlike AddDate from the 31st
+		month := time.Date(start.Year(), start.Month()+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+		day := min(start.Day(), daysIn(month))
+		return month.AddDate(0, 0, day-1)
+	}
+	return start
+}
+
+// HasRun reports whether run number n of the schedule is within its max_runs and end_at.
+func (schedule ScheduledTransfer) HasRun(n int32) bool {
+	if n < 0 || (schedule.Frequency == ScheduleFrequencyOnce && n > 0) {
+		return false
+	}
+	if schedule.MaxRuns.Valid && n >= schedule.MaxRuns.Int32 {
+		return false
+	}
+	if schedule.EndAt.Valid && schedule.RunAt(n).After(schedule.EndAt.Time) {
+		return false
+	}
+	return true
+}
+
+// daysIn returns the number of days in the month of t.
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,278 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomScheduledTransfer(t *testing.T, startAt time.Time) ScheduledTransfer {
+	account1, account2 := createRandomTransferAccounts(t)
+
+	arg := CreateScheduledTransferParams{
+		Owner:                   account1.Owner,
+		FromAccountID:           account1.ID,
+		ToAccountID:             account2.ID,
+		Amount:                  util.RandomMoney(),
+		Currency:                account1.Currency,
+		Description:             util.RandomString(20),
+		Frequency:               ScheduleFrequencyMonthly,
+		StartAt:                 startAt,
+		MaxRuns:                 sql.NullInt32{Int32: 12, Valid: true},
+		InsufficientFundsPolicy: InsufficientFundsPolicyRetry,
+		MaxRetries:              2,
+	}
+
+	schedule, err := testQueries.CreateScheduledTransfer(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, schedule.ID)
+
+	require.Equal(t, arg.Owner, schedule.Owner)
+	require.Equal(t, arg.FromAccountID, schedule.FromAccountID)
+	require.Equal(t, arg.ToAccountID, schedule.ToAccountID)
+	require.Equal(t, arg.Amount, schedule.Amount)
+	require.Equal(t, arg.Currency, schedule.Currency)
+	require.Equal(t, arg.Description, schedule.Description)
+	require.Equal(t, arg.Frequency, schedule.Frequency)
+	require.WithinDuration(t, arg.StartAt, schedule.StartAt, time.Second)
+	require.False(t, schedule.EndAt.Valid)
+	require.Equal(t, arg.MaxRuns, schedule.MaxRuns)
+	require.Equal(t, arg.InsufficientFundsPolicy, schedule.InsufficientFundsPolicy)
+	require.Equal(t, arg.MaxRetries, schedule.MaxRetries)
+	require.Equal(t, ScheduledTransferStatusActive, schedule.Status)
+	require.Zero(t, schedule.Runs)
+	require.Zero(t, schedule.Attempts)
+	require.True(t, schedule.NextRunAt.Valid)
+	require.WithinDuration(t, arg.StartAt, schedule.NextRunAt.Time, time.Second)
+
+	return schedule
+}
+
+func TestCreateScheduledTransfer(t *testing.T) {
+	createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+}
+
+func TestGetScheduledTransfer(t *testing.T) {
+	schedule1 := createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+
+	schedule2, err := testQueries.GetScheduledTransfer(context.Background(), schedule1.ID)
+	require.NoError(t, err)
+	require.Equal(t, schedule1.ID, schedule2.ID)
+	require.Equal(t, schedule1.Owner, schedule2.Owner)
+	require.WithinDuration(t, schedule1.CreatedAt, schedule2.CreatedAt, time.Second)
+}
+
+func TestListScheduledTransfers(t *testing.T) {
+	schedule := createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+
+	schedules, err := testQueries.ListScheduledTransfers(context.Background(), ListScheduledTransfersParams{
+		Owner:     schedule.Owner,
+		PageLimit: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	require.Equal(t, schedule.ID, schedules[0].ID)
+
+	schedules, err = testQueries.ListScheduledTransfers(context.Background(), ListScheduledTransfersParams{
+		Owner:     schedule.Owner,
+		AfterID:   schedule.ID,
+		PageLimit: 5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, schedules)
+}
+
+func TestUpdateScheduledTransfer(t *testing.T) {
+	schedule1 := createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+	endAt := time.Now().Add(24 * time.Hour)
+
+	schedule2, err := testQueries.UpdateScheduledTransfer(context.Background(), UpdateScheduledTransferParams{
+		Amount: sql.NullInt64{Int64: schedule1.Amount + 1, Valid: true},
+		EndAt:  sql.NullTime{Time: endAt, Valid: true},
+		ID:     schedule1.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, schedule1.Amount+1, schedule2.Amount)
+	require.WithinDuration(t, endAt, schedule2.EndAt.Time, time.Second)
+	require.Equal(t, schedule1.Description, schedule2.Description)
+	require.Equal(t, schedule1.MaxRuns, schedule2.MaxRuns)
+
+	cancelled, err := testQueries.CancelScheduledTransfer(context.Background(), schedule1.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusCancelled, cancelled.Status)
+	require.False(t, cancelled.NextRunAt.Valid)
+
+	// A cancelled schedule can neither change nor be cancelled again
+	_, err = testQueries.UpdateScheduledTransfer(context.Background(), UpdateScheduledTransferParams{
+		Description: sql.NullString{String: "too late", Valid: true},
+		ID:          schedule1.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQueries.CancelScheduledTransfer(context.Background(), schedule1.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListDueScheduledTransfers(t *testing.T) {
+	startAt := time.Now().Add(-time.Hour)
+	schedule := createRandomScheduledTransfer(t, startAt)
+
+	isListed := func(now time.Time) bool {
+		schedules, err := testQueries.ListDueScheduledTransfers(context.Background(), ListDueScheduledTransfersParams{
+			Now:       now,
+			PageLimit: 1000,
+		})
+		require.NoError(t, err)
+		for _, due := range schedules {
+			if due.ID == schedule.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	require.False(t, isListed(startAt.Add(-time.Minute)))
+	require.True(t, isListed(time.Now()))
+
+	_, err := testQueries.CancelScheduledTransfer(context.Background(), schedule.ID)
+	require.NoError(t, err)
+	require.False(t, isListed(time.Now()))
+}
+
+func TestRecordScheduledTransferRunTx(t *testing.T) {
+	schedule := createRandomScheduledTransfer(t, time.Now().Add(-time.Hour))
+
+	arg := RecordScheduledTransferRunTxParams{
+		Execution: CreateScheduledTransferExecutionParams{
+			ScheduledTransferID: schedule.ID,
+			ScheduledFor:        schedule.NextRunAt.Time,
+			Attempt:             1,
+			Status:              ExecutionStatusRetrying,
+			Error:               ErrInsufficientFunds.Error(),
+		},
+		Advance: AdvanceScheduledTransferParams{
+			Attempts:      1,
+			NextRunAt:     sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+			Status:        ScheduledTransferStatusActive,
+			ID:            schedule.ID,
+			FromNextRunAt: schedule.NextRunAt.Time,
+		},
+	}
+
+	result, err := testStore.RecordScheduledTransferRunTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result.ScheduledTransfer.Attempts)
+	require.WithinDuration(t, arg.Advance.NextRunAt.Time, result.ScheduledTransfer.NextRunAt.Time, time.Second)
+	require.NotZero(t, result.Execution.ID)
+	require.Equal(t, ExecutionStatusRetrying, result.Execution.Status)
+	require.Nil(t, result.Execution.TransferID)
+
+	// The run was already moved on, so the same attempt is not recorded twice
+	_, err = testStore.RecordScheduledTransferRunTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	executions, err := testQueries.ListScheduledTransferExecutions(context.Background(), ListScheduledTransferExecutionsParams{
+		ScheduledTransferID: schedule.ID,
+		PageLimit:           5,
+	})
+	require.NoError(t, err)
+	require.Len(t, executions, 1)
+	require.Equal(t, result.Execution.ID, executions[0].ID)
+
+	_, err = testQueries.CancelScheduledTransfer(context.Background(), schedule.ID)
+	require.NoError(t, err)
+}
+
+func TestRecordScheduledTransferRunTxCancelled(t *testing.T) {
+	schedule := createRandomScheduledTransfer(t, time.Now().Add(-time.Hour))
+
+	transfer := createRandomTransfer(t) // the transfer the run made
+
+	// The owner cancels the schedule between the transfer and its record
+	_, err := testQueries.CancelScheduledTransfer(context.Background(), schedule.ID)
+	require.NoError(t, err)
+
+	arg := RecordScheduledTransferRunTxParams{
+		Execution: CreateScheduledTransferExecutionParams{
+			ScheduledTransferID: schedule.ID,
+			ScheduledFor:        schedule.NextRunAt.Time,
+			Attempt:             1,
+			Status:              ExecutionStatusSucceeded,
+			TransferID:          &transfer.ID,
+		},
+		Advance: AdvanceScheduledTransferParams{
+			Runs:          1,
+			NextRunAt:     sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+			Status:        ScheduledTransferStatusActive,
+			ID:            schedule.ID,
+			FromNextRunAt: schedule.NextRunAt.Time,
+		},
+	}
+
+	result, err := testStore.RecordScheduledTransferRunTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferStatusCancelled, result.ScheduledTransfer.Status)
+	require.Zero(t, result.ScheduledTransfer.Runs)
+	require.Equal(t, transfer.ID, *result.Execution.TransferID)
+
+	// The transfer is only recorded once
+	_, err = testStore.RecordScheduledTransferRunTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// and an attempt that moved nothing isn't recorded for a cancelled schedule
+	arg.Execution.Status = ExecutionStatusFailed
+	arg.Execution.TransferID = nil
+	_, err = testStore.RecordScheduledTransferRunTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestScheduledTransferRunAt(t *testing.T) {
+	startAt := time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		frequency string
+		n         int32
+		want      time.Time
+	}{
+		{ScheduleFrequencyOnce, 0, startAt},
+		{ScheduleFrequencyDaily, 1, time.Date(2024, time.February, 1, 9, 30, 0, 0, time.UTC)},
+		{ScheduleFrequencyWeekly, 2, time.Date(2024, time.February, 14, 9, 30, 0, 0, time.UTC)},
+		{ScheduleFrequencyMonthly, 1, time.Date(2024, time.February, 29, 9, 30, 0, 0, time.UTC)},
+		{ScheduleFrequencyMonthly, 2, time.Date(2024, time.March, 31, 9, 30, 0, 0, time.UTC)},
+		{ScheduleFrequencyMonthly, 3, time.Date(2024, time.April, 30, 9, 30, 0, 0, time.UTC)},
+		{ScheduleFrequencyMonthly, 13, time.Date(2025, time.February, 28, 9, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		schedule := ScheduledTransfer{Frequency: tc.frequency, StartAt: startAt}
+		require.Equal(t, tc.want, schedule.RunAt(tc.n), "%s run %d", tc.frequency, tc.n)
+	}
+}
+
+func TestScheduledTransferHasRun(t *testing.T) {
+	startAt := time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC)
+
+	once := ScheduledTransfer{Frequency: ScheduleFrequencyOnce, StartAt: startAt}
+	require.True(t, once.HasRun(0))
+	require.False(t, once.HasRun(1))
+
+	limited := ScheduledTransfer{Frequency: ScheduleFrequencyDaily, StartAt: startAt, MaxRuns: sql.NullInt32{Int32: 3, Valid: true}}
+	require.True(t, limited.HasRun(2))
+	require.False(t, limited.HasRun(3))
+
+	ending := ScheduledTransfer{
+		Frequency: ScheduleFrequencyMonthly,
+		StartAt:   startAt,
+		EndAt:     sql.NullTime{Time: time.Date(2024, time.March, 31, 9, 30, 0, 0, time.UTC), Valid: true},
+	}
+	require.True(t, ending.HasRun(2))
+	require.False(t, ending.HasRun(3))
+
+	unlimited := ScheduledTransfer{Frequency: ScheduleFrequencyWeekly, StartAt: startAt}
+	require.True(t, unlimited.HasRun(1000))
+	require.False(t, unlimited.HasRun(-1))
+}
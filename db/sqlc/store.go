@@ -3,12 +3,24 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+
+	"github.com/badermezzi/KubeGoBank/util"
 )
 
 type Store interface {
 	Querier // Embed Querier interface
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	RevokeUserTokensTx(ctx context.Context, username string) (User, error)
+	AdjustBalanceTx(ctx context.Context, arg AdjustBalanceTxParams) (AdjustBalanceTxResult, error)
+	SetOverdraftLimitTx(ctx context.Context, arg SetOverdraftLimitTxParams) (SetOverdraftLimitTxResult, error)
+	CloseAccountTx(ctx context.Context, arg CloseAccountTxParams) (CloseAccountTxResult, error)
+	RecordScheduledTransferRunTx(ctx context.Context, arg RecordScheduledTransferRunTxParams) (RecordScheduledTransferRunTxResult, error)
+	ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error)
+	CompleteTransferTx(ctx context.Context, transferID int64) (TransferTxResult, error)
+	CancelTransferTx(ctx context.Context, transferID int64) (ReleaseTransferTxResult, error)
+	FailTransferTx(ctx context.Context, transferID int64) (ReleaseTransferTxResult, error)
 }
 
 // SQLStore provides all functions to execute SQL queries and transactions
@@ -44,11 +56,26 @@ func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) erro
 	return tx.Commit() // Commit transaction if function execution is successful
 }
 
+// emptyMetadata is stored on transfers made without metadata.
+var emptyMetadata = json.RawMessage(`{}`)
+
 // TransferTxParams contains the parameters for the transfer transaction
 type TransferTxParams struct {
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
-	Amount        int64 `json:"amount"`
+	FromAccountID int64      `json:"from_account_id"`
+	ToAccountID   int64      `json:"to_account_id"`
+	Amount        util.Money `json:"amount"` // Amount must be positive and in the from account's currency.
+	// ExchangeRate converts Amount from the from account's currency to the to account's currency.
+	// It is required when the currencies differ and ignored otherwise.
+	ExchangeRate string `json:"exchange_rate,omitempty"`
+	// Description is copied onto both entries. Reference and Metadata are kept on the transfer for the client.
+	Description string          `json:"description,omitempty"`
+	Reference   string          `json:"reference,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"` // Metadata must be a JSON object, empty means {}
+	// IdempotencyKey, if set, makes the transfer happen once per key: retries get the first result back.
+	IdempotencyKey *CreateIdempotencyKeyParams `json:"idempotency_key,omitempty"`
+	// Pending holds the amount on the from account instead of moving it. The transfer then has no entries
+	// until CompleteTransferTx, and CancelTransferTx or FailTransferTx release the hold.
+	Pending bool `json:"pending,omitempty"`
 }
 
 // TransferTxResult is the result of the transfer transaction
@@ -56,57 +83,352 @@ type TransferTxResult struct {
 	Transfer    Transfer `json:"transfer"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
-	FromAccount Account  `json:"from_account"` // Add FromAccount to result
-	ToAccount   Account  `json:"to_account"`   // Add ToAccount to result
+	FromAccount Account  `json:"from_account"`         // Add FromAccount to result
+	ToAccount   Account  `json:"to_account"`           // Add ToAccount to result
+	FXEntries   []Entry  `json:"fx_entries,omitempty"` // FXEntries book a cross-currency transfer against the FX position accounts
+	Replayed    bool     `json:"-"`                    // Replayed is set when the result was stored by an earlier request with the same idempotency key
 }
 
-// TransferTx performs a money transfer from one account to another.
+// TransferTx performs a money transfer from one account to another, or places it on hold if it is pending.
+// It returns ErrAccountFrozen or ErrAccountClosed if either account cannot move money,
+// and ErrInsufficientFunds if the transfer would take the from account below its overdraft limit.
+// Between currencies, the amount is converted at ExchangeRate. ErrCurrencyMismatch is returned if no rate is given
+// or the amount is not in the from account's currency.
+// With an idempotency key, a retry returns the stored result of the first transfer instead of moving money again,
+// and ErrIdempotencyKeyReused if the key was first used for a different request. Failed transfers don't keep their key.
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult
 
 	err := store.execTx(ctx, func(q *Queries) error {
-		var err error
+		if arg.IdempotencyKey != nil {
+			key, err := claimIdempotencyKey(ctx, q, *arg.IdempotencyKey)
+			if err != nil {
+				return err
+			}
+			if key != nil {
+				stored, err := StoredTransferTxResult(*key, arg.IdempotencyKey.RequestHash)
+				if err != nil {
+					return err
+				}
+				if stored != nil {
+					result = *stored
+					return nil
+				}
+			}
+		}
 
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams(arg))
+		fromAccount, toAccount, err := store.lockAccounts(ctx, q, arg.FromAccountID, arg.ToAccountID)
 		if err != nil {
 			return err
 		}
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount, // Debit from account
-		})
+		// Both sides are checked under the row lock, so a concurrent freeze or close can't slip in.
+		err = checkAccountActive(fromAccount)
 		if err != nil {
 			return err
 		}
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount, // Credit to account
-		})
+		err = checkAccountActive(toAccount)
 		if err != nil {
 			return err
 		}
 
-		// Update account balances using addMoney for consistent locking.
-		result.FromAccount, result.ToAccount, err = store.addMoney(
-			ctx,
-			q,
-			arg.FromAccountID,
-			-arg.Amount,
-			arg.ToAccountID,
-			arg.Amount,
-		)
+		result, err = store.transfer(ctx, q, arg, fromAccount, toAccount)
+		if err != nil || arg.IdempotencyKey == nil {
+			return err
+		}
 
-		return err // Return any error from addMoney or Create operations
+		return saveIdempotencyKeyResponse(ctx, q, *arg.IdempotencyKey, result.Transfer.ID, result)
 	})
 
 	return result, err
 }
 
+// claimIdempotencyKey takes the key for the current transaction. If another request already holds it, the insert
+// waits for that transaction to end, and the key is returned with the response stored by that request.
+// It returns nil if the key was free.
+func claimIdempotencyKey(ctx context.Context, q *Queries, arg CreateIdempotencyKeyParams) (*IdempotencyKey, error) {
+	_, err := q.CreateIdempotencyKey(ctx, arg)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	key, err := q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{
+		Username: arg.Username,
+		Key:      arg.Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// readIdempotencyKeyResponse decodes the response a request with the given fingerprint stored under the key
+// into result. It returns ErrIdempotencyKeyReused if the key was used with another request,
+// and false if it has no response.
+func readIdempotencyKeyResponse(key IdempotencyKey, requestHash string, result any) (bool, error) {
+	if key.RequestHash != requestHash {
+		return false, ErrIdempotencyKeyReused
+	}
+
+	if len(key.Response) == 0 {
+		return false, nil
+	}
+
+	err := json.Unmarshal(key.Response, result)
+	if err != nil {
+		return false, fmt.Errorf("cannot read stored response of idempotency key: %w", err)
+	}
+	return true, nil
+}
+
+// StoredTransferTxResult reads the result a request with the given fingerprint completed under the key, marked
+// as replayed. It returns ErrIdempotencyKeyReused if the key was used with another request, and nil if it has no result.
+func StoredTransferTxResult(key IdempotencyKey, requestHash string) (*TransferTxResult, error) {
+	var result TransferTxResult
+	ok, err := readIdempotencyKeyResponse(key, requestHash, &result)
+	if !ok {
+		return nil, err
+	}
+	result.Replayed = true
+
+	return &result, nil
+}
+
+// saveIdempotencyKeyResponse stores the result of the transfer made under the key, for retries to replay.
+func saveIdempotencyKeyResponse(ctx context.Context, q *Queries, key CreateIdempotencyKeyParams, transferID int64, result any) error {
+	response, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.SetIdempotencyKeyResponse(ctx, SetIdempotencyKeyResponseParams{
+		TransferID: &transferID,
+		Response:   response,
+		Username:   key.Username,
+		Key:        key.Key,
+	})
+	return err
+}
+
+// transfer converts the amount of a transfer into the to account's currency and books it with bookTransfer.
+// The caller locks and checks the accounts.
+func (store *SQLStore) transfer(ctx context.Context, q *Queries, arg TransferTxParams, fromAccount Account, toAccount Account) (TransferTxResult, error) {
+	var result TransferTxResult
+
+	if arg.Amount.Currency != fromAccount.Currency {
+		return result, ErrCurrencyMismatch
+	}
+	if !arg.Amount.IsPositive() {
+		return result, ErrNonPositiveAmount
+	}
+
+	toAmount := arg.Amount.Amount
+	exchangeRate := exchangeRateOne
+	if fromAccount.Currency != toAccount.Currency {
+		if arg.ExchangeRate == "" {
+			return result, ErrCurrencyMismatch
+		}
+
+		fromCurrency, err := q.GetCurrency(ctx, fromAccount.Currency)
+		if err != nil {
+			return result, err
+		}
+
+		toCurrency, err := q.GetCurrency(ctx, toAccount.Currency)
+		if err != nil {
+			return result, err
+		}
+
+		toAmount, err = convertAmount(arg.Amount.Amount, arg.ExchangeRate, fromCurrency.MinorUnit, toCurrency.MinorUnit)
+		if err != nil {
+			return result, err
+		}
+		exchangeRate = arg.ExchangeRate
+	}
+
+	metadata := arg.Metadata
+	if len(metadata) == 0 {
+		metadata = emptyMetadata
+	}
+
+	transfer := CreateTransferParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount.Amount,
+		ToAmount:      toAmount,
+		ExchangeRate:  exchangeRate,
+		Description:   arg.Description,
+		Reference:     arg.Reference,
+		Metadata:      metadata,
+	}
+
+	if arg.Pending {
+		return store.holdTransfer(ctx, q, transfer, toAccount)
+	}
+	return store.bookTransfer(ctx, q, transfer, fromAccount, toAccount)
+}
+
+// holdTransfer records a pending transfer and holds its amount on the from account, so it can't be spent
+// before the transfer completes. It returns ErrInsufficientFunds if the balance left over can't cover it.
+func (store *SQLStore) holdTransfer(ctx context.Context, q *Queries, arg CreateTransferParams, toAccount Account) (TransferTxResult, error) {
+	var result TransferTxResult
+	var err error
+
+	arg.Status = TransferStatusPending
+	result.Transfer, err = q.CreateTransfer(ctx, arg)
+	if err != nil {
+		return result, err
+	}
+
+	result.FromAccount, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+		Amount: arg.Amount,
+		ID:     arg.FromAccountID,
+	})
+	if err != nil {
+		return result, balanceError(err)
+	}
+
+	result.ToAccount = toAccount
+	return result, nil
+}
+
+// bookTransfer records a completed transfer whose amounts are already worked out and posts it.
+func (store *SQLStore) bookTransfer(ctx context.Context, q *Queries, arg CreateTransferParams, fromAccount Account, toAccount Account) (TransferTxResult, error) {
+	arg.Status = TransferStatusCompleted
+	transfer, err := q.CreateTransfer(ctx, arg)
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+
+	return store.postTransfer(ctx, q, transfer, fromAccount, toAccount)
+}
+
+// postTransfer writes the entries of a transfer and moves the money.
+// Between currencies, each side is balanced against the FX position account of its currency,
+// which are locked after the customer accounts, in ID order.
+func (store *SQLStore) postTransfer(ctx context.Context, q *Queries, transfer Transfer, fromAccount Account, toAccount Account) (TransferTxResult, error) {
+	result := TransferTxResult{Transfer: transfer}
+
+	debit, err := util.NegateAmount(transfer.Amount)
+	if err != nil {
+		return result, err
+	}
+
+	// Update account balances using addMoney for consistent locking.
+	// The entries come after, so they can record the balances they leave behind.
+	result.FromAccount, result.ToAccount, err = store.addMoney(
+		ctx,
+		q,
+		transfer.FromAccountID,
+		debit,
+		transfer.ToAccountID,
+		transfer.ToAmount,
+	)
+	if err != nil {
+		return result, err
+	}
+
+	result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    transfer.FromAccountID,
+		Amount:       debit, // Debit from account
+		TransferID:   &transfer.ID,
+		Description:  transfer.Description,
+		BalanceAfter: result.FromAccount.Balance,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    transfer.ToAccountID,
+		Amount:       transfer.ToAmount, // Credit to account
+		TransferID:   &transfer.ID,
+		Description:  transfer.Description,
+		BalanceAfter: result.ToAccount.Balance,
+	})
+	if err != nil || fromAccount.Currency == toAccount.Currency {
+		return result, err // Transfers within one currency have no FX positions to book
+	}
+
+	result.FXEntries, err = store.bookFXPositions(ctx, q, transfer, fromAccount.Currency, toAccount.Currency)
+	return result, err
+}
+
+// bookFXPositions credits the FX position in the from currency with what the customer paid
+// and debits the FX position in the to currency with what the customer received.
+func (store *SQLStore) bookFXPositions(ctx context.Context, q *Queries, transfer Transfer, fromCurrency string, toCurrency string) ([]Entry, error) {
+	fromPosition, err := q.GetFXPositionAccount(ctx, fromCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find FX position account for %s: %w", fromCurrency, err)
+	}
+
+	toPosition, err := q.GetFXPositionAccount(ctx, toCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find FX position account for %s: %w", toCurrency, err)
+	}
+
+	fromPosition, toPosition, err = store.addMoney(ctx, q, fromPosition.ID, transfer.Amount, toPosition.ID, -transfer.ToAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	fromEntry, err := q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    fromPosition.ID,
+		Amount:       transfer.Amount,
+		TransferID:   &transfer.ID,
+		BalanceAfter: fromPosition.Balance,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	toEntry, err := q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    toPosition.ID,
+		Amount:       -transfer.ToAmount,
+		TransferID:   &transfer.ID,
+		BalanceAfter: toPosition.Balance,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []Entry{fromEntry, toEntry}, nil
+}
+
+// lockAccounts locks two accounts for update in the same lower-ID-first order addMoney uses,
+// and returns them in argument order.
+func (store *SQLStore) lockAccounts(ctx context.Context, q *Queries, accountID1 int64, accountID2 int64) (account1 Account, account2 Account, err error) {
+	if accountID1 < accountID2 {
+		account1, err = q.GetAccountForUpdate(ctx, accountID1)
+		if err != nil {
+			return
+		}
+		account2, err = q.GetAccountForUpdate(ctx, accountID2)
+		return
+	}
+
+	account2, err = q.GetAccountForUpdate(ctx, accountID2)
+	if err != nil {
+		return
+	}
+	account1, err = q.GetAccountForUpdate(ctx, accountID1)
+	return
+}
+
 // addMoney adds or subtracts money from an account.  It ensures consistent locking
 // by always updating the account with the lower ID first.
+// Postgres checks the new balances against accounts_balance_check while it holds the row locks,
+// and a violation is returned as ErrInsufficientFunds. A balance that would overflow is util.ErrAmountOverflow.
 func (store *SQLStore) addMoney(ctx context.Context, q *Queries, accountID1 int64, amount1 int64, accountID2 int64, amount2 int64) (account1 Account, account2 Account, err error) {
+	defer func() {
+		err = balanceError(err)
+	}()
+
 	if accountID1 < accountID2 {
 		account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
 			ID:     accountID1,
@@ -141,3 +463,551 @@ func (store *SQLStore) addMoney(ctx context.Context, q *Queries, accountID1 int6
 	}
 	return // Both accounts, and possibly an error, are returned.
 }
+
+// RevokeUserTokensTx logs a user out everywhere.
+// It moves the user's token cutoff to now and blocks every session in one transaction.
+func (store *SQLStore) RevokeUserTokensTx(ctx context.Context, username string) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		user, err = q.RevokeUserTokens(ctx, username) // Reject every token issued before now
+		if err != nil {
+			return err
+		}
+
+		return q.BlockUserSessions(ctx, username) // Stop refresh tokens from minting new access tokens
+	})
+
+	return user, err
+}
+
+// AdjustBalanceTxParams contains the parameters for the balance adjustment transaction
+type AdjustBalanceTxParams struct {
+	AccountID  int64  `json:"account_id"`
+	Amount     int64  `json:"amount"` // Amount is credited to the account when positive and debited when negative.
+	ReasonCode string `json:"reason_code"`
+	Note       string `json:"note"`
+	CreatedBy  string `json:"created_by"`
+}
+
+// AdjustBalanceTxResult is the result of the balance adjustment transaction
+type AdjustBalanceTxResult struct {
+	Adjustment      BalanceAdjustment `json:"adjustment"`
+	Entry           Entry             `json:"entry"`
+	SuspenseEntry   Entry             `json:"suspense_entry"`
+	Account         Account           `json:"account"`
+	SuspenseAccount Account           `json:"suspense_account"`
+}
+
+// AdjustBalanceTx corrects an account balance on behalf of a banker.
+// The amount is booked against the suspense account of the same currency, so entries still sum to the balances,
+// and the adjustment is recorded with its reason code.
+// It returns ErrInsufficientFunds if a debit would overdraw a customer account and ErrAccountClosed for closed accounts.
+func (store *SQLStore) AdjustBalanceTx(ctx context.Context, arg AdjustBalanceTxParams) (AdjustBalanceTxResult, error) {
+	var result AdjustBalanceTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		account, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		// Frozen accounts can still be corrected by a banker, closed ones are final.
+		if account.Status == AccountStatusClosed {
+			return checkAccountActive(account)
+		}
+
+		suspenseAccount, err := q.GetSuspenseAccount(ctx, account.Currency)
+		if err != nil {
+			return fmt.Errorf("cannot find suspense account for %s: %w", account.Currency, err)
+		}
+
+		suspenseAmount, err := util.NegateAmount(arg.Amount)
+		if err != nil {
+			return err
+		}
+
+		result.Account, result.SuspenseAccount, err = store.addMoney(
+			ctx,
+			q,
+			account.ID,
+			arg.Amount,
+			suspenseAccount.ID,
+			suspenseAmount,
+		)
+		if err != nil {
+			return err
+		}
+
+		result.Entry, err = q.CreateEntry(ctx, CreateEntryParams{
+			AccountID:    account.ID,
+			Amount:       arg.Amount,
+			BalanceAfter: result.Account.Balance,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.SuspenseEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+			AccountID:    suspenseAccount.ID,
+			Amount:       suspenseAmount, // The suspense account carries the other side
+			BalanceAfter: result.SuspenseAccount.Balance,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Adjustment, err = q.CreateBalanceAdjustment(ctx, CreateBalanceAdjustmentParams{
+			AccountID:         account.ID,
+			SuspenseAccountID: suspenseAccount.ID,
+			EntryID:           result.Entry.ID,
+			SuspenseEntryID:   result.SuspenseEntry.ID,
+			Amount:            arg.Amount,
+			ReasonCode:        arg.ReasonCode,
+			Note:              arg.Note,
+			CreatedBy:         arg.CreatedBy,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// SetOverdraftLimitTxParams contains the parameters for the overdraft limit transaction
+type SetOverdraftLimitTxParams struct {
+	AccountID      int64  `json:"account_id"`
+	OverdraftLimit int64  `json:"overdraft_limit"`
+	ChangedBy      string `json:"changed_by"`
+}
+
+// SetOverdraftLimitTxResult is the result of the overdraft limit transaction
+type SetOverdraftLimitTxResult struct {
+	Account Account              `json:"account"`
+	Change  OverdraftLimitChange `json:"change"`
+}
+
+// SetOverdraftLimitTx changes how far below zero an account may go and records who changed it.
+// It returns ErrBalanceBelowOverdraftLimit if the account already owes more than the new limit.
+func (store *SQLStore) SetOverdraftLimitTx(ctx context.Context, arg SetOverdraftLimitTxParams) (SetOverdraftLimitTxResult, error) {
+	var result SetOverdraftLimitTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		account, err := q.GetAccountForUpdate(ctx, arg.AccountID) // Keep transfers out until the new limit is set
+		if err != nil {
+			return err
+		}
+
+		if account.Balance-account.HeldAmount < -arg.OverdraftLimit {
+			return ErrBalanceBelowOverdraftLimit
+		}
+
+		result.Account, err = q.UpdateAccountOverdraftLimit(ctx, UpdateAccountOverdraftLimitParams{
+			ID:             account.ID,
+			OverdraftLimit: arg.OverdraftLimit,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Change, err = q.CreateOverdraftLimitChange(ctx, CreateOverdraftLimitChangeParams{
+			AccountID: account.ID,
+			OldLimit:  account.OverdraftLimit,
+			NewLimit:  arg.OverdraftLimit,
+			ChangedBy: arg.ChangedBy,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// CloseAccountTxParams contains the parameters for the close account transaction
+type CloseAccountTxParams struct {
+	AccountID        int64 `json:"account_id"`
+	SweepToAccountID int64 `json:"sweep_to_account_id"` // SweepToAccountID receives the remaining balance, 0 means the balance must already be zero.
+}
+
+// CloseAccountTxResult is the result of the close account transaction
+type CloseAccountTxResult struct {
+	Account Account           `json:"account"`
+	Sweep   *TransferTxResult `json:"sweep,omitempty"` // Sweep is the transfer of the remaining balance, if there was one.
+}
+
+// CloseAccountTx closes an active account for good.
+// The account must have a zero balance, unless a sweep account is given, then a positive balance is moved there first.
+// It returns ErrFundsOnHold while transfers out of it are pending, ErrNonZeroBalance if money is left behind,
+// and ErrAccountFrozen, ErrAccountClosed or ErrCurrencyMismatch if either account cannot take part.
+func (store *SQLStore) CloseAccountTx(ctx context.Context, arg CloseAccountTxParams) (CloseAccountTxResult, error) {
+	var result CloseAccountTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var account, sweepAccount Account
+		var err error
+
+		if arg.SweepToAccountID != 0 {
+			account, sweepAccount, err = store.lockAccounts(ctx, q, arg.AccountID, arg.SweepToAccountID)
+		} else {
+			account, err = q.GetAccountForUpdate(ctx, arg.AccountID)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = checkAccountActive(account)
+		if err != nil {
+			return err
+		}
+
+		// Pending transfers must settle first, their held money is neither swept nor left behind.
+		if account.HeldAmount != 0 {
+			return ErrFundsOnHold
+		}
+
+		if arg.SweepToAccountID != 0 && account.Balance > 0 {
+			err = checkAccountActive(sweepAccount)
+			if err != nil {
+				return err
+			}
+
+			if sweepAccount.Currency != account.Currency {
+				return ErrCurrencyMismatch
+			}
+
+			sweep, err := store.transfer(ctx, q, TransferTxParams{
+				FromAccountID: account.ID,
+				ToAccountID:   sweepAccount.ID,
+				Amount:        util.NewMoney(account.Balance, account.Currency),
+			}, account, sweepAccount)
+			if err != nil {
+				return err
+			}
+
+			result.Sweep = &sweep
+			account = sweep.FromAccount
+		}
+
+		if account.Balance != 0 {
+			return ErrNonZeroBalance
+		}
+
+		result.Account, err = q.UpdateAccountStatus(ctx, UpdateAccountStatusParams{
+			ID:         account.ID,
+			Status:     AccountStatusClosed,
+			FromStatus: AccountStatusActive,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// RecordScheduledTransferRunTxParams contains the parameters for the scheduled transfer run transaction
+type RecordScheduledTransferRunTxParams struct {
+	Execution CreateScheduledTransferExecutionParams `json:"execution"`
+	Advance   AdvanceScheduledTransferParams         `json:"advance"`
+}
+
+// RecordScheduledTransferRunTxResult is the result of the scheduled transfer run transaction
+type RecordScheduledTransferRunTxResult struct {
+	ScheduledTransfer ScheduledTransfer          `json:"scheduled_transfer"`
+	Execution         ScheduledTransferExecution `json:"execution"`
+}
+
+// RecordScheduledTransferRunTx moves a schedule on after an attempt and records the attempt's outcome.
+// It returns sql.ErrNoRows, and records nothing, if the schedule was already moved on by another executor
+// or is no longer active. An attempt that made a transfer is still recorded if the schedule was cancelled
+// after the money moved, unless another executor recorded that transfer first.
+func (store *SQLStore) RecordScheduledTransferRunTx(ctx context.Context, arg RecordScheduledTransferRunTxParams) (RecordScheduledTransferRunTxResult, error) {
+	var result RecordScheduledTransferRunTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.ScheduledTransfer, err = q.AdvanceScheduledTransfer(ctx, arg.Advance)
+		if err == sql.ErrNoRows && arg.Execution.TransferID != nil {
+			// The schedule is left as it is, the money it moved is not.
+			result.ScheduledTransfer, err = q.GetScheduledTransfer(ctx, arg.Advance.ID)
+		}
+		if err != nil {
+			return err
+		}
+
+		result.Execution, err = q.CreateScheduledTransferExecution(ctx, arg.Execution)
+		return err
+	})
+
+	return result, err
+}
+
+// ReverseTransferTxParams contains the parameters for the reverse transfer transaction
+type ReverseTransferTxParams struct {
+	TransferID int64 `json:"transfer_id"`
+	// Amount is paid back from the to account of the transfer, in its currency, up to what is left of the transfer.
+	// Zero pays back all that is left.
+	Amount      int64  `json:"amount"`
+	Description string `json:"description,omitempty"` // Description defaults to naming the reversed transfer.
+	// Forced lets a banker reverse a transfer into or out of a frozen account. Closed accounts are final either way.
+	Forced bool `json:"forced"`
+	// IdempotencyKey, if set, makes the reversal happen once per key: retries get the first result back.
+	IdempotencyKey *CreateIdempotencyKeyParams `json:"idempotency_key,omitempty"`
+}
+
+// ReverseTransferTxResult is the result of the reverse transfer transaction
+type ReverseTransferTxResult struct {
+	Transfer Transfer         `json:"transfer"` // Transfer is the reversed transfer with what it has been paid back so far.
+	Reversal TransferTxResult `json:"reversal"` // Reversal moves the money from the to account of the transfer back to its from account.
+	Replayed bool             `json:"-"`        // Replayed is set when the result was stored by an earlier request with the same idempotency key
+}
+
+// ReverseTransferTx pays a transfer back, in full or in part, with a compensating transfer the other way
+// that points at it through reversed_transfer_id. The transfer row is locked first, so concurrent reversals
+// of one transfer take turns and together never pay back more than it moved.
+// A cross-currency transfer is paid back in proportion to what it moved, not at today's rate.
+// Once nothing is left, the transfer is reversed and ErrTransferAlreadyReversed is returned.
+// With an idempotency key, a retry gets the first result back before anything is checked again.
+// It returns ErrReversalExceedsTransfer if the amount is more than what is left, ErrTransferIsReversal for a reversal,
+// a TransferStatusError if the transfer isn't completed, and the errors of TransferTx if the money can't move.
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error) {
+	var result ReverseTransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		if arg.IdempotencyKey != nil {
+			key, err := claimIdempotencyKey(ctx, q, *arg.IdempotencyKey)
+			if err != nil {
+				return err
+			}
+			if key != nil {
+				replayed, err := readIdempotencyKeyResponse(*key, arg.IdempotencyKey.RequestHash, &result)
+				if err != nil || replayed {
+					result.Replayed = replayed
+					return err
+				}
+			}
+		}
+
+		transfer, err := q.GetTransferForUpdate(ctx, arg.TransferID)
+		if err != nil {
+			return err
+		}
+
+		if transfer.ReversedTransferID != nil {
+			return ErrTransferIsReversal
+		}
+
+		left := transfer.ToAmount - transfer.ReversedAmount
+		if left == 0 || transfer.Status == TransferStatusReversed {
+			return ErrTransferAlreadyReversed
+		}
+
+		// Only money that moved can be paid back, a pending transfer is cancelled instead.
+		err = checkTransferTransition(transfer, TransferStatusReversed)
+		if err != nil {
+			return err
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = left
+		}
+		if amount < 0 {
+			return ErrNonPositiveAmount
+		}
+		if amount > left {
+			return ErrReversalExceedsTransfer
+		}
+
+		// The money goes back the other way, from the account that received it.
+		fromAccount, toAccount, err := store.lockAccounts(ctx, q, transfer.ToAccountID, transfer.FromAccountID)
+		if err != nil {
+			return err
+		}
+
+		err = checkReversalAccount(fromAccount, arg.Forced)
+		if err != nil {
+			return err
+		}
+
+		err = checkReversalAccount(toAccount, arg.Forced)
+		if err != nil {
+			return err
+		}
+
+		toAmount := amount
+		exchangeRate := exchangeRateOne
+		if fromAccount.Currency != toAccount.Currency {
+			toAmount, err = reversalToAmount(transfer, amount)
+			if err != nil {
+				return err
+			}
+
+			exchangeRate, err = invertExchangeRate(transfer.ExchangeRate)
+			if err != nil {
+				return err
+			}
+		}
+
+		result.Transfer, err = q.AddTransferReversedAmount(ctx, AddTransferReversedAmountParams{
+			Amount: amount,
+			ID:     transfer.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if result.Transfer.ReversedAmount == result.Transfer.ToAmount {
+			result.Transfer, err = q.UpdateTransferStatus(ctx, UpdateTransferStatusParams{
+				Status:     TransferStatusReversed,
+				ID:         transfer.ID,
+				FromStatus: TransferStatusCompleted,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		description := arg.Description
+		if description == "" {
+			description = fmt.Sprintf("reversal of transfer %d", transfer.ID)
+		}
+
+		result.Reversal, err = store.bookTransfer(ctx, q, CreateTransferParams{
+			FromAccountID:      fromAccount.ID,
+			ToAccountID:        toAccount.ID,
+			Amount:             amount,
+			ToAmount:           toAmount,
+			ExchangeRate:       exchangeRate,
+			Description:        description,
+			Reference:          transfer.Reference, // The client matches the reversal with the same record as the transfer
+			Metadata:           emptyMetadata,
+			ReversedTransferID: &transfer.ID,
+		}, fromAccount, toAccount)
+		if err != nil || arg.IdempotencyKey == nil {
+			return err
+		}
+
+		return saveIdempotencyKeyResponse(ctx, q, *arg.IdempotencyKey, result.Reversal.Transfer.ID, result)
+	})
+
+	return result, err
+}
+
+// checkReversalAccount checks that an account can take part in a reversal.
+// A forced reversal may move money in or out of a frozen account, like a balance adjustment.
+func checkReversalAccount(account Account, forced bool) error {
+	if forced && account.Status == AccountStatusFrozen {
+		return nil
+	}
+	return checkAccountActive(account)
+}
+
+// CompleteTransferTx settles a pending transfer: the hold on the from account is released and the transfer is posted
+// at the amounts it was created with. It returns a TransferStatusError if the transfer isn't pending,
+// and ErrAccountFrozen or ErrAccountClosed, leaving the transfer pending, if either account can't move money.
+func (store *SQLStore) CompleteTransferTx(ctx context.Context, transferID int64) (TransferTxResult, error) {
+	var result TransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		transfer, err := q.GetTransferForUpdate(ctx, transferID)
+		if err != nil {
+			return err
+		}
+
+		err = checkTransferTransition(transfer, TransferStatusCompleted)
+		if err != nil {
+			return err
+		}
+
+		fromAccount, toAccount, err := store.lockAccounts(ctx, q, transfer.FromAccountID, transfer.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		err = checkAccountActive(fromAccount)
+		if err != nil {
+			return err
+		}
+
+		err = checkAccountActive(toAccount)
+		if err != nil {
+			return err
+		}
+
+		// The held money is what gets debited, so the hold goes first.
+		_, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+			Amount: -transfer.Amount,
+			ID:     transfer.FromAccountID,
+		})
+		if err != nil {
+			return err
+		}
+
+		transfer, err = q.UpdateTransferStatus(ctx, UpdateTransferStatusParams{
+			Status:     TransferStatusCompleted,
+			ID:         transfer.ID,
+			FromStatus: TransferStatusPending,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err = store.postTransfer(ctx, q, transfer, fromAccount, toAccount)
+		return err
+	})
+
+	return result, err
+}
+
+// ReleaseTransferTxResult is the result of the cancel and fail transfer transactions
+type ReleaseTransferTxResult struct {
+	Transfer    Transfer `json:"transfer"`
+	FromAccount Account  `json:"from_account"` // FromAccount no longer holds the amount of the transfer.
+}
+
+// CancelTransferTx calls off a pending transfer and releases its hold.
+// It returns a TransferStatusError if the transfer isn't pending.
+func (store *SQLStore) CancelTransferTx(ctx context.Context, transferID int64) (ReleaseTransferTxResult, error) {
+	return store.releaseTransferTx(ctx, transferID, TransferStatusCancelled)
+}
+
+// FailTransferTx gives up on a pending transfer that can't complete, e.g. because an account was frozen,
+// and releases its hold. It returns a TransferStatusError if the transfer isn't pending.
+func (store *SQLStore) FailTransferTx(ctx context.Context, transferID int64) (ReleaseTransferTxResult, error) {
+	return store.releaseTransferTx(ctx, transferID, TransferStatusFailed)
+}
+
+// releaseTransferTx moves a pending transfer to a status that ends it without moving money.
+// Frozen and closed accounts don't stop the hold from being released.
+func (store *SQLStore) releaseTransferTx(ctx context.Context, transferID int64, status string) (ReleaseTransferTxResult, error) {
+	var result ReleaseTransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		transfer, err := q.GetTransferForUpdate(ctx, transferID)
+		if err != nil {
+			return err
+		}
+
+		err = checkTransferTransition(transfer, status)
+		if err != nil {
+			return err
+		}
+
+		result.Transfer, err = q.UpdateTransferStatus(ctx, UpdateTransferStatusParams{
+			Status:     status,
+			ID:         transfer.ID,
+			FromStatus: TransferStatusPending,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.FromAccount, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+			Amount: -transfer.Amount,
+			ID:     transfer.FromAccountID,
+		})
+		return err
+	})
+
+	return result, err
+}
@@ -2,17 +2,41 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/badermezzi/KubeGoBank/util"
 	"github.com/stretchr/testify/require"
 )
 
+// createFundedAccount creates a USD customer account holding the given balance.
+// Accounts share a currency so that transfers between them need no exchange rate.
+func createFundedAccount(t *testing.T, balance int64) Account {
+	return createFundedAccountIn(t, balance, util.USD)
+}
+
+// createFundedAccountIn creates a customer account holding the given balance in the given currency.
+func createFundedAccountIn(t *testing.T, balance int64, currency string) Account {
+	user := createRandomUser(t)
+
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  balance,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
 func TestTransferTx(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createFundedAccount(t, 1000)
+	account2 := createFundedAccount(t, 1000)
 	fmt.Printf("Initial Balances: Account1: %d, Account2: %d\n", account1.Balance, account2.Balance)
 
 	n := 5
@@ -27,7 +51,7 @@ func TestTransferTx(t *testing.T) {
 			result, err := store.TransferTx(ctx, TransferTxParams{
 				FromAccountID: account1.ID,
 				ToAccountID:   account2.ID,
-				Amount:        amount,
+				Amount:        util.NewMoney(amount, account1.Currency),
 			})
 
 			errs <- err
@@ -63,6 +87,8 @@ func TestTransferTx(t *testing.T) {
 		require.NotZero(t, fromEntry.ID)
 		require.NotZero(t, fromEntry.CreatedAt)
 
+		require.Equal(t, transfer.ID, *fromEntry.TransferID)
+
 		_, err = store.GetEntry(context.Background(), fromEntry.ID)
 		require.NoError(t, err)
 
@@ -73,6 +99,8 @@ func TestTransferTx(t *testing.T) {
 		require.NotZero(t, toEntry.ID)
 		require.NotZero(t, toEntry.CreatedAt)
 
+		require.Equal(t, transfer.ID, *toEntry.TransferID)
+
 		_, err = store.GetEntry(context.Background(), toEntry.ID)
 		require.NoError(t, err)
 
@@ -112,8 +140,8 @@ func TestTransferTx(t *testing.T) {
 func TestTransferTxDeadlock(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createFundedAccount(t, 1000)
+	account2 := createFundedAccount(t, 1000)
 	fmt.Printf("Initial Balances: Account1: %d, Account2: %d\n", account1.Balance, account2.Balance)
 
 	n := 10 // Increased for more rigorous deadlock testing
@@ -136,7 +164,7 @@ func TestTransferTxDeadlock(t *testing.T) {
 			_, err := store.TransferTx(ctx, TransferTxParams{
 				FromAccountID: fromAccountID,
 				ToAccountID:   toAccountID,
-				Amount:        amount,
+				Amount:        util.NewMoney(amount, account1.Currency),
 			})
 			errs <- err
 		}()
@@ -159,3 +187,977 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, account1.Balance, updatedAccount1.Balance)
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
 }
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 10)
+	account2 := createFundedAccount(t, 10)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(11, account1.Currency),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// The whole transfer is rolled back
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+
+	updatedAccount2, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+
+	entries, err := store.ListEntries(context.Background(), ListEntriesParams{AccountID: account1.ID, PageLimit: 5})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Emptying the account exactly is fine
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(10, account1.Currency),
+	})
+	require.NoError(t, err)
+	require.Zero(t, result.FromAccount.Balance)
+}
+
+func TestTransferTxCrossCurrency(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccountIn(t, 1000, util.USD)
+	account2 := createFundedAccountIn(t, 0, util.EUR)
+
+	usdPosition, err := store.GetFXPositionAccount(context.Background(), util.USD)
+	require.NoError(t, err)
+	eurPosition, err := store.GetFXPositionAccount(context.Background(), util.EUR)
+	require.NoError(t, err)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(500, account1.Currency),
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(500), result.Transfer.Amount)
+	require.Equal(t, int64(460), result.Transfer.ToAmount)
+	require.Equal(t, "0.92", result.Transfer.ExchangeRate)
+
+	require.Equal(t, int64(-500), result.FromEntry.Amount)
+	require.Equal(t, int64(460), result.ToEntry.Amount)
+	require.Equal(t, int64(500), result.FromAccount.Balance)
+	require.Equal(t, int64(460), result.ToAccount.Balance)
+
+	// Each currency balances through its FX position account
+	require.Len(t, result.FXEntries, 2)
+	require.Equal(t, usdPosition.ID, result.FXEntries[0].AccountID)
+	require.Equal(t, int64(500), result.FXEntries[0].Amount)
+	require.Equal(t, eurPosition.ID, result.FXEntries[1].AccountID)
+	require.Equal(t, int64(-460), result.FXEntries[1].Amount)
+	for _, entry := range result.FXEntries {
+		require.Equal(t, result.Transfer.ID, *entry.TransferID)
+	}
+
+	updatedUSDPosition, err := store.GetAccount(context.Background(), usdPosition.ID)
+	require.NoError(t, err)
+	require.Equal(t, usdPosition.Balance+500, updatedUSDPosition.Balance)
+
+	updatedEURPosition, err := store.GetAccount(context.Background(), eurPosition.ID)
+	require.NoError(t, err)
+	require.Equal(t, eurPosition.Balance-460, updatedEURPosition.Balance)
+
+	// Without a rate the currencies can't be bridged
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(10, account1.Currency),
+	})
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	// Within a currency the rate is ignored
+	account3 := createFundedAccountIn(t, 0, util.USD)
+	result, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account3.ID,
+		Amount:        util.NewMoney(10, account1.Currency),
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(10), result.Transfer.ToAmount)
+	require.Equal(t, "1", result.Transfer.ExchangeRate)
+	require.Empty(t, result.FXEntries)
+}
+
+func TestTransferTxCrossCurrencyMinorUnits(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccountIn(t, 1000, util.USD)
+	account2 := createFundedAccountIn(t, 0, util.JPY)
+
+	// 10.00 USD at 150 JPY per USD is 1500 JPY, which has no minor unit
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(1000, account1.Currency),
+		ExchangeRate:  "150",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1500), result.Transfer.ToAmount)
+	require.Equal(t, int64(1500), result.ToAccount.Balance)
+
+	// 1500 JPY at 0.0067 USD per JPY is 10.05 USD
+	result, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        util.NewMoney(1500, account2.Currency),
+		ExchangeRate:  "0.0067",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1005), result.Transfer.ToAmount)
+}
+
+func TestTransferTxInvalidAmount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccountIn(t, 1000, util.USD)
+	account2 := createFundedAccountIn(t, 0, util.USD)
+
+	testCases := []struct {
+		name   string
+		amount util.Money
+		err    error
+	}{
+		{name: "Zero", amount: util.NewMoney(0, util.USD), err: ErrNonPositiveAmount},
+		{name: "Negative", amount: util.NewMoney(-10, util.USD), err: ErrNonPositiveAmount},
+		{name: "OtherCurrency", amount: util.NewMoney(10, util.EUR), err: ErrCurrencyMismatch},
+	}
+
+	for _, tc := range testCases {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        tc.amount,
+		})
+		require.ErrorIs(t, err, tc.err, tc.name)
+	}
+
+	// A balance that would overflow is an error instead of wrapping around
+	_, err := testDB.Exec("UPDATE accounts SET balance = $1 WHERE id = $2", int64(math.MaxInt64-5), account2.ID)
+	require.NoError(t, err)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(10, util.USD),
+	})
+	require.ErrorIs(t, err, util.ErrAmountOverflow)
+}
+func TestTransferTxDetails(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccountIn(t, 1000, util.USD)
+	account2 := createFundedAccountIn(t, 0, util.USD)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(10, util.USD),
+		Description:   "Rent for March",
+		Reference:     "INV-2026-0042",
+		Metadata:      json.RawMessage(`{"order_id": "42"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Rent for March", result.Transfer.Description)
+	require.Equal(t, "INV-2026-0042", result.Transfer.Reference)
+	require.JSONEq(t, `{"order_id": "42"}`, string(result.Transfer.Metadata))
+
+	// Both sides see the description on their statement
+	require.Equal(t, "Rent for March", result.FromEntry.Description)
+	require.Equal(t, "Rent for March", result.ToEntry.Description)
+
+	// Without metadata the transfer gets an empty object
+	result, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(10, util.USD),
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Transfer.Description)
+	require.JSONEq(t, `{}`, string(result.Transfer.Metadata))
+}
+
+func TestTransferTxIdempotencyKey(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createFundedAccount(t, 100)
+
+	key := &CreateIdempotencyKeyParams{
+		Username:    account1.Owner,
+		Key:         util.RandomString(16),
+		RequestHash: util.RandomString(32),
+	}
+	arg := TransferTxParams{
+		FromAccountID:  account1.ID,
+		ToAccountID:    account2.ID,
+		Amount:         util.NewMoney(10, account1.Currency),
+		IdempotencyKey: key,
+	}
+
+	// Concurrent requests with the same key move the money once and all get the same transfer back
+	n := 5
+	errs := make(chan error)
+	results := make(chan TransferTxResult)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := store.TransferTx(context.Background(), arg)
+			errs <- err
+			results <- result
+		}()
+	}
+
+	var transferID int64
+	replays := 0
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+		result := <-results
+
+		if transferID == 0 {
+			transferID = result.Transfer.ID
+		}
+		require.Equal(t, transferID, result.Transfer.ID)
+		require.Equal(t, int64(90), result.FromAccount.Balance)
+		if result.Replayed {
+			replays++
+		}
+	}
+	require.Equal(t, n-1, replays)
+
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(90), updatedAccount1.Balance)
+
+	stored, err := store.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		Username: key.Username,
+		Key:      key.Key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, transferID, *stored.TransferID)
+
+	// The stored result can be read back before a retry checks anything else
+	replayed, err := StoredTransferTxResult(stored, key.RequestHash)
+	require.NoError(t, err)
+	require.True(t, replayed.Replayed)
+	require.Equal(t, transferID, replayed.Transfer.ID)
+
+	_, err = StoredTransferTxResult(stored, util.RandomString(32))
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// The same key with a different request is rejected
+	otherKey := *key
+	otherKey.RequestHash = util.RandomString(32)
+	arg.IdempotencyKey = &otherKey
+	_, err = store.TransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// Keys are scoped to the user
+	otherUserKey := *key
+	otherUserKey.Username = account2.Owner
+	arg.IdempotencyKey = &otherUserKey
+	result, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, result.Replayed)
+	require.NotEqual(t, transferID, result.Transfer.ID)
+}
+
+func TestTransferTxIdempotencyKeyFailedTransfer(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 10)
+	account2 := createFundedAccount(t, 10)
+
+	arg := TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(20, account1.Currency),
+		IdempotencyKey: &CreateIdempotencyKeyParams{
+			Username:    account1.Owner,
+			Key:         util.RandomString(16),
+			RequestHash: util.RandomString(32),
+		},
+	}
+
+	_, err := store.TransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// A failed transfer releases its key, so the retry runs again
+	_, err = store.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		Username: arg.IdempotencyKey.Username,
+		Key:      arg.IdempotencyKey.Key,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = store.TransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestTransferTxOverdraftLimit(t *testing.T) {
+	store := NewStore(testDB)
+	banker := createRandomUser(t)
+
+	account1 := createFundedAccount(t, 10)
+	account2 := createFundedAccount(t, 10)
+
+	_, err := store.SetOverdraftLimitTx(context.Background(), SetOverdraftLimitTxParams{
+		AccountID:      account1.ID,
+		OverdraftLimit: 50,
+		ChangedBy:      banker.Username,
+	})
+	require.NoError(t, err)
+
+	// The account may go down to -50
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(60, account1.Currency),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-50), result.FromAccount.Balance)
+
+	// but not any further
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(1, account1.Currency),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestSetOverdraftLimitTx(t *testing.T) {
+	store := NewStore(testDB)
+	banker := createRandomUser(t)
+
+	account := createFundedAccount(t, 0)
+
+	result, err := store.SetOverdraftLimitTx(context.Background(), SetOverdraftLimitTxParams{
+		AccountID:      account.ID,
+		OverdraftLimit: 100,
+		ChangedBy:      banker.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(100), result.Account.OverdraftLimit)
+	require.Equal(t, account.ID, result.Change.AccountID)
+	require.Zero(t, result.Change.OldLimit)
+	require.Equal(t, int64(100), result.Change.NewLimit)
+	require.Equal(t, banker.Username, result.Change.ChangedBy)
+	require.NotZero(t, result.Change.CreatedAt)
+
+	// Use part of the overdraft, then try to lower the limit below it
+	_, err = store.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+		AccountID:  account.ID,
+		Amount:     -80,
+		ReasonCode: AdjustmentReasonFee,
+		CreatedBy:  banker.Username,
+	})
+	require.NoError(t, err)
+
+	_, err = store.SetOverdraftLimitTx(context.Background(), SetOverdraftLimitTxParams{
+		AccountID:      account.ID,
+		OverdraftLimit: 50,
+		ChangedBy:      banker.Username,
+	})
+	require.ErrorIs(t, err, ErrBalanceBelowOverdraftLimit)
+
+	changes, err := store.ListOverdraftLimitChanges(context.Background(), ListOverdraftLimitChangesParams{
+		AccountID: account.ID,
+		PageLimit: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+}
+
+func TestTransferTxInactiveAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	testCases := []struct {
+		status string
+		err    error
+	}{
+		{status: AccountStatusFrozen, err: ErrAccountFrozen},
+		{status: AccountStatusClosed, err: ErrAccountClosed},
+	}
+
+	for _, tc := range testCases {
+		account1 := createFundedAccount(t, 100)
+		account2 := createFundedAccount(t, 100)
+
+		_, err := store.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+			ID:         account2.ID,
+			Status:     tc.status,
+			FromStatus: AccountStatusActive,
+		})
+		require.NoError(t, err)
+
+		// The account can neither receive
+		_, err = store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        util.NewMoney(10, account1.Currency),
+		})
+		require.ErrorIs(t, err, tc.err)
+
+		// nor send money
+		_, err = store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account2.ID,
+			ToAccountID:   account1.ID,
+			Amount:        util.NewMoney(10, account2.Currency),
+		})
+		require.ErrorIs(t, err, tc.err)
+
+		updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+		require.NoError(t, err)
+		require.Equal(t, account1.Balance, updatedAccount1.Balance)
+	}
+}
+
+func TestCloseAccountTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	// An empty account closes right away
+	emptyAccount := createFundedAccount(t, 0)
+	result, err := store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: emptyAccount.ID})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, result.Account.Status)
+	require.Nil(t, result.Sweep)
+
+	// and stays readable
+	closedAccount, err := store.GetAccount(context.Background(), emptyAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, closedAccount.Status)
+
+	// Closing twice fails
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: emptyAccount.ID})
+	require.ErrorIs(t, err, ErrAccountClosed)
+
+	// Money can't be left behind
+	account := createFundedAccount(t, 100)
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account.ID})
+	require.ErrorIs(t, err, ErrNonZeroBalance)
+
+	// unless it is swept to another account of the same currency
+	otherCurrency := util.USD
+	if account.Currency == util.USD {
+		otherCurrency = util.EUR
+	}
+	sweepAccount, err := store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account.Owner,
+		Balance:  0,
+		Currency: otherCurrency,
+	})
+	require.NoError(t, err)
+
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{
+		AccountID:        account.ID,
+		SweepToAccountID: sweepAccount.ID,
+	})
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	owner := createRandomUser(t)
+	sweepAccount, err = store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    owner.Username,
+		Balance:  0,
+		Currency: account.Currency,
+	})
+	require.NoError(t, err)
+
+	result, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{
+		AccountID:        account.ID,
+		SweepToAccountID: sweepAccount.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AccountStatusClosed, result.Account.Status)
+	require.Zero(t, result.Account.Balance)
+	require.NotNil(t, result.Sweep)
+	require.Equal(t, account.Balance, result.Sweep.Transfer.Amount)
+	require.Equal(t, account.Balance, result.Sweep.ToAccount.Balance)
+
+	// The owner may open a new account in the same currency afterwards
+	_, err = store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account.Owner,
+		Balance:  0,
+		Currency: account.Currency,
+	})
+	require.NoError(t, err)
+}
+
+func TestAddAccountBalanceCheckConstraint(t *testing.T) {
+	account := createFundedAccount(t, 10)
+
+	// Even a raw balance update cannot take a customer account below zero
+	_, err := testQueries.AddAccountBalance(context.Background(), AddAccountBalanceParams{
+		ID:     account.ID,
+		Amount: -11,
+	})
+	require.ErrorIs(t, balanceError(err), ErrInsufficientFunds)
+}
+
+func TestRevokeUserTokensTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	session := createRandomSession(t)
+
+	user, err := store.RevokeUserTokensTx(context.Background(), session.Username)
+	require.NoError(t, err)
+	require.Equal(t, session.Username, user.Username)
+	require.WithinDuration(t, time.Now(), user.TokensRevokedAt, time.Second)
+
+	blockedSession, err := store.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, blockedSession.IsBlocked)
+
+	revocations, err := store.ListUserTokenRevocationsSince(context.Background(), user.TokensRevokedAt.Add(-time.Second))
+	require.NoError(t, err)
+
+	var found bool
+	for _, revocation := range revocations {
+		if revocation.Username == user.Username {
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestAdjustBalanceTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createFundedAccount(t, 100)
+	banker := createRandomUser(t)
+
+	suspenseAccount, err := store.GetSuspenseAccount(context.Background(), account.Currency)
+	require.NoError(t, err)
+
+	amount := int64(-25)
+	result, err := store.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+		AccountID:  account.ID,
+		Amount:     amount,
+		ReasonCode: AdjustmentReasonFee,
+		Note:       "wire fee",
+		CreatedBy:  banker.Username,
+	})
+	require.NoError(t, err)
+
+	// check adjustment
+	adjustment := result.Adjustment
+	require.NotZero(t, adjustment.ID)
+	require.Equal(t, account.ID, adjustment.AccountID)
+	require.Equal(t, suspenseAccount.ID, adjustment.SuspenseAccountID)
+	require.Equal(t, amount, adjustment.Amount)
+	require.Equal(t, AdjustmentReasonFee, adjustment.ReasonCode)
+	require.Equal(t, "wire fee", adjustment.Note)
+	require.Equal(t, banker.Username, adjustment.CreatedBy)
+
+	// check entries balance each other
+	require.Equal(t, result.Entry.ID, adjustment.EntryID)
+	require.Equal(t, result.SuspenseEntry.ID, adjustment.SuspenseEntryID)
+	require.Equal(t, account.ID, result.Entry.AccountID)
+	require.Equal(t, amount, result.Entry.Amount)
+	require.Equal(t, suspenseAccount.ID, result.SuspenseEntry.AccountID)
+	require.Equal(t, -amount, result.SuspenseEntry.Amount)
+
+	// check balances
+	require.Equal(t, account.Balance+amount, result.Account.Balance)
+	require.Equal(t, suspenseAccount.ID, result.SuspenseAccount.ID)
+
+	updatedSuspenseAccount, err := store.GetAccount(context.Background(), suspenseAccount.ID)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, updatedSuspenseAccount.Balance-suspenseAccount.Balance, -amount) // other tests may adjust concurrently
+}
+
+func TestReverseTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 1000)
+	account2 := createFundedAccount(t, 0)
+
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(300, account1.Currency),
+		Reference:     "INV-1",
+	})
+	require.NoError(t, err)
+
+	// A partial refund goes back the other way and points at the transfer
+	result, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Amount:     100,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(100), result.Transfer.ReversedAmount)
+
+	reversal := result.Reversal
+	require.Equal(t, account2.ID, reversal.Transfer.FromAccountID)
+	require.Equal(t, account1.ID, reversal.Transfer.ToAccountID)
+	require.Equal(t, int64(100), reversal.Transfer.Amount)
+	require.Equal(t, int64(100), reversal.Transfer.ToAmount)
+	require.Equal(t, transfer.Transfer.ID, *reversal.Transfer.ReversedTransferID)
+	require.Equal(t, "INV-1", reversal.Transfer.Reference)
+	require.Equal(t, fmt.Sprintf("reversal of transfer %d", transfer.Transfer.ID), reversal.Transfer.Description)
+	require.Equal(t, int64(-100), reversal.FromEntry.Amount)
+	require.Equal(t, reversal.Transfer.ID, *reversal.FromEntry.TransferID)
+	require.Equal(t, int64(100), reversal.ToEntry.Amount)
+	require.Equal(t, int64(200), reversal.FromAccount.Balance)
+	require.Equal(t, int64(800), reversal.ToAccount.Balance)
+
+	// No more than what is left can be paid back
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Amount:     201,
+	})
+	require.ErrorIs(t, err, ErrReversalExceedsTransfer)
+
+	// A reversal can't be reversed itself
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: reversal.Transfer.ID})
+	require.ErrorIs(t, err, ErrTransferIsReversal)
+
+	// No amount pays back the rest
+	result, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID:  transfer.Transfer.ID,
+		Description: "wrong recipient",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(300), result.Transfer.ReversedAmount)
+	require.Equal(t, int64(200), result.Reversal.Transfer.Amount)
+	require.Equal(t, "wrong recipient", result.Reversal.Transfer.Description)
+	require.Equal(t, TransferStatusReversed, result.Transfer.Status)
+	require.Zero(t, result.Reversal.FromAccount.Balance)
+	require.Equal(t, int64(1000), result.Reversal.ToAccount.Balance)
+
+	// and then nothing is left
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.ErrorIs(t, err, ErrTransferAlreadyReversed)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: -1})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestReverseTransferTxIdempotencyKey(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 1000)
+	account2 := createFundedAccount(t, 0)
+
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(300, account1.Currency),
+	})
+	require.NoError(t, err)
+
+	arg := ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Amount:     100,
+		IdempotencyKey: &CreateIdempotencyKeyParams{
+			Username:    account2.Owner,
+			Key:         util.RandomString(16),
+			RequestHash: util.RandomString(32),
+		},
+	}
+
+	// Retries of a partial refund pay it back once and get the first reversal back
+	first, err := store.ReverseTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, first.Replayed)
+
+	retry, err := store.ReverseTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, retry.Replayed)
+	require.Equal(t, first.Reversal.Transfer.ID, retry.Reversal.Transfer.ID)
+	require.Equal(t, int64(100), retry.Transfer.ReversedAmount)
+
+	updatedTransfer, err := store.GetTransfer(context.Background(), transfer.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), updatedTransfer.ReversedAmount)
+
+	// The same key with a different request is rejected
+	otherKey := *arg.IdempotencyKey
+	otherKey.RequestHash = util.RandomString(32)
+	arg.IdempotencyKey = &otherKey
+	_, err = store.ReverseTransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+}
+
+func TestReverseTransferTxConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 1000)
+	account2 := createFundedAccount(t, 1000)
+
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(100, account1.Currency),
+	})
+	require.NoError(t, err)
+
+	// Only one of the full reversals racing each other goes through
+	n := 5
+	errs := make(chan error)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+				TransferID: transfer.Transfer.ID,
+			})
+			errs <- err
+		}()
+	}
+
+	reversed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			reversed++
+			continue
+		}
+		require.ErrorIs(t, err, ErrTransferAlreadyReversed)
+	}
+	require.Equal(t, 1, reversed)
+
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+
+	updatedAccount2, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+}
+
+func TestReverseTransferTxCrossCurrency(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccountIn(t, 1000, util.USD)
+	account2 := createFundedAccountIn(t, 0, util.EUR)
+
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(500, account1.Currency),
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(460), transfer.Transfer.ToAmount)
+
+	// Refunds are paid in euros and give back the dollars in proportion, whatever today's rate is
+	result, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Amount:     230,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(230), result.Reversal.Transfer.Amount)
+	require.Equal(t, int64(250), result.Reversal.Transfer.ToAmount)
+	require.Equal(t, "1.0869565217", result.Reversal.Transfer.ExchangeRate)
+	require.Len(t, result.Reversal.FXEntries, 2)
+
+	result, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(230), result.Reversal.Transfer.Amount)
+	require.Equal(t, int64(250), result.Reversal.Transfer.ToAmount)
+	require.Zero(t, result.Reversal.FromAccount.Balance)
+	require.Equal(t, account1.Balance, result.Reversal.ToAccount.Balance)
+}
+
+func TestReverseTransferTxFrozenAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createFundedAccount(t, 0)
+
+	transfer, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(100, account1.Currency),
+	})
+	require.NoError(t, err)
+
+	_, err = store.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:         account2.ID,
+		Status:     AccountStatusFrozen,
+		FromStatus: AccountStatusActive,
+	})
+	require.NoError(t, err)
+
+	// The recipient can't refund out of a frozen account
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: transfer.Transfer.ID})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+
+	// but a banker can force the reversal
+	result, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: transfer.Transfer.ID,
+		Forced:     true,
+	})
+	require.NoError(t, err)
+	require.Zero(t, result.Reversal.FromAccount.Balance)
+	require.Equal(t, int64(100), result.Reversal.ToAccount.Balance)
+}
+
+func TestPendingTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 1000)
+	account2 := createFundedAccount(t, 0)
+
+	pending, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(600, account1.Currency),
+		Pending:       true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusPending, pending.Transfer.Status)
+	require.Zero(t, pending.FromEntry.ID)
+	require.Zero(t, pending.ToEntry.ID)
+	require.Equal(t, int64(1000), pending.FromAccount.Balance)
+	require.Equal(t, int64(600), pending.FromAccount.HeldAmount)
+
+	// The held money can't be spent meanwhile
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(401, account1.Currency),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(401, account1.Currency),
+		Pending:       true,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// nor can a pending transfer be reversed
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{TransferID: pending.Transfer.ID})
+	require.ErrorIs(t, err, ErrInvalidTransferTransition)
+
+	completed, err := store.CompleteTransferTx(context.Background(), pending.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusCompleted, completed.Transfer.Status)
+	require.Equal(t, int64(-600), completed.FromEntry.Amount)
+	require.Equal(t, int64(600), completed.ToEntry.Amount)
+	require.Equal(t, int64(400), completed.FromAccount.Balance)
+	require.Zero(t, completed.FromAccount.HeldAmount)
+	require.Equal(t, int64(600), completed.ToAccount.Balance)
+
+	// A completed transfer can't be completed or cancelled again
+	_, err = store.CompleteTransferTx(context.Background(), pending.Transfer.ID)
+	require.ErrorIs(t, err, ErrInvalidTransferTransition)
+
+	_, err = store.CancelTransferTx(context.Background(), pending.Transfer.ID)
+	var statusErr *TransferStatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, TransferStatusCompleted, statusErr.From)
+	require.Equal(t, TransferStatusCancelled, statusErr.To)
+}
+
+func TestReleaseTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 1000)
+	account2 := createFundedAccount(t, 0)
+
+	for _, status := range []string{TransferStatusCancelled, TransferStatusFailed} {
+		pending, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        util.NewMoney(1000, account1.Currency),
+			Pending:       true,
+		})
+		require.NoError(t, err)
+
+		var result ReleaseTransferTxResult
+		if status == TransferStatusCancelled {
+			result, err = store.CancelTransferTx(context.Background(), pending.Transfer.ID)
+		} else {
+			result, err = store.FailTransferTx(context.Background(), pending.Transfer.ID)
+		}
+		require.NoError(t, err)
+		require.Equal(t, status, result.Transfer.Status)
+		require.Equal(t, int64(1000), result.FromAccount.Balance)
+		require.Zero(t, result.FromAccount.HeldAmount)
+
+		// The transfer is over, it can't be completed any more
+		_, err = store.CompleteTransferTx(context.Background(), pending.Transfer.ID)
+		require.ErrorIs(t, err, ErrInvalidTransferTransition)
+	}
+
+	updatedAccount2, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Zero(t, updatedAccount2.Balance)
+}
+
+func TestCompleteTransferTxFrozenAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createFundedAccount(t, 0)
+
+	pending, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(100, account1.Currency),
+		Pending:       true,
+	})
+	require.NoError(t, err)
+
+	_, err = store.UpdateAccountStatus(context.Background(), UpdateAccountStatusParams{
+		ID:         account2.ID,
+		Status:     AccountStatusFrozen,
+		FromStatus: AccountStatusActive,
+	})
+	require.NoError(t, err)
+
+	// The transfer stays pending until it can be failed
+	_, err = store.CompleteTransferTx(context.Background(), pending.Transfer.ID)
+	require.ErrorIs(t, err, ErrAccountFrozen)
+
+	result, err := store.FailTransferTx(context.Background(), pending.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusFailed, result.Transfer.Status)
+	require.Zero(t, result.FromAccount.HeldAmount)
+}
+
+func TestCloseAccountTxFundsOnHold(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createFundedAccount(t, 0)
+
+	pending, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.NewMoney(100, account1.Currency),
+		Pending:       true,
+	})
+	require.NoError(t, err)
+
+	_, err = store.CompleteTransferTx(context.Background(), pending.Transfer.ID)
+	require.NoError(t, err)
+
+	pending, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        util.NewMoney(100, account2.Currency),
+		Pending:       true,
+	})
+	require.NoError(t, err)
+
+	// account1 holds nothing and is empty, account2 still holds the pending transfer
+	_, err = store.CloseAccountTx(context.Background(), CloseAccountTxParams{AccountID: account2.ID})
+	require.ErrorIs(t, err, ErrFundsOnHold)
+}
@@ -7,26 +7,87 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
 )
 
+const addTransferReversedAmount = `-- name: AddTransferReversedAmount :one
+UPDATE transfers
+SET reversed_amount = reversed_amount + $1
+WHERE id = $2
+RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, description, reference, metadata, reversed_transfer_id, reversed_amount, status
+`
+
+type AddTransferReversedAmountParams struct {
+	Amount int64 `json:"amount"`
+	ID     int64 `json:"id"`
+}
+
+func (q *Queries) AddTransferReversedAmount(ctx context.Context, arg AddTransferReversedAmountParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, addTransferReversedAmount, arg.Amount, arg.ID)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.ReversedTransferID,
+		&i.ReversedAmount,
+		&i.Status,
+	)
+	return i, err
+}
+
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  to_amount,
+  exchange_rate,
+  description,
+  reference,
+  metadata,
+  reversed_transfer_id,
+  status
 ) VALUES (
-  $1, $2, $3
-) RETURNING id, from_account_id, to_account_id, amount, created_at
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, description, reference, metadata, reversed_transfer_id, reversed_amount, status
 `
 
 type CreateTransferParams struct {
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
-	Amount        int64 `json:"amount"`
+	FromAccountID      int64           `json:"from_account_id"`
+	ToAccountID        int64           `json:"to_account_id"`
+	Amount             int64           `json:"amount"`
+	ToAmount           int64           `json:"to_amount"`
+	ExchangeRate       string          `json:"exchange_rate"`
+	Description        string          `json:"description"`
+	Reference          string          `json:"reference"`
+	Metadata           json.RawMessage `json:"metadata"`
+	ReversedTransferID *int64          `json:"reversed_transfer_id"`
+	Status             string          `json:"status"`
 }
 
 func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
-	row := q.db.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ToAmount,
+		arg.ExchangeRate,
+		arg.Description,
+		arg.Reference,
+		arg.Metadata,
+		arg.ReversedTransferID,
+		arg.Status,
+	)
 	var i Transfer
 	err := row.Scan(
 		&i.ID,
@@ -34,12 +95,20 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.ReversedTransferID,
+		&i.ReversedAmount,
+		&i.Status,
 	)
 	return i, err
 }
 
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, description, reference, metadata, reversed_transfer_id, reversed_amount, status FROM transfers
 WHERE id = $1
 LIMIT 1
 `
@@ -53,33 +122,121 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.ReversedTransferID,
+		&i.ReversedAmount,
+		&i.Status,
+	)
+	return i, err
+}
+
+const getTransferForUpdate = `-- name: GetTransferForUpdate :one
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, description, reference, metadata, reversed_transfer_id, reversed_amount, status FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, getTransferForUpdate, id)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.ReversedTransferID,
+		&i.ReversedAmount,
+		&i.Status,
+	)
+	return i, err
+}
+
+const getTransferWithOwners = `-- name: GetTransferWithOwners :one
+SELECT
+  transfers.id, transfers.from_account_id, transfers.to_account_id, transfers.amount, transfers.created_at, transfers.to_amount, transfers.exchange_rate, transfers.description, transfers.reference, transfers.metadata, transfers.reversed_transfer_id, transfers.reversed_amount, transfers.status,
+  from_account.owner AS from_owner,
+  to_account.owner AS to_owner,
+  from_account.currency AS from_currency,
+  to_account.currency AS to_currency
+FROM transfers
+JOIN accounts AS from_account ON from_account.id = transfers.from_account_id
+JOIN accounts AS to_account ON to_account.id = transfers.to_account_id
+WHERE transfers.id = $1
+LIMIT 1
+`
+
+type GetTransferWithOwnersRow struct {
+	ID                 int64           `json:"id"`
+	FromAccountID      int64           `json:"from_account_id"`
+	ToAccountID        int64           `json:"to_account_id"`
+	Amount             int64           `json:"amount"`
+	CreatedAt          time.Time       `json:"created_at"`
+	ToAmount           int64           `json:"to_amount"`
+	ExchangeRate       string          `json:"exchange_rate"`
+	Description        string          `json:"description"`
+	Reference          string          `json:"reference"`
+	Metadata           json.RawMessage `json:"metadata"`
+	ReversedTransferID *int64          `json:"reversed_transfer_id"`
+	ReversedAmount     int64           `json:"reversed_amount"`
+	Status             string          `json:"status"`
+	FromOwner          string          `json:"from_owner"`
+	ToOwner            string          `json:"to_owner"`
+	FromCurrency       string          `json:"from_currency"`
+	ToCurrency         string          `json:"to_currency"`
+}
+
+func (q *Queries) GetTransferWithOwners(ctx context.Context, id int64) (GetTransferWithOwnersRow, error) {
+	row := q.db.QueryRowContext(ctx, getTransferWithOwners, id)
+	var i GetTransferWithOwnersRow
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.ReversedTransferID,
+		&i.ReversedAmount,
+		&i.Status,
+		&i.FromOwner,
+		&i.ToOwner,
+		&i.FromCurrency,
+		&i.ToCurrency,
 	)
 	return i, err
 }
 
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, description, reference, metadata, reversed_transfer_id, reversed_amount, status
 FROM transfers
-WHERE from_account_id = $3 OR to_account_id = $4  -- List transfers involving a specific account (either sender or receiver)
+WHERE (from_account_id = $1 OR to_account_id = $1)  -- List transfers involving a specific account (either sender or receiver)
+  AND id > $2
 ORDER BY id
-LIMIT $1
-OFFSET $2
+LIMIT $3
 `
 
 type ListTransfersParams struct {
-	Limit         int32 `json:"limit"`
-	Offset        int32 `json:"offset"`
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
+	AccountID int64 `json:"account_id"`
+	AfterID   int64 `json:"after_id"`
+	PageLimit int32 `json:"page_limit"`
 }
 
 func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error) {
-	rows, err := q.db.QueryContext(ctx, listTransfers,
-		arg.Limit,
-		arg.Offset,
-		arg.FromAccountID,
-		arg.ToAccountID,
-	)
+	rows, err := q.db.QueryContext(ctx, listTransfers, arg.AccountID, arg.AfterID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +250,14 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.Description,
+			&i.Reference,
+			&i.Metadata,
+			&i.ReversedTransferID,
+			&i.ReversedAmount,
+			&i.Status,
 		); err != nil {
 			return nil, err
 		}
@@ -106,3 +271,167 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 	}
 	return items, nil
 }
+
+const listUserTransfers = `-- name: ListUserTransfers :many
+SELECT
+  transfers.id, transfers.from_account_id, transfers.to_account_id, transfers.amount, transfers.created_at, transfers.to_amount, transfers.exchange_rate, transfers.description, transfers.reference, transfers.metadata, transfers.reversed_transfer_id, transfers.reversed_amount, transfers.status,
+  from_account.currency AS from_currency,
+  to_account.currency AS to_currency
+FROM transfers
+JOIN accounts AS from_account ON from_account.id = transfers.from_account_id
+JOIN accounts AS to_account ON to_account.id = transfers.to_account_id
+WHERE (from_account.owner = $1 OR to_account.owner = $1)
+  AND transfers.id > $2
+  AND (
+    $3::bigint IS NULL
+    OR (transfers.from_account_id = $3 AND from_account.owner = $1)
+    OR (transfers.to_account_id = $3 AND to_account.owner = $1)
+  )
+  AND (
+    $4::varchar = ''
+    OR (
+      $4 = 'outgoing' AND from_account.owner = $1
+      AND ($3 IS NULL OR transfers.from_account_id = $3)
+    )
+    OR (
+      $4 = 'incoming' AND to_account.owner = $1
+      AND ($3 IS NULL OR transfers.to_account_id = $3)
+    )
+  )
+  AND (
+    $5::bigint IS NULL
+    OR (transfers.from_account_id = $5 AND to_account.owner = $1)
+    OR (transfers.to_account_id = $5 AND from_account.owner = $1)
+  )
+  AND ($6::bigint IS NULL OR transfers.amount >= $6)
+  AND ($7::bigint IS NULL OR transfers.amount <= $7)
+  AND ($8::timestamptz IS NULL OR transfers.created_at >= $8)
+  AND ($9::timestamptz IS NULL OR transfers.created_at < $9)
+  AND ($10::varchar IS NULL OR transfers.reference = $10)
+  AND ($11::varchar IS NULL OR strpos(lower(transfers.description), lower($11)) > 0)
+  AND ($12::varchar IS NULL OR transfers.metadata @> $12::jsonb)
+ORDER BY transfers.id
+LIMIT $13
+`
+
+type ListUserTransfersParams struct {
+	Owner                 string         `json:"owner"`
+	AfterID               int64          `json:"after_id"`
+	AccountID             sql.NullInt64  `json:"account_id"`
+	Direction             string         `json:"direction"`
+	CounterpartyAccountID sql.NullInt64  `json:"counterparty_account_id"`
+	MinAmount             sql.NullInt64  `json:"min_amount"`
+	MaxAmount             sql.NullInt64  `json:"max_amount"`
+	FromTime              sql.NullTime   `json:"from_time"`
+	ToTime                sql.NullTime   `json:"to_time"`
+	Reference             sql.NullString `json:"reference"`
+	Description           sql.NullString `json:"description"`
+	Metadata              sql.NullString `json:"metadata"`
+	PageLimit             int32          `json:"page_limit"`
+}
+
+type ListUserTransfersRow struct {
+	ID                 int64           `json:"id"`
+	FromAccountID      int64           `json:"from_account_id"`
+	ToAccountID        int64           `json:"to_account_id"`
+	Amount             int64           `json:"amount"`
+	CreatedAt          time.Time       `json:"created_at"`
+	ToAmount           int64           `json:"to_amount"`
+	ExchangeRate       string          `json:"exchange_rate"`
+	Description        string          `json:"description"`
+	Reference          string          `json:"reference"`
+	Metadata           json.RawMessage `json:"metadata"`
+	ReversedTransferID *int64          `json:"reversed_transfer_id"`
+	ReversedAmount     int64           `json:"reversed_amount"`
+	Status             string          `json:"status"`
+	FromCurrency       string          `json:"from_currency"`
+	ToCurrency         string          `json:"to_currency"`
+}
+
+// Lists transfers touching any account of the owner. Direction and counterparty are seen from the owner's side.
+func (q *Queries) ListUserTransfers(ctx context.Context, arg ListUserTransfersParams) ([]ListUserTransfersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserTransfers,
+		arg.Owner,
+		arg.AfterID,
+		arg.AccountID,
+		arg.Direction,
+		arg.CounterpartyAccountID,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.FromTime,
+		arg.ToTime,
+		arg.Reference,
+		arg.Description,
+		arg.Metadata,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUserTransfersRow{}
+	for rows.Next() {
+		var i ListUserTransfersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.Description,
+			&i.Reference,
+			&i.Metadata,
+			&i.ReversedTransferID,
+			&i.ReversedAmount,
+			&i.Status,
+			&i.FromCurrency,
+			&i.ToCurrency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateTransferStatus = `-- name: UpdateTransferStatus :one
+UPDATE transfers
+SET status = $1
+WHERE id = $2 AND status = $3
+RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, description, reference, metadata, reversed_transfer_id, reversed_amount, status
+`
+
+type UpdateTransferStatusParams struct {
+	Status     string `json:"status"`
+	ID         int64  `json:"id"`
+	FromStatus string `json:"from_status"`
+}
+
+func (q *Queries) UpdateTransferStatus(ctx context.Context, arg UpdateTransferStatusParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, updateTransferStatus, arg.Status, arg.ID, arg.FromStatus)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.Description,
+		&i.Reference,
+		&i.Metadata,
+		&i.ReversedTransferID,
+		&i.ReversedAmount,
+		&i.Status,
+	)
+	return i, err
+}
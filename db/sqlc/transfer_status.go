@@ -0,0 +1,52 @@
+package db
+
+import "fmt"
+
+// Transfer statuses stored in transfers.status
+const (
+	TransferStatusPending   = "pending"   // TransferStatusPending transfers hold their amount on the from account until they settle
+	TransferStatusCompleted = "completed" // TransferStatusCompleted transfers have posted their entries and moved the money
+	TransferStatusFailed    = "failed"    // TransferStatusFailed transfers could not complete, their hold is released
+	TransferStatusCancelled = "cancelled" // TransferStatusCancelled transfers were called off while pending, their hold is released
+	TransferStatusReversed  = "reversed"  // TransferStatusReversed transfers were paid back in full by reversals
+)
+
+// transferStatusTransitions lists the statuses a transfer may move to from each status.
+var transferStatusTransitions = map[string][]string{
+	TransferStatusPending:   {TransferStatusCompleted, TransferStatusFailed, TransferStatusCancelled},
+	TransferStatusCompleted: {TransferStatusReversed},
+}
+
+// CanTransitionTransferStatus reports whether a transfer may move from one status to another.
+func CanTransitionTransferStatus(from string, to string) bool {
+	for _, status := range transferStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransferStatusError is returned when a transfer would move to a status it can't reach from its current one.
+type TransferStatusError struct {
+	TransferID int64
+	From       string
+	To         string
+}
+
+func (err *TransferStatusError) Error() string {
+	return fmt.Sprintf("transfer [%d] cannot go from %s to %s", err.TransferID, err.From, err.To)
+}
+
+// Is lets errors.Is(err, ErrInvalidTransferTransition) match any transition.
+func (err *TransferStatusError) Is(target error) bool {
+	return target == ErrInvalidTransferTransition
+}
+
+// checkTransferTransition returns a TransferStatusError if the transfer can't move to the status.
+func checkTransferTransition(transfer Transfer, to string) error {
+	if !CanTransitionTransferStatus(transfer.Status, to) {
+		return &TransferStatusError{TransferID: transfer.ID, From: transfer.Status, To: to}
+	}
+	return nil
+}
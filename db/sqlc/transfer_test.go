@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -26,6 +28,12 @@ func createRandomTransfer(t *testing.T) Transfer {
 		FromAccountID: account1.ID,
 		ToAccountID:   account2.ID,
 		Amount:        amount,
+		ToAmount:      amount,
+		ExchangeRate:  "1",
+		Description:   util.RandomString(20),
+		Reference:     util.RandomString(10),
+		Metadata:      json.RawMessage(`{"order_id": "42"}`),
+		Status:        TransferStatusCompleted,
 	}
 
 	transfer, err := testQueries.CreateTransfer(context.Background(), arg)
@@ -35,6 +43,12 @@ func createRandomTransfer(t *testing.T) Transfer {
 	require.Equal(t, arg.FromAccountID, transfer.FromAccountID)
 	require.Equal(t, arg.ToAccountID, transfer.ToAccountID)
 	require.Equal(t, arg.Amount, transfer.Amount)
+	require.Equal(t, arg.ToAmount, transfer.ToAmount)
+	require.Equal(t, arg.ExchangeRate, transfer.ExchangeRate)
+	require.Equal(t, arg.Description, transfer.Description)
+	require.Equal(t, arg.Reference, transfer.Reference)
+	require.JSONEq(t, string(arg.Metadata), string(transfer.Metadata))
+	require.Equal(t, arg.Status, transfer.Status)
 
 	require.NotZero(t, transfer.ID)
 	require.NotZero(t, transfer.CreatedAt)
@@ -74,12 +88,15 @@ func TestListTransfers(t *testing.T) {
 	}
 
 	arg := ListTransfersParams{
-		Limit:         5,
-		Offset:        5,
-		FromAccountID: account.ID, // Filter by from_account_id
-		ToAccountID:   account.ID, // Also filter by to_account_id (in OR condition of query)
+		AccountID: account.ID, // Filter by from_account_id or to_account_id
+		PageLimit: 5,
 	}
 
+	firstPage, err := testQueries.ListTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, firstPage, 5)
+	arg.AfterID = firstPage[len(firstPage)-1].ID // Second page starts after the first one
+
 	transfers, err := testQueries.ListTransfers(context.Background(), arg)
 	require.NoError(t, err)
 	require.Len(t, transfers, 5) // Expecting 5 transfers based on limit
@@ -98,6 +115,10 @@ func createRandomTransferWithAccount(t *testing.T, fromAccount Account, toAccoun
 		FromAccountID: fromAccount.ID,
 		ToAccountID:   toAccount.ID,
 		Amount:        amount,
+		ToAmount:      amount,
+		ExchangeRate:  "1",
+		Metadata:      json.RawMessage(`{}`),
+		Status:        TransferStatusCompleted,
 	}
 
 	transfer, err := testQueries.CreateTransfer(context.Background(), arg)
@@ -113,3 +134,150 @@ func createRandomTransferWithAccount(t *testing.T, fromAccount Account, toAccoun
 
 	return transfer
 }
+
+func TestGetTransferWithOwners(t *testing.T) {
+	transfer := createRandomTransfer(t)
+
+	row, err := testQueries.GetTransferWithOwners(context.Background(), transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, transfer.ID, row.ID)
+	require.Equal(t, transfer.Amount, row.Amount)
+
+	fromAccount, err := testQueries.GetAccount(context.Background(), transfer.FromAccountID)
+	require.NoError(t, err)
+	toAccount, err := testQueries.GetAccount(context.Background(), transfer.ToAccountID)
+	require.NoError(t, err)
+
+	require.Equal(t, fromAccount.Owner, row.FromOwner)
+	require.Equal(t, toAccount.Owner, row.ToOwner)
+	require.Equal(t, fromAccount.Currency, row.FromCurrency)
+	require.Equal(t, toAccount.Currency, row.ToCurrency)
+}
+
+func TestListUserTransfers(t *testing.T) {
+	account := createRandomAccount(t)
+	other1 := createRandomAccount(t)
+	other2 := createRandomAccount(t)
+
+	createTransfer := func(from, to Account, amount int64, description string, reference string, metadata string) Transfer {
+		transfer, err := testQueries.CreateTransfer(context.Background(), CreateTransferParams{
+			FromAccountID: from.ID,
+			ToAccountID:   to.ID,
+			Amount:        amount,
+			ToAmount:      amount,
+			ExchangeRate:  "1",
+			Description:   description,
+			Reference:     reference,
+			Metadata:      json.RawMessage(metadata),
+			Status:        TransferStatusCompleted,
+		})
+		require.NoError(t, err)
+		return transfer
+	}
+
+	reference := util.RandomString(12)
+	outgoing := createTransfer(account, other1, 100, "March Rent", reference, `{"order_id": "42", "channel": "web"}`)
+	incoming := createTransfer(other2, account, 200, "Refund", "", `{}`)
+	createTransfer(other1, other2, 300, "Rent", reference, `{"order_id": "42"}`) // does not touch the owner
+
+	list := func(arg ListUserTransfersParams) []ListUserTransfersRow {
+		arg.Owner = account.Owner
+		arg.PageLimit = 10
+		transfers, err := testQueries.ListUserTransfers(context.Background(), arg)
+		require.NoError(t, err)
+		return transfers
+	}
+
+	transfers := list(ListUserTransfersParams{})
+	require.Len(t, transfers, 2)
+	require.Equal(t, outgoing.ID, transfers[0].ID)
+	require.Equal(t, incoming.ID, transfers[1].ID)
+	require.Equal(t, account.Currency, transfers[0].FromCurrency)
+	require.Equal(t, other1.Currency, transfers[0].ToCurrency)
+
+	transfers = list(ListUserTransfersParams{Direction: "outgoing"})
+	require.Len(t, transfers, 1)
+	require.Equal(t, outgoing.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{Direction: "incoming"})
+	require.Len(t, transfers, 1)
+	require.Equal(t, incoming.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{CounterpartyAccountID: sql.NullInt64{Int64: other2.ID, Valid: true}})
+	require.Len(t, transfers, 1)
+	require.Equal(t, incoming.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{MinAmount: sql.NullInt64{Int64: 150, Valid: true}})
+	require.Len(t, transfers, 1)
+	require.Equal(t, incoming.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{MaxAmount: sql.NullInt64{Int64: 150, Valid: true}})
+	require.Len(t, transfers, 1)
+	require.Equal(t, outgoing.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{AccountID: sql.NullInt64{Int64: other1.ID, Valid: true}})
+	require.Empty(t, transfers) // other1 is not one of the owner's accounts
+
+	transfers = list(ListUserTransfersParams{ToTime: sql.NullTime{Time: outgoing.CreatedAt.Add(-time.Minute), Valid: true}})
+	require.Empty(t, transfers)
+
+	transfers = list(ListUserTransfersParams{Reference: sql.NullString{String: reference, Valid: true}})
+	require.Len(t, transfers, 1)
+	require.Equal(t, outgoing.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{Description: sql.NullString{String: "rent", Valid: true}})
+	require.Len(t, transfers, 1)
+	require.Equal(t, outgoing.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{Metadata: sql.NullString{String: `{"order_id": "42"}`, Valid: true}})
+	require.Len(t, transfers, 1)
+	require.Equal(t, outgoing.ID, transfers[0].ID)
+
+	transfers = list(ListUserTransfersParams{Metadata: sql.NullString{String: `{"order_id": "43"}`, Valid: true}})
+	require.Empty(t, transfers)
+}
+
+func TestUpdateTransferStatus(t *testing.T) {
+	transfer1 := createRandomTransfer(t)
+
+	transfer2, err := testQueries.UpdateTransferStatus(context.Background(), UpdateTransferStatusParams{
+		ID:         transfer1.ID,
+		Status:     TransferStatusReversed,
+		FromStatus: TransferStatusCompleted,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusReversed, transfer2.Status)
+
+	// The transfer is no longer completed, so the guarded update matches nothing
+	_, err = testQueries.UpdateTransferStatus(context.Background(), UpdateTransferStatusParams{
+		ID:         transfer1.ID,
+		Status:     TransferStatusReversed,
+		FromStatus: TransferStatusCompleted,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestCanTransitionTransferStatus(t *testing.T) {
+	require.True(t, CanTransitionTransferStatus(TransferStatusPending, TransferStatusCompleted))
+	require.True(t, CanTransitionTransferStatus(TransferStatusPending, TransferStatusFailed))
+	require.True(t, CanTransitionTransferStatus(TransferStatusPending, TransferStatusCancelled))
+	require.True(t, CanTransitionTransferStatus(TransferStatusCompleted, TransferStatusReversed))
+	require.False(t, CanTransitionTransferStatus(TransferStatusPending, TransferStatusReversed))
+	require.False(t, CanTransitionTransferStatus(TransferStatusCompleted, TransferStatusCancelled))
+	require.False(t, CanTransitionTransferStatus(TransferStatusCancelled, TransferStatusCompleted))
+	require.False(t, CanTransitionTransferStatus(TransferStatusReversed, TransferStatusCompleted))
+	require.False(t, CanTransitionTransferStatus(TransferStatusPending, TransferStatusPending))
+}
+
+func TestTransferStatusError(t *testing.T) {
+	err := checkTransferTransition(Transfer{ID: 7, Status: TransferStatusCancelled}, TransferStatusCompleted)
+	require.ErrorIs(t, err, ErrInvalidTransferTransition)
+	require.EqualError(t, err, "transfer [7] cannot go from cancelled to completed")
+
+	var statusErr *TransferStatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, TransferStatusCancelled, statusErr.From)
+	require.Equal(t, TransferStatusCompleted, statusErr.To)
+
+	require.NoError(t, checkTransferTransition(Transfer{ID: 7, Status: TransferStatusPending}, TransferStatusCompleted))
+}
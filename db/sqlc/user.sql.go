@@ -7,6 +7,7 @@ package db
 
 import (
 	"context"
+	"time"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -17,7 +18,7 @@ INSERT INTO users (
   email
 ) VALUES (
   $1, $2, $3, $4
-) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at
+) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role
 `
 
 type CreateUserParams struct {
@@ -42,12 +43,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.TokensRevokedAt,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at FROM users
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role FROM users
 WHERE username = $1 LIMIT 1
 `
 
@@ -61,6 +64,109 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.TokensRevokedAt,
+		&i.Role,
+	)
+	return i, err
+}
+
+const listUserTokenRevocationsSince = `-- name: ListUserTokenRevocationsSince :many
+SELECT username, tokens_revoked_at FROM users
+WHERE tokens_revoked_at > $1
+ORDER BY tokens_revoked_at
+`
+
+type ListUserTokenRevocationsSinceRow struct {
+	Username        string    `json:"username"`
+	TokensRevokedAt time.Time `json:"tokens_revoked_at"`
+}
+
+func (q *Queries) ListUserTokenRevocationsSince(ctx context.Context, tokensRevokedAt time.Time) ([]ListUserTokenRevocationsSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserTokenRevocationsSince, tokensRevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUserTokenRevocationsSinceRow{}
+	for rows.Next() {
+		var i ListUserTokenRevocationsSinceRow
+		if err := rows.Scan(&i.Username, &i.TokensRevokedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role FROM users
+WHERE username > $1
+ORDER BY username
+LIMIT $2
+`
+
+type ListUsersParams struct {
+	AfterUsername string `json:"after_username"`
+	PageLimit     int32  `json:"page_limit"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.AfterUsername, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.Username,
+			&i.HashedPassword,
+			&i.FullName,
+			&i.Email,
+			&i.PasswordChangedAt,
+			&i.CreatedAt,
+			&i.TokensRevokedAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :one
+UPDATE users
+SET tokens_revoked_at = now()
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role
+`
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, revokeUserTokens, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.TokensRevokedAt,
+		&i.Role,
 	)
 	return i, err
 }
@@ -53,3 +53,8 @@ func TestGetUser(t *testing.T) {
 	require.WithinDuration(t, user1.PasswordChangedAt, user2.PasswordChangedAt, time.Second)
 	require.WithinDuration(t, user1.CreatedAt, user2.CreatedAt, time.Second)
 }
+
+func TestCreateUserDefaultRole(t *testing.T) {
+	user := createRandomUser(t)
+	require.Equal(t, util.DepositorRole, user.Role)
+}
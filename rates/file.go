@@ -0,0 +1,114 @@
+package rates
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileProvider reads rates from a CSV or JSON file dropped on disk, picked by the file extension.
+// The file is read again on every call, so it can be replaced while the server runs.
+//
+// CSV rows are base,quote,rate[,valid_from] with an optional header row. JSON is an array of Rate.
+// Rates without valid_from are valid from the file's modification time.
+type FileProvider struct {
+	path string
+}
+
+// NewFileProvider creates a FileProvider. The file does not have to exist yet.
+func NewFileProvider(path string) (*FileProvider, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".json":
+		return &FileProvider{path: path}, nil
+	}
+	return nil, fmt.Errorf("rates file %q must be a .csv or .json file", path)
+}
+
+// Name implements RateProvider.
+func (provider *FileProvider) Name() string {
+	return "file:" + filepath.Base(provider.path)
+}
+
+// Rates implements RateProvider.
+func (provider *FileProvider) Rates(ctx context.Context) ([]Rate, error) {
+	file, err := os.Open(provider.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []Rate
+	if strings.ToLower(filepath.Ext(provider.path)) == ".json" {
+		err = json.NewDecoder(file).Decode(&rates)
+	} else {
+		rates, err = readCSVRates(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rates file %s: %w", provider.path, err)
+	}
+
+	for i := range rates {
+		if rates[i].ValidFrom.IsZero() {
+			rates[i].ValidFrom = info.ModTime()
+		}
+
+		err = rates[i].Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate in %s: %w", provider.path, err)
+		}
+	}
+
+	return rates, nil
+}
+
+func readCSVRates(reader io.Reader) ([]Rate, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	var rates []Rate
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line == 1 && strings.EqualFold(record[0], "base") {
+			continue // header row
+		}
+
+		if len(record) < 3 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: want base,quote,rate[,valid_from]", line)
+		}
+
+		rate := Rate{
+			Base:  record[0],
+			Quote: record[1],
+			Rate:  record[2],
+		}
+
+		if len(record) == 4 && record[3] != "" {
+			rate.ValidFrom, err = time.Parse(time.RFC3339, record[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		rates = append(rates, rate)
+	}
+}
@@ -0,0 +1,71 @@
+package rates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRatesFile(t *testing.T, name string, content string) *FileProvider {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	require.NoError(t, err)
+
+	provider, err := NewFileProvider(path)
+	require.NoError(t, err)
+	return provider
+}
+
+func TestFileProviderCSV(t *testing.T) {
+	provider := writeRatesFile(t, "rates.csv", "base,quote,rate,valid_from\n"+
+		"USD,EUR,0.92,2024-01-02T15:04:05Z\n"+
+		"EUR,USD,1.08\n")
+	require.Equal(t, "file:rates.csv", provider.Name())
+
+	info, err := os.Stat(provider.path)
+	require.NoError(t, err)
+
+	rates, err := provider.Rates(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Rate{
+		{Base: util.USD, Quote: util.EUR, Rate: "0.92", ValidFrom: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{Base: util.EUR, Quote: util.USD, Rate: "1.08", ValidFrom: info.ModTime()},
+	}, rates)
+}
+
+func TestFileProviderJSON(t *testing.T) {
+	provider := writeRatesFile(t, "rates.json", `[{"base":"USD","quote":"CAD","rate":"1.37","valid_from":"2024-01-02T15:04:05Z"}]`)
+
+	rates, err := provider.Rates(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Rate{
+		{Base: util.USD, Quote: util.CAD, Rate: "1.37", ValidFrom: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+	}, rates)
+}
+
+func TestFileProviderInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"columns.csv":    "USD,EUR\n",
+		"currency.csv":   "USD,US,1.2\n",
+		"rate.csv":       "USD,EUR,-1\n",
+		"valid_from.csv": "USD,EUR,0.92,yesterday\n",
+		"syntax.json":    `{"base":"USD"`,
+	} {
+		provider := writeRatesFile(t, name, content)
+		_, err := provider.Rates(context.Background())
+		require.Error(t, err, name)
+	}
+
+	provider, err := NewFileProvider(filepath.Join(t.TempDir(), "missing.csv"))
+	require.NoError(t, err)
+	_, err = provider.Rates(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = NewFileProvider("rates.yaml")
+	require.Error(t, err)
+}
@@ -0,0 +1,58 @@
+// Package rates loads exchange rates from a provider and keeps their history in the database.
+package rates
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+)
+
+// Rate says how many units of Quote one unit of Base buys, from ValidFrom until a newer rate of the pair.
+type Rate struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      string    `json:"rate"`
+	ValidFrom time.Time `json:"valid_from"`
+}
+
+// Validate checks that the pair is made of two different currency codes and the rate is a positive decimal.
+// Whether the bank supports both currencies is checked against the currency registry when the rate is stored.
+func (rate Rate) Validate() error {
+	if !util.IsCurrencyCode(rate.Base) || !util.IsCurrencyCode(rate.Quote) || rate.Base == rate.Quote {
+		return fmt.Errorf("invalid currency pair %s/%s", rate.Base, rate.Quote)
+	}
+
+	r, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok || r.Sign() <= 0 {
+		return fmt.Errorf("rate %q of %s/%s is not a positive decimal", rate.Rate, rate.Base, rate.Quote)
+	}
+
+	if rate.ValidFrom.IsZero() {
+		return fmt.Errorf("rate of %s/%s has no valid_from", rate.Base, rate.Quote)
+	}
+	return nil
+}
+
+// RateProvider is a source of exchange rates.
+type RateProvider interface {
+	// Name identifies the provider on the rates it provides.
+	Name() string
+
+	// Rates returns the rates the provider knows right now. The history of a pair may be partial.
+	Rates(ctx context.Context) ([]Rate, error)
+}
+
+// NewProvider creates the RateProvider named by RATES_PROVIDER.
+func NewProvider(config util.Config) (RateProvider, error) {
+	switch config.RatesProvider {
+	case "", "static":
+		// The refresher skips rates equal to the one in effect, so restarts do not add history rows
+		return NewStaticProviderFromConfig(config.ExchangeRates, time.Now())
+	case "file":
+		return NewFileProvider(config.RatesFile)
+	}
+	return nil, fmt.Errorf("unsupported rates provider %q", config.RatesProvider)
+}
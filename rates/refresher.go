@@ -0,0 +1,80 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+)
+
+// Store is the part of db.Store the refresher writes rates to.
+type Store interface {
+	CreateExchangeRate(ctx context.Context, arg db.CreateExchangeRateParams) (int64, error)
+}
+
+// Refresher copies the rates of a provider into the database, which keeps the history of every pair.
+type Refresher struct {
+	provider RateProvider
+	store    Store
+}
+
+// NewRefresher creates a Refresher.
+func NewRefresher(provider RateProvider, store Store) *Refresher {
+	return &Refresher{
+		provider: provider,
+		store:    store,
+	}
+}
+
+// Refresh stores the provider's current rates and returns how many of them were new.
+// Rates already stored for the same pair and valid_from, or equal to the rate already in effect, are skipped.
+// A rate that cannot be stored is logged and does not stop the others; the returned error joins every failure.
+func (refresher *Refresher) Refresh(ctx context.Context) (int, error) {
+	rates, err := refresher.provider.Rates(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	stored := 0
+	var errs []error
+	for _, rate := range rates {
+		rows, err := refresher.store.CreateExchangeRate(ctx, db.CreateExchangeRateParams{
+			BaseCurrency:  rate.Base,
+			QuoteCurrency: rate.Quote,
+			Rate:          rate.Rate,
+			ValidFrom:     rate.ValidFrom,
+			Source:        refresher.provider.Name(),
+		})
+		if err != nil {
+			err = fmt.Errorf("cannot store %s/%s rate valid from %s: %w", rate.Base, rate.Quote, rate.ValidFrom.Format(time.RFC3339), err)
+			log.Print(err)
+			errs = append(errs, err)
+			continue
+		}
+		stored += int(rows)
+	}
+
+	return stored, errors.Join(errs...)
+}
+
+// Run refreshes the rates every interval until the context is cancelled.
+func (refresher *Refresher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := refresher.Refresh(ctx)
+		if err != nil {
+			log.Printf("cannot refresh exchange rates from %s: %v", refresher.provider.Name(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package rates
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresherRefresh(t *testing.T) {
+	validFrom := time.Now()
+	provider, err := NewStaticProvider(
+		Rate{Base: util.USD, Quote: util.EUR, Rate: "0.92", ValidFrom: validFrom},
+		Rate{Base: util.EUR, Quote: util.USD, Rate: "1.08", ValidFrom: validFrom},
+	)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateExchangeRate(gomock.Any(), gomock.Eq(db.CreateExchangeRateParams{
+			BaseCurrency:  util.USD,
+			QuoteCurrency: util.EUR,
+			Rate:          "0.92",
+			ValidFrom:     validFrom,
+			Source:        "static",
+		})).
+		Times(1).
+		Return(int64(1), nil)
+	store.EXPECT().
+		CreateExchangeRate(gomock.Any(), gomock.Eq(db.CreateExchangeRateParams{
+			BaseCurrency:  util.EUR,
+			QuoteCurrency: util.USD,
+			Rate:          "1.08",
+			ValidFrom:     validFrom,
+			Source:        "static",
+		})).
+		Times(1).
+		Return(int64(0), nil) // already stored
+
+	stored, err := NewRefresher(provider, store).Refresh(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, stored)
+}
+
+func TestRefresherRefreshError(t *testing.T) {
+	provider, err := NewStaticProvider(Rate{Base: util.USD, Quote: util.EUR, Rate: "0.92", ValidFrom: time.Now()})
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().CreateExchangeRate(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), sql.ErrConnDone)
+
+	_, err = NewRefresher(provider, store).Refresh(context.Background())
+	require.ErrorIs(t, err, sql.ErrConnDone)
+
+	// A rate that cannot be stored does not block the rates after it
+	provider, err = NewStaticProvider(
+		Rate{Base: util.USD, Quote: "XXX", Rate: "2", ValidFrom: time.Now()},
+		Rate{Base: util.USD, Quote: util.EUR, Rate: "0.92", ValidFrom: time.Now()},
+	)
+	require.NoError(t, err)
+
+	store.EXPECT().
+		CreateExchangeRate(gomock.Any(), gomock.Any()).
+		Times(2).
+		DoAndReturn(func(_ context.Context, arg db.CreateExchangeRateParams) (int64, error) {
+			if arg.QuoteCurrency == "XXX" {
+				return 0, &pq.Error{Code: "23503"} // currency not in the registry
+			}
+			return 1, nil
+		})
+
+	stored, err := NewRefresher(provider, store).Refresh(context.Background())
+	var pqErr *pq.Error
+	require.ErrorAs(t, err, &pqErr)
+	require.ErrorContains(t, err, "USD/XXX")
+	require.Equal(t, 1, stored)
+
+	fileProvider, err := NewFileProvider("missing.csv")
+	require.NoError(t, err)
+
+	_, err = NewRefresher(fileProvider, store).Refresh(context.Background())
+	require.Error(t, err)
+}
+
+func TestRefresherRun(t *testing.T) {
+	provider, err := NewStaticProvider(Rate{Base: util.USD, Quote: util.EUR, Rate: "0.92", ValidFrom: time.Now()})
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateExchangeRate(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(context.Context, db.CreateExchangeRateParams) (int64, error) {
+			cancel() // stop after the first refresh
+			return 1, nil
+		})
+
+	done := make(chan struct{})
+	go func() {
+		NewRefresher(provider, store).Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresher did not stop")
+	}
+}
@@ -0,0 +1,62 @@
+package rates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StaticProvider always returns the same rates. It serves rates set in the config and tests.
+type StaticProvider struct {
+	rates []Rate
+}
+
+// NewStaticProvider creates a StaticProvider. It returns an error if a rate is invalid.
+func NewStaticProvider(rates ...Rate) (*StaticProvider, error) {
+	for _, rate := range rates {
+		err := rate.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &StaticProvider{rates: rates}, nil
+}
+
+// NewStaticProviderFromConfig creates a StaticProvider from EXCHANGE_RATES, written as FROM/TO=RATE
+// where one unit of FROM buys RATE units of TO. Each direction is configured on its own, so buy and sell
+// rates can differ. The rates are valid from the given time.
+func NewStaticProviderFromConfig(values []string, validFrom time.Time) (*StaticProvider, error) {
+	rates := make([]Rate, 0, len(values))
+
+	for _, value := range values {
+		pair, rate, ok := strings.Cut(strings.TrimSpace(value), "=")
+		if !ok {
+			return nil, fmt.Errorf("exchange rate %q is not written as FROM/TO=RATE", value)
+		}
+
+		base, quote, ok := strings.Cut(pair, "/")
+		if !ok {
+			return nil, fmt.Errorf("exchange rate %q is not written as FROM/TO=RATE", value)
+		}
+
+		rates = append(rates, Rate{
+			Base:      base,
+			Quote:     quote,
+			Rate:      rate,
+			ValidFrom: validFrom,
+		})
+	}
+
+	return NewStaticProvider(rates...)
+}
+
+// Name implements RateProvider.
+func (provider *StaticProvider) Name() string {
+	return "static"
+}
+
+// Rates implements RateProvider.
+func (provider *StaticProvider) Rates(ctx context.Context) ([]Rate, error) {
+	return provider.rates, nil
+}
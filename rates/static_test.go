@@ -0,0 +1,46 @@
+package rates
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStaticProviderFromConfig(t *testing.T) {
+	validFrom := time.Now()
+
+	provider, err := NewStaticProviderFromConfig([]string{"USD/EUR=0.92", " EUR/USD=1.08 "}, validFrom)
+	require.NoError(t, err)
+	require.Equal(t, "static", provider.Name())
+
+	rates, err := provider.Rates(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Rate{
+		{Base: util.USD, Quote: util.EUR, Rate: "0.92", ValidFrom: validFrom},
+		{Base: util.EUR, Quote: util.USD, Rate: "1.08", ValidFrom: validFrom},
+	}, rates)
+
+	for _, value := range []string{"USD/EUR", "USD-EUR=0.92", "USD/usd=1", "USD/USD=1", "USD/EUR=0", "USD/EUR=-1", "USD/EUR=abc"} {
+		_, err := NewStaticProviderFromConfig([]string{value}, validFrom)
+		require.Error(t, err, value)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	provider, err := NewProvider(util.Config{ExchangeRates: []string{"USD/EUR=0.92"}})
+	require.NoError(t, err)
+	require.IsType(t, &StaticProvider{}, provider)
+
+	provider, err = NewProvider(util.Config{RatesProvider: "file", RatesFile: "rates.csv"})
+	require.NoError(t, err)
+	require.IsType(t, &FileProvider{}, provider)
+
+	_, err = NewProvider(util.Config{RatesProvider: "file", RatesFile: "rates.txt"})
+	require.Error(t, err)
+
+	_, err = NewProvider(util.Config{RatesProvider: "unknown"})
+	require.Error(t, err)
+}
@@ -0,0 +1,272 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+)
+
+// dueBatchSize is how many due schedules one pass of the executor picks up.
+const dueBatchSize = 100
+
+// errNoExchangeRate is recorded when a cross-currency schedule is due and no rate is known.
+var errNoExchangeRate = errors.New("no exchange rate")
+
+// Store is the part of db.Store the executor reads schedules from and moves money with.
+type Store interface {
+	ListDueScheduledTransfers(ctx context.Context, arg db.ListDueScheduledTransfersParams) ([]db.ScheduledTransfer, error)
+	AdvanceScheduledTransfer(ctx context.Context, arg db.AdvanceScheduledTransferParams) (db.ScheduledTransfer, error)
+	GetAccount(ctx context.Context, id int64) (db.Account, error)
+	GetExchangeRate(ctx context.Context, arg db.GetExchangeRateParams) (db.ExchangeRate, error)
+	GetBeneficiaryByAccount(ctx context.Context, arg db.GetBeneficiaryByAccountParams) (db.Beneficiary, error)
+	GetCurrency(ctx context.Context, code string) (db.Currency, error)
+	TransferTx(ctx context.Context, arg db.TransferTxParams) (db.TransferTxResult, error)
+	RecordScheduledTransferRunTx(ctx context.Context, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error)
+}
+
+// Executor runs the scheduled transfers that are due.
+// Each run is made through TransferTx under an idempotency key of the schedule and run, so a run
+// picked up twice, by two servers or again after a crash, moves the money once.
+type Executor struct {
+	store            Store
+	retryInterval    time.Duration
+	coolingOffPeriod time.Duration
+}
+
+// NewExecutor creates an Executor. Runs that lack funds under the retry policy are tried again after retryInterval.
+// Runs into an account the owner saved as a beneficiary less than coolingOffPeriod before are held to the
+// cooling-off limit of their currency.
+func NewExecutor(store Store, retryInterval time.Duration, coolingOffPeriod time.Duration) *Executor {
+	return &Executor{
+		store:            store,
+		retryInterval:    retryInterval,
+		coolingOffPeriod: coolingOffPeriod,
+	}
+}
+
+// RunDue runs the schedules due at now and returns how many of them it recorded an attempt for.
+// A schedule that hits an unexpected error is left due for the next pass, and the first such error is returned.
+func (executor *Executor) RunDue(ctx context.Context, now time.Time) (int, error) {
+	schedules, err := executor.store.ListDueScheduledTransfers(ctx, db.ListDueScheduledTransfersParams{
+		Now:       now,
+		PageLimit: dueBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	var firstErr error
+	for _, schedule := range schedules {
+		ok, err := executor.run(ctx, schedule, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("scheduled transfer [%d]: %w", schedule.ID, err)
+			}
+			continue
+		}
+		if ok {
+			recorded++
+		}
+	}
+
+	return recorded, firstErr
+}
+
+// Run runs due schedules every interval until the context is cancelled.
+func (executor *Executor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := executor.RunDue(ctx, time.Now())
+		if err != nil {
+			log.Printf("cannot run scheduled transfers: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run makes one attempt at the next run of the schedule and records it.
+// It reports false if there was nothing to record: the schedule ended early because it was changed,
+// or another executor recorded the run first.
+func (executor *Executor) run(ctx context.Context, schedule db.ScheduledTransfer, now time.Time) (bool, error) {
+	advance := db.AdvanceScheduledTransferParams{
+		ID:            schedule.ID,
+		FromNextRunAt: schedule.NextRunAt.Time,
+		Status:        db.ScheduledTransferStatusActive,
+	}
+
+	// end_at or max_runs may have been lowered since the run was scheduled
+	if !schedule.HasRun(schedule.Runs) {
+		advance.Runs = schedule.Runs
+		advance.Status = db.ScheduledTransferStatusCompleted
+		_, err := executor.store.AdvanceScheduledTransfer(ctx, advance)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		return false, err
+	}
+
+	runAt := schedule.RunAt(schedule.Runs)
+	execution := db.CreateScheduledTransferExecutionParams{
+		ScheduledTransferID: schedule.ID,
+		ScheduledFor:        runAt,
+		Attempt:             schedule.Attempts + 1,
+	}
+
+	result, err := executor.transfer(ctx, schedule, runAt, now)
+	switch {
+	case err == nil:
+		execution.Status = db.ExecutionStatusSucceeded
+		execution.TransferID = &result.Transfer.ID
+	case errors.Is(err, db.ErrInsufficientFunds) && schedule.InsufficientFundsPolicy == db.InsufficientFundsPolicyRetry &&
+		schedule.Attempts < schedule.MaxRetries:
+		execution.Status = db.ExecutionStatusRetrying
+	case errors.Is(err, db.ErrInsufficientFunds):
+		execution.Status = db.ExecutionStatusSkipped
+	case isRunError(err):
+		execution.Status = db.ExecutionStatusFailed
+	default:
+		return false, err
+	}
+	if err != nil {
+		execution.Error = err.Error()
+	}
+
+	if execution.Status == db.ExecutionStatusRetrying {
+		advance.Runs = schedule.Runs
+		advance.Attempts = execution.Attempt
+		advance.NextRunAt = sql.NullTime{Time: now.Add(executor.retryInterval), Valid: true}
+	} else {
+		advance.Runs = schedule.Runs + 1
+		if schedule.HasRun(advance.Runs) {
+			advance.NextRunAt = sql.NullTime{Time: schedule.RunAt(advance.Runs), Valid: true}
+		} else {
+			advance.Status = db.ScheduledTransferStatusCompleted
+		}
+	}
+
+	_, err = executor.store.RecordScheduledTransferRunTx(ctx, db.RecordScheduledTransferRunTxParams{
+		Execution: execution,
+		Advance:   advance,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// transfer moves the money of one run of the schedule. Between currencies, the amount is converted at the rate of now.
+func (executor *Executor) transfer(ctx context.Context, schedule db.ScheduledTransfer, runAt time.Time, now time.Time) (db.TransferTxResult, error) {
+	toAccount, err := executor.store.GetAccount(ctx, schedule.ToAccountID)
+	if err != nil {
+		return db.TransferTxResult{}, err
+	}
+
+	err = executor.checkCoolingOff(ctx, schedule, now)
+	if err != nil {
+		return db.TransferTxResult{}, err
+	}
+
+	key := schedule.RunKey(runAt)
+	hash := sha256.Sum256([]byte(key))
+
+	metadata, err := json.Marshal(map[string]string{"scheduled_transfer_id": strconv.FormatInt(schedule.ID, 10)})
+	if err != nil {
+		return db.TransferTxResult{}, err
+	}
+
+	arg := db.TransferTxParams{
+		FromAccountID: schedule.FromAccountID,
+		ToAccountID:   schedule.ToAccountID,
+		Amount:        util.NewMoney(schedule.Amount, schedule.Currency),
+		Description:   schedule.Description,
+		Metadata:      metadata,
+		IdempotencyKey: &db.CreateIdempotencyKeyParams{
+			Username:    schedule.Owner,
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+		},
+	}
+
+	if toAccount.Currency != schedule.Currency {
+		rate, err := executor.store.GetExchangeRate(ctx, db.GetExchangeRateParams{
+			BaseCurrency:  schedule.Currency,
+			QuoteCurrency: toAccount.Currency,
+			At:            now,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return db.TransferTxResult{}, fmt.Errorf("%w from %s to %s", errNoExchangeRate, schedule.Currency, toAccount.Currency)
+			}
+			return db.TransferTxResult{}, err
+		}
+		arg.ExchangeRate = rate.Rate
+	}
+
+	return executor.store.TransferTx(ctx, arg)
+}
+
+// checkCoolingOff returns db.ErrCoolingOffLimitExceeded if the to account of the schedule was saved by its owner
+// as a beneficiary, deleted or not, less than the cooling-off period before now and the amount is above the limit.
+func (executor *Executor) checkCoolingOff(ctx context.Context, schedule db.ScheduledTransfer, now time.Time) error {
+	beneficiary, err := executor.store.GetBeneficiaryByAccount(ctx, db.GetBeneficiaryByAccountParams{
+		Owner:     schedule.Owner,
+		AccountID: schedule.ToAccountID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	if !now.Before(beneficiary.CoolingOffUntil(executor.coolingOffPeriod)) {
+		return nil
+	}
+
+	currency, err := executor.store.GetCurrency(ctx, schedule.Currency)
+	if err != nil {
+		return err
+	}
+	return beneficiary.CheckCoolingOff(executor.coolingOffPeriod, currency, schedule.Amount, now)
+}
+
+// isRunError reports whether the run itself can't be made, as opposed to the database being unavailable.
+// Such runs are recorded as failed and the schedule moves on to its next run.
+func isRunError(err error) bool {
+	for _, target := range []error{
+		errNoExchangeRate,
+		sql.ErrNoRows,
+		db.ErrAccountFrozen,
+		db.ErrAccountClosed,
+		db.ErrCurrencyMismatch,
+		db.ErrInvalidExchangeRate,
+		db.ErrAmountTooSmall,
+		db.ErrCoolingOffLimitExceeded,
+		db.ErrNonPositiveAmount,
+		db.ErrIdempotencyKeyReused,
+		util.ErrAmountOverflow,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,368 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	mockdb "github.com/badermezzi/KubeGoBank/db/mock"
+	db "github.com/badermezzi/KubeGoBank/db/sqlc"
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func dueSchedule(startAt time.Time) db.ScheduledTransfer {
+	return db.ScheduledTransfer{
+		ID:                      util.RandomInt(1, 1000),
+		Owner:                   util.RandomOwner(),
+		FromAccountID:           1,
+		ToAccountID:             2,
+		Amount:                  100,
+		Currency:                util.USD,
+		Description:             "rent",
+		Frequency:               db.ScheduleFrequencyMonthly,
+		StartAt:                 startAt,
+		InsufficientFundsPolicy: db.InsufficientFundsPolicySkip,
+		Status:                  db.ScheduledTransferStatusActive,
+		NextRunAt:               sql.NullTime{Time: startAt, Valid: true},
+	}
+}
+
+func TestExecutorRunDue(t *testing.T) {
+	startAt := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+	now := startAt.Add(time.Minute)
+	retryInterval := time.Hour
+
+	testCases := []struct {
+		name          string
+		schedule      func() db.ScheduledTransfer
+		buildStubs    func(store *mockdb.MockStore, schedule db.ScheduledTransfer)
+		checkRecorded func(t *testing.T, recorded int, err error)
+	}{
+		{
+			name:     "Succeeded",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(schedule.ToAccountID)).Times(1).
+					Return(db.Account{ID: schedule.ToAccountID, Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.TransferTxParams) (db.TransferTxResult, error) {
+						require.Equal(t, util.NewMoney(schedule.Amount, schedule.Currency), arg.Amount)
+						require.Equal(t, schedule.Description, arg.Description)
+						require.JSONEq(t, fmt.Sprintf(`{"scheduled_transfer_id":"%d"}`, schedule.ID), string(arg.Metadata))
+						require.Equal(t, schedule.Owner, arg.IdempotencyKey.Username)
+						require.Contains(t, arg.IdempotencyKey.Key, "2024-01-31T09:00:00Z")
+						return db.TransferTxResult{Transfer: db.Transfer{ID: 42}}, nil
+					})
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ExecutionStatusSucceeded, arg.Execution.Status)
+						require.Equal(t, int64(42), *arg.Execution.TransferID)
+						require.Equal(t, int32(1), arg.Execution.Attempt)
+						require.Equal(t, int32(1), arg.Advance.Runs)
+						require.Zero(t, arg.Advance.Attempts)
+						require.Equal(t, db.ScheduledTransferStatusActive, arg.Advance.Status)
+						require.Equal(t, startAt, arg.Advance.FromNextRunAt)
+						// February has no 31st
+						require.Equal(t, time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC), arg.Advance.NextRunAt.Time)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name: "LastRunCompletes",
+			schedule: func() db.ScheduledTransfer {
+				schedule := dueSchedule(startAt)
+				schedule.Frequency = db.ScheduleFrequencyOnce
+				return schedule
+			},
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ScheduledTransferStatusCompleted, arg.Advance.Status)
+						require.False(t, arg.Advance.NextRunAt.Valid)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name: "Retrying",
+			schedule: func() db.ScheduledTransfer {
+				schedule := dueSchedule(startAt)
+				schedule.InsufficientFundsPolicy = db.InsufficientFundsPolicyRetry
+				schedule.MaxRetries = 2
+				schedule.Attempts = 1
+				return schedule
+			},
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ExecutionStatusRetrying, arg.Execution.Status)
+						require.Equal(t, int32(2), arg.Execution.Attempt)
+						require.Nil(t, arg.Execution.TransferID)
+						require.Equal(t, db.ErrInsufficientFunds.Error(), arg.Execution.Error)
+						require.Zero(t, arg.Advance.Runs)
+						require.Equal(t, int32(2), arg.Advance.Attempts)
+						require.Equal(t, now.Add(retryInterval), arg.Advance.NextRunAt.Time)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name: "RetriesExhausted",
+			schedule: func() db.ScheduledTransfer {
+				schedule := dueSchedule(startAt)
+				schedule.InsufficientFundsPolicy = db.InsufficientFundsPolicyRetry
+				schedule.MaxRetries = 2
+				schedule.Attempts = 2
+				return schedule
+			},
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ExecutionStatusSkipped, arg.Execution.Status)
+						require.Equal(t, int32(3), arg.Execution.Attempt)
+						require.Equal(t, int32(1), arg.Advance.Runs)
+						require.Zero(t, arg.Advance.Attempts)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name:     "Failed",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrAccountFrozen)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ExecutionStatusFailed, arg.Execution.Status)
+						require.Equal(t, int32(1), arg.Advance.Runs)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name:     "IdempotencyKeyTakenByClient",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrIdempotencyKeyReused)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						// The run is given up rather than left due for ever
+						require.Equal(t, db.ExecutionStatusFailed, arg.Execution.Status)
+						require.Equal(t, int32(1), arg.Advance.Runs)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name:     "NoExchangeRate",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.EUR}, nil)
+				store.EXPECT().GetExchangeRate(gomock.Any(), gomock.Eq(db.GetExchangeRateParams{
+					BaseCurrency:  util.USD,
+					QuoteCurrency: util.EUR,
+					At:            now,
+				})).Times(1).Return(db.ExchangeRate{}, sql.ErrNoRows)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ExecutionStatusFailed, arg.Execution.Status)
+						require.Contains(t, arg.Execution.Error, errNoExchangeRate.Error())
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name: "EndedByUpdate",
+			schedule: func() db.ScheduledTransfer {
+				schedule := dueSchedule(startAt)
+				schedule.Runs = 3
+				schedule.MaxRuns = sql.NullInt32{Int32: 3, Valid: true}
+				return schedule
+			},
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AdvanceScheduledTransfer(gomock.Any(), gomock.Eq(db.AdvanceScheduledTransferParams{
+					Runs:          3,
+					Status:        db.ScheduledTransferStatusCompleted,
+					ID:            schedule.ID,
+					FromNextRunAt: startAt,
+				})).Times(1).Return(db.ScheduledTransfer{}, nil)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Zero(t, recorded)
+			},
+		},
+		{
+			name:     "CoolingOffLimitExceeded",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				// saved an hour ago and deleted since
+				store.EXPECT().GetBeneficiaryByAccount(gomock.Any(), gomock.Eq(db.GetBeneficiaryByAccountParams{
+					Owner:     schedule.Owner,
+					AccountID: schedule.ToAccountID,
+				})).Times(1).Return(db.Beneficiary{
+					ID:        7,
+					CreatedAt: now.Add(-time.Hour),
+					DeletedAt: sql.NullTime{Time: now, Valid: true},
+				}, nil)
+				store.EXPECT().GetCurrency(gomock.Any(), gomock.Eq(util.USD)).Times(1).
+					Return(db.Currency{Code: util.USD, BeneficiaryCoolingOffLimit: schedule.Amount - 1}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ExecutionStatusFailed, arg.Execution.Status)
+						require.Contains(t, arg.Execution.Error, db.ErrCoolingOffLimitExceeded.Error())
+						require.Equal(t, int32(1), arg.Advance.Runs)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name:     "CoolingOffOver",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().GetBeneficiaryByAccount(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.Beneficiary{CreatedAt: now.Add(-48 * time.Hour)}, nil)
+				store.EXPECT().GetCurrency(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ any, arg db.RecordScheduledTransferRunTxParams) (db.RecordScheduledTransferRunTxResult, error) {
+						require.Equal(t, db.ExecutionStatusSucceeded, arg.Execution.Status)
+						return db.RecordScheduledTransferRunTxResult{}, nil
+					})
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, recorded)
+			},
+		},
+		{
+			name:     "RecordedByAnotherExecutor",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, nil)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.RecordScheduledTransferRunTxResult{}, sql.ErrNoRows)
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.NoError(t, err)
+				require.Zero(t, recorded)
+			},
+		},
+		{
+			name:     "StoreError",
+			schedule: func() db.ScheduledTransfer { return dueSchedule(startAt) },
+			buildStubs: func(store *mockdb.MockStore, schedule db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{Currency: util.USD}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, sql.ErrConnDone)
+				store.EXPECT().RecordScheduledTransferRunTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkRecorded: func(t *testing.T, recorded int, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+				require.Zero(t, recorded)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			schedule := tc.schedule()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				ListDueScheduledTransfers(gomock.Any(), gomock.Eq(db.ListDueScheduledTransfersParams{Now: now, PageLimit: dueBatchSize})).
+				Times(1).
+				Return([]db.ScheduledTransfer{schedule}, nil)
+			tc.buildStubs(store, schedule)
+			// the to account isn't a beneficiary of the owner unless the case says otherwise
+			store.EXPECT().GetBeneficiaryByAccount(gomock.Any(), gomock.Any()).AnyTimes().Return(db.Beneficiary{}, sql.ErrNoRows)
+
+			recorded, err := NewExecutor(store, retryInterval, 24*time.Hour).RunDue(context.Background(), now)
+			tc.checkRecorded(t, recorded, err)
+		})
+	}
+}
+
+func TestExecutorRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListDueScheduledTransfers(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, _ db.ListDueScheduledTransfersParams) ([]db.ScheduledTransfer, error) {
+			cancel()
+			return nil, nil
+		})
+
+	done := make(chan struct{})
+	go func() {
+		NewExecutor(store, time.Hour, 24*time.Hour).Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("executor did not stop")
+	}
+}
@@ -0,0 +1,170 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Supported JWT signing algorithms.
+const (
+	JWTAlgorithmHS256 = "HS256" // HS256 signs tokens with HMAC-SHA256 and a shared secret.
+	JWTAlgorithmRS256 = "RS256" // RS256 signs tokens with RSASSA-PKCS1-v1_5 and SHA-256.
+	JWTAlgorithmES256 = "ES256" // ES256 signs tokens with ECDSA on the P-256 curve and SHA-256.
+)
+
+// minSecretKeySize is the minimum length of the HS256 secret key.
+const minSecretKeySize = 32
+
+// JWTMaker is a JSON Web Token maker.
+// Each maker is pinned to exactly one signing algorithm.
+type JWTMaker struct {
+	method     jwt.SigningMethod // method is the only signing algorithm this maker creates and accepts.
+	signingKey any               // signingKey is the key used to sign new tokens.
+	verifyKey  any               // verifyKey is the key used to check token signatures.
+}
+
+// jwtClaims maps a Payload onto the registered JWT claims so other services can read the token.
+type jwtClaims struct {
+	Username string    `json:"username"`   // Username is the username of the token owner.
+	Role     string    `json:"role"`       // Role is the role of the token owner.
+	Type     TokenType `json:"token_type"` // Type is what the token may be used for.
+	jwt.RegisteredClaims
+}
+
+// NewJWTMaker creates a new JWTMaker that signs tokens with HS256.
+// It takes a secretKey string as input and returns a Maker interface and an error.
+func NewJWTMaker(secretKey string) (Maker, error) {
+	// Check if the secret key is long enough to be used with HMAC-SHA256.
+	if len(secretKey) < minSecretKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	}
+
+	maker := &JWTMaker{
+		method:     jwt.SigningMethodHS256,
+		signingKey: []byte(secretKey),
+		verifyKey:  []byte(secretKey),
+	}
+	return maker, nil
+}
+
+// NewAsymmetricJWTMaker creates a new JWTMaker that signs tokens with RS256 or ES256.
+// It takes the algorithm name and a PEM encoded private key and returns a Maker interface and an error.
+func NewAsymmetricJWTMaker(algorithm string, privateKeyPEM []byte) (Maker, error) {
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse RSA private key: %w", err)
+		}
+
+		maker := &JWTMaker{
+			method:     jwt.SigningMethodRS256,
+			signingKey: privateKey,
+			verifyKey:  &privateKey.PublicKey,
+		}
+		return maker, nil
+	case JWTAlgorithmES256:
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(privateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse ECDSA private key: %w", err)
+		}
+		if privateKey.Curve.Params().Name != "P-256" {
+			return nil, fmt.Errorf("invalid ECDSA curve %s: ES256 requires P-256", privateKey.Curve.Params().Name)
+		}
+
+		maker := &JWTMaker{
+			method:     jwt.SigningMethodES256,
+			signingKey: privateKey,
+			verifyKey:  &privateKey.PublicKey,
+		}
+		return maker, nil
+	}
+
+	return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+}
+
+// NewAsymmetricJWTMakerFromFile reads a PEM encoded private key from disk and creates a JWTMaker with it.
+func NewAsymmetricJWTMakerFromFile(algorithm string, privateKeyFile string) (Maker, error) {
+	privateKeyPEM, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read private key file: %w", err)
+	}
+	return NewAsymmetricJWTMaker(algorithm, privateKeyPEM)
+}
+
+// CreateToken creates a new signed JWT of the given type for the given username, role and duration.
+// It takes a username, role, token type and duration as input and returns a token string, its payload and an error.
+func (maker *JWTMaker) CreateToken(username string, role string, tokenType TokenType, duration time.Duration) (string, *Payload, error) {
+	// Create a new payload for the token.
+	payload, err := NewPayload(username, role, tokenType, duration)
+	if err != nil {
+		return "", payload, err // Return error if payload creation fails.
+	}
+
+	claims := jwtClaims{
+		Username: payload.Username,
+		Role:     payload.Role,
+		Type:     payload.Type,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        payload.ID.String(),
+			Subject:   payload.Username,
+			IssuedAt:  jwt.NewNumericDate(payload.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(payload.ExpiredAt),
+		},
+	}
+
+	// Sign the claims with the maker's pinned algorithm and key.
+	token, err := jwt.NewWithClaims(maker.method, claims).SignedString(maker.signingKey)
+	return token, payload, err
+}
+
+// VerifyToken verifies if the token is valid or not.
+// It takes a token string as input and returns a Payload and an error.
+func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
+	keyFunc := func(jwtToken *jwt.Token) (any, error) {
+		// Reject any token whose header names a different algorithm than the one this maker is pinned to.
+		// This blocks "none" and algorithm confusion such as an HS256 token signed with an RSA public key.
+		if jwtToken.Method.Alg() != maker.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return maker.verifyKey, nil
+	}
+
+	claims := &jwtClaims{}
+	_, err := jwt.ParseWithClaims(
+		token,
+		claims,
+		keyFunc,
+		jwt.WithValidMethods([]string{maker.method.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken // Return error if the token has expired.
+		}
+		return nil, ErrInvalidToken // Return error if the token is malformed or its signature is invalid.
+	}
+
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, ErrInvalidToken // Return error if the token ID is not a valid UUID.
+	}
+	if claims.IssuedAt == nil {
+		return nil, ErrInvalidToken // Return error if the token has no issued at time.
+	}
+
+	payload := &Payload{
+		ID:        tokenID,
+		Username:  claims.Username,
+		Role:      claims.Role,
+		Type:      claims.Type,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiredAt: claims.ExpiresAt.Time,
+	}
+	return payload, nil
+}
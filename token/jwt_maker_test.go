@@ -0,0 +1,189 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// randomRSAKeyPEM generates a new RSA private key and returns it with its PEM encoding.
+func randomRSAKeyPEM(t *testing.T) (*rsa.PrivateKey, []byte) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+	return privateKey, privateKeyPEM
+}
+
+// randomECKeyPEM generates a new P-256 private key and returns its PEM encoding.
+func randomECKeyPEM(t *testing.T) []byte {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+// requireValidToken creates a token with the maker and checks that it verifies to the expected payload.
+func requireValidToken(t *testing.T, maker Maker) {
+	username := util.RandomOwner() // Generate a random username for the token payload.
+	duration := time.Minute        // Set the token duration to 1 minute.
+
+	issuedAt := time.Now()              // Record the time before token creation.
+	expiredAt := issuedAt.Add(duration) // Calculate the expected expiration time.
+
+	token, payload, err := maker.CreateToken(username, util.BankerRole, TokenTypeRefreshToken, duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	require.NotZero(t, payload.ID)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, util.BankerRole, payload.Role)
+	require.Equal(t, TokenTypeRefreshToken, payload.Type)
+	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
+	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
+}
+
+// TestJWTMaker tests the HS256 JWTMaker's token creation and verification functionalities.
+func TestJWTMaker(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	requireValidToken(t, maker)
+}
+
+// TestRS256JWTMaker tests token creation and verification with an RSA key.
+func TestRS256JWTMaker(t *testing.T) {
+	_, privateKeyPEM := randomRSAKeyPEM(t)
+
+	maker, err := NewAsymmetricJWTMaker(JWTAlgorithmRS256, privateKeyPEM)
+	require.NoError(t, err)
+
+	requireValidToken(t, maker)
+}
+
+// TestES256JWTMaker tests token creation and verification with an ECDSA key.
+func TestES256JWTMaker(t *testing.T) {
+	maker, err := NewAsymmetricJWTMaker(JWTAlgorithmES256, randomECKeyPEM(t))
+	require.NoError(t, err)
+
+	requireValidToken(t, maker)
+}
+
+// TestInvalidJWTMakerConfig tests that bad keys and algorithms are rejected at construction time.
+func TestInvalidJWTMakerConfig(t *testing.T) {
+	_, err := NewJWTMaker(util.RandomString(minSecretKeySize - 1))
+	require.Error(t, err)
+
+	_, err = NewAsymmetricJWTMaker(JWTAlgorithmHS256, []byte(util.RandomString(32)))
+	require.Error(t, err)
+
+	_, err = NewAsymmetricJWTMaker(JWTAlgorithmRS256, randomECKeyPEM(t))
+	require.Error(t, err)
+}
+
+// TestExpiredJWTToken tests the JWTMaker's token verification with an expired token.
+func TestExpiredJWTToken(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	token, payload, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, -time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)
+	require.ErrorIs(t, err, ErrExpiredToken)
+	require.Nil(t, payload)
+}
+
+// TestInvalidJWTTokenAlgNone tests that an unsigned token using the "none" algorithm is rejected.
+func TestInvalidJWTTokenAlgNone(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	claims := jwtClaims{
+		Username: payload.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        payload.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(payload.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(payload.ExpiredAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Nil(t, payload)
+}
+
+// TestInvalidJWTTokenAlgConfusion tests that an HS256 token signed with the RSA public key is rejected by an RS256 maker.
+func TestInvalidJWTTokenAlgConfusion(t *testing.T) {
+	privateKey, privateKeyPEM := randomRSAKeyPEM(t)
+
+	maker, err := NewAsymmetricJWTMaker(JWTAlgorithmRS256, privateKeyPEM)
+	require.NoError(t, err)
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	claims := jwtClaims{
+		Username: payload.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        payload.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(payload.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(payload.ExpiredAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(publicKeyPEM)
+	require.NoError(t, err)
+
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Nil(t, payload)
+}
+
+// TestInvalidJWTTokenWrongKey tests that a token signed with a different secret is rejected.
+func TestInvalidJWTTokenWrongKey(t *testing.T) {
+	maker1, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+	maker2, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	token, _, err := maker1.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker2.VerifyToken(token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Nil(t, payload)
+}
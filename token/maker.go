@@ -7,8 +7,9 @@ import (
 // Maker is an interface for managing tokens.
 // It defines the methods that a token maker should implement.
 type Maker interface {
-	// CreateToken creates a new token for the given username and duration.
-	CreateToken(username string, duration time.Duration) (string, error)
+	// CreateToken creates a new token of the given type for the given username, role and duration.
+	// It also returns the payload so callers can record the token ID and expiry.
+	CreateToken(username string, role string, tokenType TokenType, duration time.Duration) (string, *Payload, error)
 
 	// VerifyToken checks if the token is valid or not.
 	VerifyToken(token string) (*Payload, error)
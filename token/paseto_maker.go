@@ -8,48 +8,85 @@ import (
 )
 
 // PasetoMaker is a PASETO token maker.
+// It encrypts with the current symmetric key and decrypts with any active key, picked by the
+// key ID in the token footer, so secrets can be rotated without invalidating outstanding tokens.
 type PasetoMaker struct {
-	paseto       *paseto.V2 // paseto is the PASETO v2 instance used to create and verify tokens.
-	symmetricKey []byte    // symmetricKey is the secret key used to sign and verify PASETO tokens.
+	paseto       *paseto.V2        // paseto is the PASETO v2 instance used to create and verify tokens.
+	symmetricKey []byte            // symmetricKey is the current secret key used to encrypt PASETO tokens.
+	keyID        string            // keyID identifies the current symmetric key in token footers.
+	keys         map[string][]byte // keys maps key IDs to every active key, current and previous.
+	keyOrder     []string          // keyOrder lists the active key IDs, current key first.
 }
 
 // NewPasetoMaker creates a new PasetoMaker.
-// It takes a symmetricKey string as input and returns a Maker interface and an error.
-func NewPasetoMaker(symmetricKey string) (Maker, error) {
-	// Check if the symmetric key length is valid (must be 32 bytes for PASETO v2).
-	if len(symmetricKey) != 32 {
-		return nil, fmt.Errorf("invalid key size: must be exactly 32 characters")
-	}
-
+// It takes the current symmetricKey and any previous keys that should still be accepted,
+// and returns a Maker interface and an error.
+func NewPasetoMaker(symmetricKey string, previousKeys ...string) (Maker, error) {
 	// Create a new PasetoMaker instance.
 	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(), // Initialize the PASETO v2 instance.
+		paseto:       paseto.NewV2(),       // Initialize the PASETO v2 instance.
 		symmetricKey: []byte(symmetricKey), // Convert the symmetricKey string to a byte slice.
+		keyID:        paserkLID([]byte(symmetricKey)),
+		keys:         make(map[string][]byte),
+	}
+
+	for _, key := range append([]string{symmetricKey}, previousKeys...) {
+		// Check if the symmetric key length is valid (must be 32 bytes for PASETO v2).
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid key size: must be exactly 32 characters")
+		}
+
+		keyID := paserkLID([]byte(key))
+		if _, ok := maker.keys[keyID]; ok {
+			continue // Skip duplicates, e.g. the current key listed again as a previous key.
+		}
+		maker.keys[keyID] = []byte(key)
+		maker.keyOrder = append(maker.keyOrder, keyID)
 	}
 	return maker, nil
 }
 
-// CreateToken creates a new PASETO token for the given username and duration.
-// It takes a username and duration as input and returns a token string and an error.
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, error) {
+// CreateToken creates a new PASETO token of the given type for the given username, role and duration.
+// It takes a username, role, token type and duration as input and returns a token string, its payload and an error.
+func (maker *PasetoMaker) CreateToken(username string, role string, tokenType TokenType, duration time.Duration) (string, *Payload, error) {
 	// Create a new payload for the token.
-	payload, err := NewPayload(username, duration)
+	payload, err := NewPayload(username, role, tokenType, duration)
 	if err != nil {
-		return "", err // Return error if payload creation fails.
+		return "", payload, err // Return error if payload creation fails.
 	}
 
-	// Encrypt the payload using the symmetric key and return the token string.
-	return maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+	// Encrypt the payload using the current symmetric key and name the key in the footer.
+	footer := pasetoFooter{KeyID: maker.keyID}
+	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, footer)
+	return token, payload, err
 }
 
 // VerifyToken verifies if the token is valid or not.
 // It takes a token string as input and returns a Payload and an error.
 func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
+	// Read the key ID from the footer. Tokens issued before key rotation have no footer.
+	var footer pasetoFooter
+	_ = paseto.ParseFooter(token, &footer) // A bad footer is caught by Decrypt below.
+
+	keyIDs := maker.keyOrder // Try every active key when the token does not name one.
+	if footer.KeyID != "" {
+		if _, ok := maker.keys[footer.KeyID]; !ok {
+			return nil, fmt.Errorf("invalid token: %w", ErrInvalidToken) // Return error if the key is not active.
+		}
+		keyIDs = []string{footer.KeyID}
+	}
+
 	// Create an empty payload to store the decrypted data.
 	payload := &Payload{}
 
-	// Decrypt the token using the symmetric key and unmarshal the payload into the payload struct.
-	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
+	var err error
+	for _, keyID := range keyIDs {
+		// Decrypt the token using the symmetric key and unmarshal the payload into the payload struct.
+		err = maker.paseto.Decrypt(token, maker.keys[keyID], payload, nil)
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err) // Return error if token decryption fails.
 	}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/o1egl/paseto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,18 +24,21 @@ func TestPasetoMaker(t *testing.T) {
 	expiredAt := issuedAt.Add(duration) // Calculate the expected expiration time.
 
 	// Create a new token for the given username and duration.
-	token, err := maker.CreateToken(username, duration)
-	require.NoError(t, err)    // Assert that no error occurred during token creation.
-	require.NotEmpty(t, token) // Assert that the created token is not empty.
+	token, payload, err := maker.CreateToken(username, util.DepositorRole, TokenTypeAccessToken, duration)
+	require.NoError(t, err)      // Assert that no error occurred during token creation.
+	require.NotEmpty(t, token)   // Assert that the created token is not empty.
+	require.NotEmpty(t, payload) // Assert that the returned payload is not empty.
 
 	// Verify the created token.
-	payload, err := maker.VerifyToken(token)
+	payload, err = maker.VerifyToken(token)
 	require.NoError(t, err)      // Assert that no error occurred during token verification.
 	require.NotEmpty(t, payload) // Assert that the verified payload is not empty.
 
 	// Assert the payload data is correct.
 	require.NotZero(t, payload.ID)                                       // Assert that the payload ID is not zero.
 	require.Equal(t, username, payload.Username)                         // Assert that the username in the payload matches the generated username.
+	require.Equal(t, util.DepositorRole, payload.Role)                   // Assert that the role in the payload matches the given role.
+	require.Equal(t, TokenTypeAccessToken, payload.Type)                 // Assert that the token type in the payload matches the given type.
 	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)   // Assert that the issuedAt time in the payload is within 1 second of the recorded issuedAt time.
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second) // Assert that the expiredAt time in the payload is within 1 second of the calculated expiredAt time.
 }
@@ -48,13 +52,80 @@ func TestExpiredPasetoToken(t *testing.T) {
 	require.NoError(t, err) // Assert that no error occurred during PasetoMaker creation.
 
 	// Create a new token with a negative duration, making it immediately expired.
-	token, err := maker.CreateToken(util.RandomOwner(), -time.Minute)
-	require.NoError(t, err)    // Assert that no error occurred during token creation.
-	require.NotEmpty(t, token) // Assert that the created token is not empty.
+	token, payload, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, -time.Minute)
+	require.NoError(t, err)      // Assert that no error occurred during token creation.
+	require.NotEmpty(t, token)   // Assert that the created token is not empty.
+	require.NotEmpty(t, payload) // Assert that the returned payload is not empty.
 
 	// Verify the expired token.
-	payload, err := maker.VerifyToken(token)
-	require.Error(t, err)   // Assert that an error occurred during token verification (due to expiration).
-	require.Nil(t, payload) // Assert that the payload is nil because the token is expired.
-	// require.ErrorIs(t, err, ErrExpiredToken) // ErrExpiredToken is not defined yet // Commented out: ErrExpiredToken is not defined yet.
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)                    // Assert that an error occurred during token verification (due to expiration).
+	require.Nil(t, payload)                  // Assert that the payload is nil because the token is expired.
+	require.ErrorIs(t, err, ErrExpiredToken) // Assert that the error is ErrExpiredToken.
+}
+
+// TestPasetoMakerKeyRotation tests that tokens encrypted with a previous key are still accepted after rotation.
+func TestPasetoMakerKeyRotation(t *testing.T) {
+	oldKey := util.RandomString(32)
+	newKey := util.RandomString(32)
+
+	oldMaker, err := NewPasetoMaker(oldKey)
+	require.NoError(t, err)
+	rotatedMaker, err := NewPasetoMaker(newKey, oldKey)
+	require.NoError(t, err)
+	newOnlyMaker, err := NewPasetoMaker(newKey)
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+
+	// A token from before the rotation verifies as long as the old key is still listed.
+	oldToken, _, err := oldMaker.CreateToken(username, util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	payload, err := rotatedMaker.VerifyToken(oldToken)
+	require.NoError(t, err)
+	require.Equal(t, username, payload.Username)
+
+	payload, err = newOnlyMaker.VerifyToken(oldToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Nil(t, payload)
+
+	// New tokens are encrypted with the current key, so the old maker cannot read them.
+	newToken, _, err := rotatedMaker.CreateToken(username, util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	payload, err = newOnlyMaker.VerifyToken(newToken)
+	require.NoError(t, err)
+	require.Equal(t, username, payload.Username)
+
+	payload, err = oldMaker.VerifyToken(newToken)
+	require.Error(t, err)
+	require.Nil(t, payload)
+}
+
+// TestPasetoMakerLegacyToken tests that tokens issued without a key ID footer are tried against every active key.
+func TestPasetoMakerLegacyToken(t *testing.T) {
+	oldKey := util.RandomString(32)
+
+	legacyPayload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+	legacyToken, err := paseto.NewV2().Encrypt([]byte(oldKey), legacyPayload, nil)
+	require.NoError(t, err)
+
+	maker, err := NewPasetoMaker(util.RandomString(32), oldKey)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(legacyToken)
+	require.NoError(t, err)
+	require.Equal(t, legacyPayload.ID, payload.ID)
+	require.Equal(t, legacyPayload.Username, payload.Username)
+}
+
+// TestInvalidPasetoMakerKeys tests that every active key must have the right size.
+func TestInvalidPasetoMakerKeys(t *testing.T) {
+	_, err := NewPasetoMaker(util.RandomString(31))
+	require.Error(t, err)
+
+	_, err = NewPasetoMaker(util.RandomString(32), util.RandomString(16))
+	require.Error(t, err)
 }
@@ -0,0 +1,117 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// PASETO v4.public and PASERK helpers.
+// See https://github.com/paseto-standard/paseto-spec/blob/master/docs/01-Protocol-Versions/Version4.md
+const (
+	pasetoV4PublicHeader = "v4.public."
+	paserkV4PublicHeader = "k4.public."
+	paserkV4PIDHeader    = "k4.pid."
+	paserkV2LocalHeader  = "k2.local."
+	paserkV2LIDHeader    = "k2.lid."
+	paserkIDSize         = 33 // paserkIDSize is the BLAKE2b-264 digest size used for key IDs.
+)
+
+var errInvalidPasetoV4 = errors.New("invalid v4.public token")
+
+// pasetoEncoding is the unpadded URL-safe base64 encoding used everywhere in PASETO.
+var pasetoEncoding = base64.RawURLEncoding
+
+// pae implements the PASETO Pre-Authentication Encoding of the given pieces.
+func pae(pieces ...[]byte) []byte {
+	var buffer bytes.Buffer
+
+	writeLength := func(n int) {
+		var le64 [8]byte
+		binary.LittleEndian.PutUint64(le64[:], uint64(n)&^(1<<63)) // The most significant bit must be cleared.
+		buffer.Write(le64[:])
+	}
+
+	writeLength(len(pieces))
+	for _, piece := range pieces {
+		writeLength(len(piece))
+		buffer.Write(piece)
+	}
+	return buffer.Bytes()
+}
+
+// signV4Public signs the message and footer with an Ed25519 key and returns a v4.public token.
+func signV4Public(privateKey ed25519.PrivateKey, message []byte, footer []byte) string {
+	signature := ed25519.Sign(privateKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil))
+
+	token := pasetoV4PublicHeader + pasetoEncoding.EncodeToString(append(append([]byte{}, message...), signature...))
+	if len(footer) > 0 {
+		token += "." + pasetoEncoding.EncodeToString(footer)
+	}
+	return token
+}
+
+// splitV4Public splits a v4.public token into its signed body and footer without verifying it.
+func splitV4Public(token string) (body []byte, footer []byte, err error) {
+	if !strings.HasPrefix(token, pasetoV4PublicHeader) {
+		return nil, nil, errInvalidPasetoV4
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, pasetoV4PublicHeader), ".")
+	if len(parts) > 2 {
+		return nil, nil, errInvalidPasetoV4
+	}
+
+	body, err = pasetoEncoding.DecodeString(parts[0])
+	if err != nil || len(body) < ed25519.SignatureSize {
+		return nil, nil, errInvalidPasetoV4
+	}
+
+	if len(parts) == 2 {
+		footer, err = pasetoEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, nil, errInvalidPasetoV4
+		}
+	}
+	return body, footer, nil
+}
+
+// verifyV4Public checks the signature of the signed body and footer and returns the message.
+func verifyV4Public(publicKey ed25519.PublicKey, body []byte, footer []byte) ([]byte, error) {
+	message := body[:len(body)-ed25519.SignatureSize]
+	signature := body[len(body)-ed25519.SignatureSize:]
+
+	if !ed25519.Verify(publicKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil), signature) {
+		return nil, errInvalidPasetoV4
+	}
+	return message, nil
+}
+
+// paserkPublic serializes an Ed25519 public key as a k4.public PASERK.
+func paserkPublic(publicKey ed25519.PublicKey) string {
+	return paserkV4PublicHeader + pasetoEncoding.EncodeToString(publicKey)
+}
+
+// paserkPID returns the k4.pid key identifier of an Ed25519 public key.
+func paserkPID(publicKey ed25519.PublicKey) string {
+	return paserkID(paserkV4PIDHeader, paserkPublic(publicKey))
+}
+
+// paserkLID returns the k2.lid key identifier of a PASETO v2.local symmetric key.
+// The identifier is a one-way hash, so it can be put in a token footer without leaking the key.
+func paserkLID(symmetricKey []byte) string {
+	return paserkID(paserkV2LIDHeader, paserkV2LocalHeader+pasetoEncoding.EncodeToString(symmetricKey))
+}
+
+// paserkID hashes a serialized PASERK key into an identifier with the given header.
+func paserkID(header string, paserk string) string {
+	hash, _ := blake2b.New(paserkIDSize, nil) // Only fails for an invalid size or key.
+	hash.Write([]byte(header))
+	hash.Write([]byte(paserk))
+	return header + pasetoEncoding.EncodeToString(hash.Sum(nil))
+}
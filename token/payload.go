@@ -7,27 +7,46 @@ import (
 	"github.com/google/uuid"
 )
 
+// Different types of error returned by the VerifyToken function.
+var (
+	ErrInvalidToken = errors.New("token is invalid")  // ErrInvalidToken is returned when a token cannot be decoded or its signature does not match.
+	ErrExpiredToken = errors.New("token has expired") // ErrExpiredToken is returned when a token is past its expiration time.
+)
+
+// TokenType tells access tokens, sent with every request, from refresh tokens, which can only renew them.
+type TokenType string
+
+// Types of token created by a Maker.
+const (
+	TokenTypeAccessToken  TokenType = "access"  // TokenTypeAccessToken authenticates requests.
+	TokenTypeRefreshToken TokenType = "refresh" // TokenTypeRefreshToken is only accepted by the renew access token route.
+)
+
 // Payload contains the payload data of the token.
-// It includes the token ID, username, issued at time, and expired at time.
+// It includes the token ID, username, role, token type, issued at time, and expired at time.
 type Payload struct {
-	ID        uuid.UUID `json:"id"`        // ID is the unique identifier of the token.
-	Username  string    `json:"username"`  // Username is the username of the token owner.
-	IssuedAt  time.Time `json:"issued_at"` // IssuedAt is the time when the token was issued.
-	ExpiredAt time.Time `json:"expired_at"`// ExpiredAt is the time when the token will expire.
+	ID        uuid.UUID `json:"id"`         // ID is the unique identifier of the token.
+	Username  string    `json:"username"`   // Username is the username of the token owner.
+	Role      string    `json:"role"`       // Role is the role of the token owner, e.g. depositor or banker.
+	Type      TokenType `json:"token_type"` // Type is what the token may be used for.
+	IssuedAt  time.Time `json:"issued_at"`  // IssuedAt is the time when the token was issued.
+	ExpiredAt time.Time `json:"expired_at"` // ExpiredAt is the time when the token will expire.
 }
 
-// NewPayload creates a new token payload with the given username and duration.
+// NewPayload creates a new token payload with the given username, role, token type and duration.
 // It returns a Payload and an error.
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+func NewPayload(username string, role string, tokenType TokenType, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom() // Generate a new random UUID for the token ID.
 	if err != nil {
 		return nil, err // Return error if UUID generation fails.
 	}
 
 	payload := &Payload{
-		ID:        tokenID,               // Assign the generated token ID to the payload.
-		Username:  username,              // Assign the given username to the payload.
-		IssuedAt:  time.Now(),            // Set the issued at time to the current time.
+		ID:        tokenID,                  // Assign the generated token ID to the payload.
+		Username:  username,                 // Assign the given username to the payload.
+		Role:      role,                     // Assign the given role to the payload.
+		Type:      tokenType,                // Assign the given token type to the payload.
+		IssuedAt:  time.Now(),               // Set the issued at time to the current time.
 		ExpiredAt: time.Now().Add(duration), // Set the expired at time to the current time plus the given duration.
 	}
 	return payload, nil
@@ -37,7 +56,7 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 // It returns an error if the token is expired or invalid.
 func (payload *Payload) Valid() error {
 	if time.Now().After(payload.ExpiredAt) { // Check if the current time is after the token's expiration time.
-		return errors.New("token has expired") // Return an error if the token has expired.
+		return ErrExpiredToken // Return an error if the token has expired.
 	}
 	return nil // Return nil if the token is still valid.
 }
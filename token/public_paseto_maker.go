@@ -0,0 +1,221 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PublicKey is a token verification key that can be shared with other services.
+type PublicKey struct {
+	KeyID   string `json:"kid"`     // KeyID is the k4.pid identifier carried in the token footer.
+	Version string `json:"version"` // Version is the PASETO version and purpose the key is used with.
+	Key     string `json:"key"`     // Key is the k4.public PASERK serialization of the public key.
+}
+
+// PublicKeyProvider is implemented by makers whose tokens can be verified with public keys only.
+type PublicKeyProvider interface {
+	// PublicKeys returns every key that tokens from this maker may be verified with.
+	PublicKeys() []PublicKey
+}
+
+// PublicPasetoMaker is a PASETO v4.public token maker.
+// It signs with a single Ed25519 private key and verifies with any of its known public keys,
+// picked by the key ID in the token footer, so signing keys can be rotated.
+type PublicPasetoMaker struct {
+	privateKey       ed25519.PrivateKey           // privateKey is the current signing key.
+	keyID            string                       // keyID identifies the current signing key.
+	verificationKeys map[string]ed25519.PublicKey // verificationKeys maps key IDs to accepted public keys.
+	publicKeys       []PublicKey                  // publicKeys lists the accepted keys, current key first.
+}
+
+// pasetoFooter is the JSON footer that names the key a PASETO token was created with.
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// pasetoClaims maps a Payload onto the registered PASETO claims so other services can read the token.
+type pasetoClaims struct {
+	TokenID    string    `json:"jti"`
+	Subject    string    `json:"sub"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	TokenType  TokenType `json:"token_type"`
+	IssuedAt   time.Time `json:"iat"`
+	Expiration time.Time `json:"exp"`
+}
+
+// NewPublicPasetoMaker creates a new PublicPasetoMaker.
+// It takes the current Ed25519 private key and the public keys of previous signing keys that should still be accepted.
+func NewPublicPasetoMaker(privateKey ed25519.PrivateKey, previousKeys ...ed25519.PublicKey) (Maker, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid key size: must be exactly %d bytes", ed25519.PrivateKeySize)
+	}
+
+	currentKey := privateKey.Public().(ed25519.PublicKey)
+	maker := &PublicPasetoMaker{
+		privateKey:       privateKey,
+		keyID:            paserkPID(currentKey),
+		verificationKeys: make(map[string]ed25519.PublicKey),
+	}
+
+	for _, publicKey := range append([]ed25519.PublicKey{currentKey}, previousKeys...) {
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key size: must be exactly %d bytes", ed25519.PublicKeySize)
+		}
+
+		keyID := paserkPID(publicKey)
+		if _, ok := maker.verificationKeys[keyID]; ok {
+			continue // Skip duplicates, e.g. the current key listed again as a previous key.
+		}
+
+		maker.verificationKeys[keyID] = publicKey
+		maker.publicKeys = append(maker.publicKeys, PublicKey{
+			KeyID:   keyID,
+			Version: "v4.public",
+			Key:     paserkPublic(publicKey),
+		})
+	}
+	return maker, nil
+}
+
+// NewPublicPasetoMakerFromFiles reads a PEM encoded Ed25519 private key and previous public keys from disk
+// and creates a PublicPasetoMaker with them.
+func NewPublicPasetoMakerFromFiles(privateKeyFile string, previousKeyFiles ...string) (Maker, error) {
+	privateKeyPEM, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read private key file: %w", err)
+	}
+
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("cannot decode private key file %s", privateKeyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key file %s is not an Ed25519 key", privateKeyFile)
+	}
+
+	previousKeys := make([]ed25519.PublicKey, 0, len(previousKeyFiles))
+	for _, previousKeyFile := range previousKeyFiles {
+		publicKeyPEM, err := os.ReadFile(previousKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read public key file: %w", err)
+		}
+
+		block, _ := pem.Decode(publicKeyPEM)
+		if block == nil {
+			return nil, fmt.Errorf("cannot decode public key file %s", previousKeyFile)
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse public key: %w", err)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key file %s is not an Ed25519 key", previousKeyFile)
+		}
+		previousKeys = append(previousKeys, publicKey)
+	}
+
+	return NewPublicPasetoMaker(privateKey, previousKeys...)
+}
+
+// CreateToken creates a new signed PASETO v4.public token of the given type for the given username, role and duration.
+// It takes a username, role, token type and duration as input and returns a token string, its payload and an error.
+func (maker *PublicPasetoMaker) CreateToken(username string, role string, tokenType TokenType, duration time.Duration) (string, *Payload, error) {
+	// Create a new payload for the token.
+	payload, err := NewPayload(username, role, tokenType, duration)
+	if err != nil {
+		return "", payload, err // Return error if payload creation fails.
+	}
+
+	message, err := json.Marshal(pasetoClaims{
+		TokenID:    payload.ID.String(),
+		Subject:    payload.Username,
+		Username:   payload.Username,
+		Role:       payload.Role,
+		TokenType:  payload.Type,
+		IssuedAt:   payload.IssuedAt,
+		Expiration: payload.ExpiredAt,
+	})
+	if err != nil {
+		return "", payload, err
+	}
+
+	footer, err := json.Marshal(pasetoFooter{KeyID: maker.keyID})
+	if err != nil {
+		return "", payload, err
+	}
+
+	// Sign the claims and the key ID footer with the current private key.
+	return signV4Public(maker.privateKey, message, footer), payload, nil
+}
+
+// VerifyToken verifies if the token is valid or not.
+// It takes a token string as input and returns a Payload and an error.
+func (maker *PublicPasetoMaker) VerifyToken(token string) (*Payload, error) {
+	body, footerData, err := splitV4Public(token)
+	if err != nil {
+		return nil, ErrInvalidToken // Return error if the token is not a well formed v4.public token.
+	}
+
+	// Pick the verification key named in the footer.
+	var footer pasetoFooter
+	err = json.Unmarshal(footerData, &footer)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	publicKey, ok := maker.verificationKeys[footer.KeyID]
+	if !ok {
+		return nil, ErrInvalidToken // Return error if the token was signed with an unknown key.
+	}
+
+	message, err := verifyV4Public(publicKey, body, footerData)
+	if err != nil {
+		return nil, ErrInvalidToken // Return error if the signature does not match.
+	}
+
+	var claims pasetoClaims
+	err = json.Unmarshal(message, &claims)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	tokenID, err := uuid.Parse(claims.TokenID)
+	if err != nil {
+		return nil, ErrInvalidToken // Return error if the token ID is not a valid UUID.
+	}
+
+	payload := &Payload{
+		ID:        tokenID,
+		Username:  claims.Username,
+		Role:      claims.Role,
+		Type:      claims.TokenType,
+		IssuedAt:  claims.IssuedAt,
+		ExpiredAt: claims.Expiration,
+	}
+
+	// Check if the token payload is valid (not expired).
+	err = payload.Valid()
+	if err != nil {
+		return nil, err // Return error if token payload is invalid.
+	}
+
+	return payload, nil // Return the valid payload and no error.
+}
+
+// PublicKeys returns the current verification key followed by the previous ones.
+func (maker *PublicPasetoMaker) PublicKeys() []PublicKey {
+	return append([]PublicKey{}, maker.publicKeys...)
+}
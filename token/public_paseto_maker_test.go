@@ -0,0 +1,151 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/badermezzi/KubeGoBank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// randomEd25519Key generates a new Ed25519 key pair for testing.
+func randomEd25519Key(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return publicKey, privateKey
+}
+
+// TestPasetoV4PublicVector checks the v4.public signer against test vector 4-S-1 from the PASETO specification.
+func TestPasetoV4PublicVector(t *testing.T) {
+	secretKey, err := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+	require.NoError(t, err)
+
+	message := []byte(`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`)
+	expected := "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"
+
+	token := signV4Public(ed25519.PrivateKey(secretKey), message, nil)
+	require.Equal(t, expected, token)
+
+	body, footer, err := splitV4Public(token)
+	require.NoError(t, err)
+	require.Empty(t, footer)
+
+	verified, err := verifyV4Public(ed25519.PrivateKey(secretKey).Public().(ed25519.PublicKey), body, footer)
+	require.NoError(t, err)
+	require.Equal(t, message, verified)
+}
+
+// TestPublicPasetoMaker tests the PublicPasetoMaker's token creation and verification functionalities.
+func TestPublicPasetoMaker(t *testing.T) {
+	_, privateKey := randomEd25519Key(t)
+
+	maker, err := NewPublicPasetoMaker(privateKey)
+	require.NoError(t, err)
+
+	requireValidToken(t, maker)
+
+	token, _, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, pasetoV4PublicHeader))
+
+	publicKeys := maker.(PublicKeyProvider).PublicKeys()
+	require.Len(t, publicKeys, 1)
+	require.True(t, strings.HasPrefix(publicKeys[0].KeyID, paserkV4PIDHeader))
+	require.Equal(t, paserkPublic(privateKey.Public().(ed25519.PublicKey)), publicKeys[0].Key)
+}
+
+// TestExpiredPublicPasetoToken tests the PublicPasetoMaker's token verification with an expired token.
+func TestExpiredPublicPasetoToken(t *testing.T) {
+	_, privateKey := randomEd25519Key(t)
+
+	maker, err := NewPublicPasetoMaker(privateKey)
+	require.NoError(t, err)
+
+	token, _, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, -time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.ErrorIs(t, err, ErrExpiredToken)
+	require.Nil(t, payload)
+}
+
+// TestPublicPasetoMakerRotation tests that tokens signed with a previous key still verify after rotation.
+func TestPublicPasetoMakerRotation(t *testing.T) {
+	oldPublicKey, oldPrivateKey := randomEd25519Key(t)
+	_, newPrivateKey := randomEd25519Key(t)
+
+	oldMaker, err := NewPublicPasetoMaker(oldPrivateKey)
+	require.NoError(t, err)
+	rotatedMaker, err := NewPublicPasetoMaker(newPrivateKey, oldPublicKey)
+	require.NoError(t, err)
+	newOnlyMaker, err := NewPublicPasetoMaker(newPrivateKey)
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	oldToken, _, err := oldMaker.CreateToken(username, util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	payload, err := rotatedMaker.VerifyToken(oldToken)
+	require.NoError(t, err)
+	require.Equal(t, username, payload.Username)
+
+	payload, err = newOnlyMaker.VerifyToken(oldToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Nil(t, payload)
+
+	publicKeys := rotatedMaker.(PublicKeyProvider).PublicKeys()
+	require.Len(t, publicKeys, 2)
+	require.Equal(t, paserkPID(newPrivateKey.Public().(ed25519.PublicKey)), publicKeys[0].KeyID)
+	require.Equal(t, paserkPID(oldPublicKey), publicKeys[1].KeyID)
+}
+
+// TestInvalidPublicPasetoToken tests that tampered and foreign tokens are rejected.
+func TestInvalidPublicPasetoToken(t *testing.T) {
+	_, privateKey := randomEd25519Key(t)
+
+	maker, err := NewPublicPasetoMaker(privateKey)
+	require.NoError(t, err)
+
+	token, _, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 4)
+
+	// Swap the footer for one naming another key.
+	otherPublicKey, _ := randomEd25519Key(t)
+	otherFooter := pasetoEncoding.EncodeToString([]byte(`{"kid":"` + paserkPID(otherPublicKey) + `"}`))
+
+	// Sign with the same key ID but a different private key.
+	_, otherPrivateKey := randomEd25519Key(t)
+	forged := signV4Public(otherPrivateKey, []byte(`{}`), pasetoEncodingDecode(t, parts[3]))
+
+	localMaker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	localToken, _, err := localMaker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccessToken, time.Minute)
+	require.NoError(t, err)
+
+	for _, invalidToken := range []string{
+		"",
+		parts[0] + "." + parts[1] + "." + parts[2],                      // No footer, so no key ID.
+		parts[0] + "." + parts[1] + "." + parts[2] + "." + otherFooter,  // Unknown key ID.
+		parts[0] + "." + parts[1] + "." + parts[2][1:] + "." + parts[3], // Tampered body.
+		forged,
+		localToken,
+	} {
+		payload, err := maker.VerifyToken(invalidToken)
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Nil(t, payload)
+	}
+}
+
+// pasetoEncodingDecode decodes an unpadded base64url string for testing.
+func pasetoEncodingDecode(t *testing.T, s string) []byte {
+	data, err := pasetoEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return data
+}
@@ -7,11 +7,30 @@ import (
 )
 
 type Config struct {
-	DBDriver            string        `mapstructure:"DB_DRIVER"`
-	DBSource            string        `mapstructure:"DB_SOURCE"`
-	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
-	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	DBDriver                    string        `mapstructure:"DB_DRIVER"`
+	DBSource                    string        `mapstructure:"DB_SOURCE"`
+	ServerAddress               string        `mapstructure:"SERVER_ADDRESS"`
+	TokenType                   string        `mapstructure:"TOKEN_TYPE"`
+	TokenSymmetricKey           string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	TokenSymmetricKeys          []string      `mapstructure:"TOKEN_SYMMETRIC_KEYS"`
+	TokenJWTAlgorithm           string        `mapstructure:"TOKEN_JWT_ALGORITHM"`
+	TokenPrivateKeyFile         string        `mapstructure:"TOKEN_PRIVATE_KEY_FILE"`
+	TokenPublicKeyFiles         []string      `mapstructure:"TOKEN_PUBLIC_KEY_FILES"`
+	AccessTokenDuration         time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration        time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	RevocationSyncPeriod        time.Duration `mapstructure:"REVOCATION_SYNC_PERIOD"`
+	ExchangeRates               []string      `mapstructure:"EXCHANGE_RATES"`
+	RatesProvider               string        `mapstructure:"RATES_PROVIDER"`
+	RatesFile                   string        `mapstructure:"RATES_FILE"`
+	RatesRefreshPeriod          time.Duration `mapstructure:"RATES_REFRESH_PERIOD"`
+	CurrencySyncPeriod          time.Duration `mapstructure:"CURRENCY_SYNC_PERIOD"`
+	SchedulerPeriod             time.Duration `mapstructure:"SCHEDULER_PERIOD"`
+	SchedulerRetryPeriod        time.Duration `mapstructure:"SCHEDULER_RETRY_PERIOD"`
+	BeneficiaryCoolingOffPeriod time.Duration `mapstructure:"BENEFICIARY_COOLING_OFF_PERIOD"`
+	PayeeLookupLimit            int           `mapstructure:"PAYEE_LOOKUP_LIMIT"`
+	PayeeLookupPeriod           time.Duration `mapstructure:"PAYEE_LOOKUP_PERIOD"`
+	DefaultPageSize             int32         `mapstructure:"DEFAULT_PAGE_SIZE"`
+	MaxPageSize                 int32         `mapstructure:"MAX_PAGE_SIZE"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -31,3 +50,12 @@ func LoadConfig(path string) (config Config, err error) {
 	return
 
 }
+
+// SymmetricKeys returns the ordered list of token keys, current key first.
+// TOKEN_SYMMETRIC_KEYS takes precedence; otherwise TOKEN_SYMMETRIC_KEY is the only key.
+func (config Config) SymmetricKeys() []string {
+	if len(config.TokenSymmetricKeys) > 0 {
+		return config.TokenSymmetricKeys
+	}
+	return []string{config.TokenSymmetricKey}
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	env := "TOKEN_SYMMETRIC_KEY=12345678901234567890123456789012\n" +
+		"TOKEN_SYMMETRIC_KEYS=abcdefghijabcdefghijabcdefghijab,12345678901234567890123456789012\n" +
+		"ACCESS_TOKEN_DURATION=15m\n"
+	err := os.WriteFile(filepath.Join(dir, "app.env"), []byte(env), 0o600)
+	require.NoError(t, err)
+
+	config, err := LoadConfig(dir)
+	require.NoError(t, err)
+
+	require.Equal(t, 15*time.Minute, config.AccessTokenDuration)
+	require.Equal(t, []string{"abcdefghijabcdefghijabcdefghijab", "12345678901234567890123456789012"}, config.SymmetricKeys())
+}
+
+func TestSymmetricKeys(t *testing.T) {
+	config := Config{TokenSymmetricKey: RandomString(32)}
+	require.Equal(t, []string{config.TokenSymmetricKey}, config.SymmetricKeys())
+
+	config.TokenSymmetricKeys = []string{RandomString(32), RandomString(32)}
+	require.Equal(t, config.TokenSymmetricKeys, config.SymmetricKeys())
+}
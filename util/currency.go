@@ -1,17 +1,56 @@
 package util
 
+import (
+	"strconv"
+	"strings"
+)
+
+// Currencies seeded by the migrations. The currencies table is the registry of what the bank supports.
 const (
 	USD = "USD"
 	EUR = "EUR"
 	CAD = "CAD"
+	JPY = "JPY"
+	KWD = "KWD"
 )
 
-func IsSupportedCurrency(currency string) bool {
+// IsCurrencyCode reports whether code looks like an ISO 4217 alphabetic code.
+// It does not tell whether the currency is supported, the currency registry does.
+func IsCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatAmount writes an amount held in minor units as a decimal with minorUnit decimal places,
+// e.g. 12345 is "123.45" in USD, "12345" in JPY and "12.345" in KWD.
+func FormatAmount(amount int64, minorUnit int32) string {
+	digits := strconv.FormatUint(absAmount(amount), 10)
 
-	switch currency {
-	case USD, EUR, CAD:
-		return true
+	if minorUnit > 0 {
+		if len(digits) <= int(minorUnit) {
+			digits = strings.Repeat("0", int(minorUnit)-len(digits)+1) + digits
+		}
+		point := len(digits) - int(minorUnit)
+		digits = digits[:point] + "." + digits[point:]
 	}
-	return false
 
+	if amount < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// absAmount also handles math.MinInt64, whose absolute value does not fit in an int64.
+func absAmount(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
 }
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatAmount(t *testing.T) {
+	testCases := []struct {
+		amount    int64
+		minorUnit int32
+		want      string
+	}{
+		{amount: 12345, minorUnit: 2, want: "123.45"},
+		{amount: 12345, minorUnit: 0, want: "12345"},
+		{amount: 12345, minorUnit: 3, want: "12.345"},
+		{amount: 5, minorUnit: 2, want: "0.05"},
+		{amount: 0, minorUnit: 2, want: "0.00"},
+		{amount: 0, minorUnit: 0, want: "0"},
+		{amount: -5, minorUnit: 3, want: "-0.005"},
+		{amount: -1000, minorUnit: 2, want: "-10.00"},
+		{amount: math.MinInt64, minorUnit: 2, want: "-92233720368547758.08"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, FormatAmount(tc.amount, tc.minorUnit), tc.amount)
+	}
+}
+
+func TestIsCurrencyCode(t *testing.T) {
+	for _, code := range []string{USD, EUR, CAD, JPY, KWD, "XYZ"} {
+		require.True(t, IsCurrencyCode(code), code)
+	}
+	for _, code := range []string{"", "usd", "US", "USDT", "U$D"} {
+		require.False(t, IsCurrencyCode(code), code)
+	}
+}
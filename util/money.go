@@ -0,0 +1,124 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrAmountOverflow is returned when money arithmetic would not fit in an int64 of minor units.
+var ErrAmountOverflow = errors.New("amount overflows")
+
+// ErrMoneyCurrencyMismatch is returned when money of different currencies is added or compared.
+var ErrMoneyCurrencyMismatch = errors.New("money currencies do not match")
+
+// Money is an amount in minor units of a currency, e.g. 1050 USD is 10.50 dollars.
+// Its arithmetic is checked, so a bug shows up as an error instead of a balance that wrapped around.
+// In JSON it is written as {"value": 1050, "currency": "USD"}.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney creates Money of the given currency.
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Add returns m + other. Both must be of the same currency.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrMoneyCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	amount, err := AddAmounts(m.Amount, other.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other. Both must be of the same currency.
+func (m Money) Sub(other Money) (Money, error) {
+	negated, err := other.Negate()
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Add(negated)
+}
+
+// Negate returns -m.
+func (m Money) Negate() (Money, error) {
+	amount, err := NegateAmount(m.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// IsPositive reports whether m is more than zero.
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsZero reports whether m is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// String writes m in minor units, e.g. "1050 USD". Use FormatAmount to show it as a decimal.
+func (m Money) String() string {
+	return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+}
+
+type moneyJSON struct {
+	Value    *int64 `json:"value"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Value: &m.Amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The value must be a whole number of minor units
+// and the currency an ISO 4217 code. Whether the currency is supported is up to the caller.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var value moneyJSON
+	err := decoder.Decode(&value)
+	if err != nil {
+		return fmt.Errorf("invalid money: %w", err)
+	}
+
+	if value.Value == nil {
+		return errors.New("invalid money: value is required")
+	}
+
+	if !IsCurrencyCode(value.Currency) {
+		return fmt.Errorf("invalid money: %q is not a currency code", value.Currency)
+	}
+
+	*m = Money{Amount: *value.Value, Currency: value.Currency}
+	return nil
+}
+
+// AddAmounts returns a + b, or ErrAmountOverflow if the sum does not fit in an int64.
+func AddAmounts(a int64, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+// NegateAmount returns -amount, or ErrAmountOverflow for math.MinInt64, which has no positive counterpart.
+func NegateAmount(amount int64) (int64, error) {
+	if amount == math.MinInt64 {
+		return 0, ErrAmountOverflow
+	}
+	return -amount, nil
+}
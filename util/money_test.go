@@ -0,0 +1,85 @@
+package util
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoneyAdd(t *testing.T) {
+	sum, err := NewMoney(1050, USD).Add(NewMoney(-50, USD))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(1000, USD), sum)
+
+	_, err = NewMoney(1050, USD).Add(NewMoney(50, EUR))
+	require.ErrorIs(t, err, ErrMoneyCurrencyMismatch)
+
+	_, err = NewMoney(math.MaxInt64, USD).Add(NewMoney(1, USD))
+	require.ErrorIs(t, err, ErrAmountOverflow)
+
+	_, err = NewMoney(math.MinInt64, USD).Add(NewMoney(-1, USD))
+	require.ErrorIs(t, err, ErrAmountOverflow)
+
+	sum, err = NewMoney(math.MaxInt64, USD).Add(NewMoney(math.MinInt64, USD))
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), sum.Amount)
+}
+
+func TestMoneySub(t *testing.T) {
+	difference, err := NewMoney(1050, USD).Sub(NewMoney(2000, USD))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(-950, USD), difference)
+
+	_, err = NewMoney(1050, USD).Sub(NewMoney(50, CAD))
+	require.ErrorIs(t, err, ErrMoneyCurrencyMismatch)
+
+	_, err = NewMoney(math.MinInt64, USD).Sub(NewMoney(1, USD))
+	require.ErrorIs(t, err, ErrAmountOverflow)
+
+	_, err = NewMoney(0, USD).Sub(NewMoney(math.MinInt64, USD))
+	require.ErrorIs(t, err, ErrAmountOverflow)
+}
+
+func TestMoneyNegate(t *testing.T) {
+	negated, err := NewMoney(1050, USD).Negate()
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(-1050, USD), negated)
+
+	negated, err = NewMoney(math.MaxInt64, USD).Negate()
+	require.NoError(t, err)
+	require.Equal(t, int64(-math.MaxInt64), negated.Amount)
+
+	_, err = NewMoney(math.MinInt64, USD).Negate()
+	require.ErrorIs(t, err, ErrAmountOverflow)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(1050, USD))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"value":1050,"currency":"USD"}`, string(data))
+
+	data, err = json.Marshal(NewMoney(0, JPY))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"value":0,"currency":"JPY"}`, string(data))
+
+	var money Money
+	err = json.Unmarshal([]byte(`{"value":-1234,"currency":"KWD"}`), &money)
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(-1234, KWD), money)
+
+	for _, invalid := range []string{
+		`{"currency":"USD"}`,
+		`{"value":10.5,"currency":"USD"}`,
+		`{"value":"10","currency":"USD"}`,
+		`{"value":10,"currency":"usd"}`,
+		`{"value":10}`,
+		`{"value":10,"currency":"USD","extra":1}`,
+		`{"value":9223372036854775808,"currency":"USD"}`,
+		`1050`,
+	} {
+		err = json.Unmarshal([]byte(invalid), &money)
+		require.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,7 @@
+package util
+
+// Roles a user can have. Depositors are customers; bankers are back-office staff.
+const (
+	DepositorRole = "depositor"
+	BankerRole    = "banker"
+)